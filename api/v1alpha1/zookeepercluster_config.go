@@ -0,0 +1,165 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"strconv"
+	"strings"
+)
+
+// operatorConfigKeys are the zoo.cfg keys the operator always sets from the spec
+var operatorConfigKeys = []string{
+	"skipACL", "clientPort", "secureClientPort", "dataDir", "dataLogDir", "dynamicConfigFile",
+	"reconfigEnabled", "standaloneEnabled", "metricsProvider.httpPort", "admin.enableServer", "admin.serverPort",
+}
+
+// The zoo.cfg keys introduced by each release line; the keys ending with * match a family of keys.
+// The keys zookeeper reads as the zookeeper.<key> system property are included
+var (
+	configKeys35 = []string{
+		"tickTime", "initLimit", "syncLimit", "maxClientCnxns", "minSessionTimeout", "maxSessionTimeout",
+		"clientPort", "clientPortAddress", "secureClientPort", "secureClientPortAddress", "dataDir", "dataLogDir",
+		"dynamicConfigFile", "reconfigEnabled", "standaloneEnabled", "skipACL", "peerType", "electionAlg",
+		"quorumListenOnAllIPs", "cnxTimeout", "leaderServes", "localSessionsEnabled", "localSessionsUpgradingEnabled",
+		"autopurge.snapRetainCount", "autopurge.purgeInterval", "syncEnabled", "forceSync", "preAllocSize",
+		"snapCount", "globalOutstandingLimit", "commitLogCount", "snapSizeLimitInKb", "txnLogSizeLimitInKb",
+		"fsync.warningthresholdms", "maxResponseCacheSize", "serverCnxnFactory", "4lw.commands.whitelist",
+		"admin.*", "ssl.*", "sslQuorum", "portUnification", "quorum.auth.*", "quorum.cnxn.threads.size",
+		"authProvider.*", "kerberos.*", "jute.maxbuffer.extrasize", "snapshot.trust.empty",
+	}
+	configKeys36 = []string{
+		"metricsProvider.*", "maxCnxns", "connectToLearnerMasterLimit", "electionPortBindRetry",
+		"multiAddress.*", "client.portUnification", "sslQuorumReloadCertFiles", "digest.enabled",
+		"snapshot.compression.method", "audit.enable", "watchManagerName", "learner.closeSocketAsync",
+		"leader.closeSocketAsync", "flushDelay", "maxWriteQueuePollTime", "maxBatchSize",
+		"requestThrottleLimit", "requestThrottleStallTime", "requestThrottleDropStale", "largeRequestMaxBytes",
+		"largeRequestThreshold", "serializeLastProcessedZxid.enabled", "sessionRequireClientSASLAuth",
+	}
+	configKeys38 = []string{
+		"enforceQuota", "learner.asyncSending", "client.certReload", "enforce.auth.enabled", "enforce.auth.schemes", "clientPortListenBacklog", "netty.server.outstandingHandshake.limit",
+	}
+)
+
+// isKnownConfigKey tells whether the zoo.cfg key is read by the version. The keys of an unsupported version aren't checked
+func isKnownConfigKey(version, key string) bool {
+	i, ok := lookupVersion(version)
+	if !ok {
+		return true
+	}
+	for _, line := range versionMatrix[:i+1] {
+		for _, known := range line.configKeys {
+			if known == key || (strings.HasSuffix(known, "*") && strings.HasPrefix(key, strings.TrimSuffix(known, "*"))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OperatorConfigKeys returns the zoo.cfg keys the operator sets from the spec; the zkCfg can't override them
+func (in *ZookeeperCluster) OperatorConfigKeys() []string {
+	keys := append([]string{}, operatorConfigKeys...)
+	if in.IsTLSEnabled() {
+		keys = append(keys, "serverCnxnFactory")
+	}
+	if in.IsClientTLSEnabled() {
+		keys = append(keys, "ssl.keyStore.location", "ssl.keyStore.type", "ssl.keyStore.password",
+			"ssl.trustStore.location", "ssl.trustStore.type", "ssl.trustStore.password", "ssl.clientAuth")
+	}
+	if in.IsQuorumTLSEnabled() {
+		keys = append(keys, "sslQuorum", "ssl.quorum.keyStore.location", "ssl.quorum.keyStore.type",
+			"ssl.quorum.keyStore.password", "ssl.quorum.trustStore.location", "ssl.quorum.trustStore.type",
+			"ssl.quorum.trustStore.password")
+	}
+	if in.IsQuorumSaslEnabled() {
+		keys = append(keys, "quorum.auth.enableSasl", "quorum.auth.learnerRequireSasl", "quorum.auth.serverRequireSasl",
+			"quorum.auth.learner.saslLoginContext", "quorum.auth.server.saslLoginContext")
+	}
+	keys = append(keys, in.TLSReloadConfigKeys()...)
+	if in.OperatorCredentialsSecretName() != "" {
+		keys = append(keys, "DigestAuthenticationProvider.superDigest")
+	}
+	for key := range in.ClientAuthEnforcementConfig() {
+		keys = append(keys, key)
+	}
+	if in.IsClientSaslEnabled() {
+		keys = append(keys, "authProvider.sasl")
+		if in.Spec.Authentication.Client.Mechanism == SASLMechanismGSSAPI {
+			keys = append(keys, "kerberos.removeHostFromPrincipal", "kerberos.removeRealmFromPrincipal")
+		}
+	}
+	return keys
+}
+
+// TLSReloadConfigKeys returns the zoo.cfg keys making the members reload the renewed key stores
+// of the enabled TLS, or none unless the rotation strategy is Reload
+func (in *ZookeeperCluster) TLSReloadConfigKeys() []string {
+	if !in.IsTLSEnabled() || in.Spec.TLS.RotationStrategy != TLSRotationReload {
+		return nil
+	}
+	var keys []string
+	if in.IsClientTLSEnabled() {
+		keys = append(keys, "client.certReload")
+	}
+	if in.IsQuorumTLSEnabled() {
+		keys = append(keys, "sslQuorumReloadCertFiles")
+	}
+	return keys
+}
+
+// ClientAuthEnforcementConfig returns the zoo.cfg entries closing the sessions of the clients which don't
+// authenticate with SASL, from zookeeper 3.7. The operator, which has no SASL support, is let in as the super
+// user through the digest scheme, so none are returned without the operator credentials or on the versions
+// which can't exempt it. The digest scheme also lets in the clients adding a digest identity
+func (in *ZookeeperCluster) ClientAuthEnforcementConfig() map[string]string {
+	cfg := map[string]string{}
+	if in.IsClientSaslEnabled() && in.OperatorCredentialsSecretName() != "" &&
+		isKnownConfigKey(in.Spec.ZookeeperVersion, "enforce.auth.enabled") {
+		cfg["enforce.auth.enabled"] = "true"
+		cfg["enforce.auth.schemes"] = "sasl,digest"
+	}
+	return cfg
+}
+
+// KeyValues returns the zoo.cfg entries of the set settings. The jute.maxbuffer isn't
+// one of them since zookeeper only reads it as a system property
+func (in *ZooConfig) KeyValues() map[string]string {
+	cfg := map[string]string{}
+	if in == nil {
+		return cfg
+	}
+	settings := map[string]*int32{
+		"tickTime":               in.TickTime,
+		"initLimit":              in.InitLimit,
+		"syncLimit":              in.SyncLimit,
+		"maxClientCnxns":         in.MaxClientCnxns,
+		"minSessionTimeout":      in.MinSessionTimeout,
+		"maxSessionTimeout":      in.MaxSessionTimeout,
+		"globalOutstandingLimit": in.GlobalOutstandingLimit,
+		"snapCount":              in.SnapCount,
+	}
+	if in.AutoPurge != nil {
+		settings["autopurge.snapRetainCount"] = in.AutoPurge.SnapRetainCount
+		settings["autopurge.purgeInterval"] = in.AutoPurge.PurgeInterval
+	}
+	for key, value := range settings {
+		if value != nil {
+			cfg[key] = strconv.Itoa(int(*value))
+		}
+	}
+	return cfg
+}
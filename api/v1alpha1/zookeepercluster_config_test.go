@@ -0,0 +1,161 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestIsKnownConfigKey(t *testing.T) {
+	tests := []struct {
+		version string
+		key     string
+		known   bool
+	}{
+		{"3.5.7", "tickTime", true},
+		{"3.8.4", "tickTime", true},
+		{"3.5.7", "ssl.quorum.keyStore.location", true},
+		{"3.5.7", "ssl", false},
+		{"3.5.7", "sslQuorum", true},
+		{"3.5.7", "sslQuorumReloadCertFiles", false},
+		{"3.6.3", "sslQuorumReloadCertFiles", true},
+		{"3.5.7", "metricsProvider.className", false},
+		{"3.6.1", "metricsProvider.className", true},
+		{"3.8.4", "metricsProvider.exportJvmInfo", true},
+		{"3.6.3", "client.certReload", false},
+		{"3.8.4", "client.certReload", true},
+		{"3.6.3", "enforce.auth.enabled", false},
+		{"3.8.4", "enforce.auth.schemes", true},
+		{"3.6.3", "sessionRequireClientSASLAuth", true},
+		{"3.8.4", "authProvider.sasl", true},
+		{"3.8.4", "notAKey", false},
+		{"3.8.4", "tickTimes", false},
+		{"3.9.0", "notAKey", true},
+		{"", "notAKey", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version+" "+tt.key, func(t *testing.T) {
+			if known := isKnownConfigKey(tt.version, tt.key); known != tt.known {
+				t.Errorf("expected known to be %t", tt.known)
+			}
+		})
+	}
+}
+
+func TestOperatorConfigKeys(t *testing.T) {
+	tls := &TLS{Client: true, Quorum: true, SecretName: "zk-tls"}
+	tests := []struct {
+		name     string
+		mutate   func(c *ZookeeperCluster)
+		included []string
+		excluded []string
+	}{
+		{
+			name:     "defaults",
+			included: operatorConfigKeys,
+			excluded: []string{"serverCnxnFactory", "ssl.keyStore.location", "sslQuorum", "authProvider.sasl"},
+		},
+		{
+			name: "tls",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.TLS = tls
+			},
+			included: []string{"serverCnxnFactory", "ssl.keyStore.password", "ssl.clientAuth", "sslQuorum",
+				"ssl.quorum.trustStore.password"},
+			excluded: []string{"client.certReload", "sslQuorumReloadCertFiles"},
+		},
+		{
+			name: "tls reload",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.TLS = &TLS{Client: true, Quorum: true, SecretName: "zk-tls", RotationStrategy: TLSRotationReload}
+			},
+			included: []string{"client.certReload", "sslQuorumReloadCertFiles"},
+		},
+		{
+			name: "quorum tls reload",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.TLS = &TLS{Quorum: true, SecretName: "zk-tls", RotationStrategy: TLSRotationReload}
+			},
+			included: []string{"sslQuorumReloadCertFiles"},
+			excluded: []string{"client.certReload", "ssl.keyStore.location"},
+		},
+		{
+			name: "quorum sasl",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Authentication = &Authentication{Quorum: &QuorumAuthentication{CredentialsSecret: "zk-quorum"}}
+			},
+			included: []string{"quorum.auth.enableSasl", "quorum.auth.server.saslLoginContext"},
+			excluded: []string{"DigestAuthenticationProvider.superDigest", "authProvider.sasl"},
+		},
+		{
+			name: "client sasl",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Authentication = &Authentication{
+					OperatorCredentialsSecret: "zk-operator",
+					Client:                    &ClientAuthentication{Mechanism: SASLMechanismGSSAPI},
+				}
+			},
+			included: []string{"DigestAuthenticationProvider.superDigest", "authProvider.sasl",
+				"kerberos.removeHostFromPrincipal", "enforce.auth.enabled", "enforce.auth.schemes"},
+		},
+		{
+			name: "client sasl without the operator credentials",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Authentication = &Authentication{Client: &ClientAuthentication{Mechanism: SASLMechanismDigestMD5}}
+			},
+			included: []string{"authProvider.sasl"},
+			excluded: []string{"DigestAuthenticationProvider.superDigest", "enforce.auth.enabled",
+				"kerberos.removeHostFromPrincipal"},
+		},
+		{
+			name: "client sasl on 3.6",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.6.3"
+				c.Spec.Authentication = &Authentication{
+					OperatorCredentialsSecret: "zk-operator",
+					Client:                    &ClientAuthentication{Mechanism: SASLMechanismDigestMD5},
+				}
+			},
+			included: []string{"DigestAuthenticationProvider.superDigest", "authProvider.sasl"},
+			excluded: []string{"enforce.auth.enabled", "enforce.auth.schemes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := testCluster(tt.mutate).OperatorConfigKeys()
+			sort.Strings(keys)
+			set := map[string]bool{}
+			for _, key := range keys {
+				if set[key] {
+					t.Errorf("the key %s is returned twice", key)
+				}
+				set[key] = true
+			}
+			for _, key := range tt.included {
+				if !set[key] {
+					t.Errorf("expected the key %s in %v", key, keys)
+				}
+			}
+			for _, key := range tt.excluded {
+				if set[key] {
+					t.Errorf("unexpected key %s in %v", key, keys)
+				}
+			}
+		})
+	}
+}
@@ -24,6 +24,8 @@ import (
 	"github.com/monimesl/zookeeper-operator/internal"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
 )
 
 var (
@@ -56,12 +58,13 @@ const (
 )
 
 const (
-	defaultAdminPort          = 8080
-	defaultClientPort         = 2181
-	defaultMetricsPort        = 7000
-	defaultSecureClientPort   = -1
-	defaultQuorumPort         = 2888
-	defaultLeaderElectionPort = 3888
+	defaultAdminPort           = 8080
+	defaultClientPort          = 2181
+	defaultMetricsPort         = 7000
+	defaultSecureClientPort    = -1
+	defaultSecureClientTLSPort = 2281
+	defaultQuorumPort          = 2888
+	defaultLeaderElectionPort  = 3888
 )
 
 const (
@@ -70,6 +73,11 @@ const (
 	defaultClusterDomain            = "cluster.local"
 )
 
+const (
+	defaultRolloutProgressDeadline = 10 * time.Minute
+	defaultScrapeInterval          = "30s"
+)
+
 var (
 	defaultClusterSize            int32 = 3
 	defaultTerminationGracePeriod int64 = 120
@@ -96,13 +104,22 @@ type ZookeeperClusterSpec struct {
 	// ImagePullPolicy describes a policy for if/when to pull the image
 	// +optional
 	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Image overrides the zookeeper image the members run, e.g. to pull it from a private registry
+	// +optional
+	Image *Image `json:"image,omitempty"`
 
 	// ZkConfig defines the zoo.cfg data
 	ZkConfig string `json:"zkCfg,omitempty"`
+	// Config defines the common zoo.cfg settings; they take precedence over the same keys of the zkCfg
+	// +optional
+	Config *ZooConfig `json:"config,omitempty"`
 
 	// Persistence configures your node storage
 	// +optional
 	Persistence *Persistence `json:"persistence,omitempty"`
+	// DataLogPersistence configures the volume of the transaction logs, which every write is synced to
+	// +optional
+	DataLogPersistence *DataLogPersistence `json:"dataLogPersistence,omitempty"`
 
 	// PodConfig defines common configuration for the zookeeper pods
 	PodConfig basetype.PodConfig `json:"podConfig,omitempty"`
@@ -118,6 +135,298 @@ type ZookeeperClusterSpec struct {
 	// ClusterDomain defines the cluster domain for the cluster
 	// It defaults to cluster.local
 	ClusterDomain string `json:"clusterDomain,omitempty"`
+
+	// TLS configures the encryption of the client and quorum traffic
+	// +optional
+	TLS *TLS `json:"tls,omitempty"`
+
+	// Authentication configures how the clients and the members authenticate
+	// +optional
+	Authentication *Authentication `json:"authentication,omitempty"`
+
+	// StrictValidation makes the webhook reject the sizes which can't tolerate
+	// a member failure efficiently: the even sizes and the ones below 3. The
+	// zkCfg keys unknown to the zookeeper version are rejected too
+	// +optional
+	StrictValidation bool `json:"strictValidation,omitempty"`
+
+	// AllowScaleToZero allows setting the size to 0, which stops all the members
+	// while keeping their volumes. The webhook rejects it otherwise
+	// +optional
+	AllowScaleToZero bool `json:"allowScaleToZero,omitempty"`
+
+	// Monitoring configures the prometheus-operator objects monitoring the cluster. They're
+	// only created when the prometheus-operator resources are installed
+	// +optional
+	Monitoring *Monitoring `json:"monitoring,omitempty"`
+
+	// UpgradePolicy configures how the operator handles a rollout whose restarted member
+	// doesn't rejoin the ensemble
+	// +optional
+	UpgradePolicy *UpgradePolicy `json:"upgradePolicy,omitempty"`
+}
+
+// ZooConfig defines the common zoo.cfg settings. The unset ones keep the operator defaults
+type ZooConfig struct {
+	// TickTime is the length in milliseconds of the tick the other timeouts are measured in
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TickTime *int32 `json:"tickTime,omitempty"`
+	// InitLimit is the number of ticks a follower has to connect and sync to the leader
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	InitLimit *int32 `json:"initLimit,omitempty"`
+	// SyncLimit is the number of ticks a follower can lag behind the leader
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	SyncLimit *int32 `json:"syncLimit,omitempty"`
+	// MaxClientCnxns limits the concurrent connections of a client IP to a member; 0 removes the limit
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxClientCnxns *int32 `json:"maxClientCnxns,omitempty"`
+	// MinSessionTimeout is the minimum session timeout in milliseconds the members allow the clients
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinSessionTimeout *int32 `json:"minSessionTimeout,omitempty"`
+	// MaxSessionTimeout is the maximum session timeout in milliseconds the members allow the clients
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxSessionTimeout *int32 `json:"maxSessionTimeout,omitempty"`
+	// GlobalOutstandingLimit is the number of queued requests above which a member throttles the clients
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	GlobalOutstandingLimit *int32 `json:"globalOutstandingLimit,omitempty"`
+	// SnapCount is the number of transactions logged before a snapshot is taken
+	// +kubebuilder:validation:Minimum=2
+	// +optional
+	SnapCount *int32 `json:"snapCount,omitempty"`
+	// JuteMaxBuffer is the maximum size in bytes of a znode data and of a request. It's passed to the
+	// members as the jute.maxbuffer system property, and must fit the clients one
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	JuteMaxBuffer *int32 `json:"juteMaxBuffer,omitempty"`
+	// AutoPurge configures the periodic deletion of the old snapshots and transaction logs
+	// +optional
+	AutoPurge *AutoPurge `json:"autopurge,omitempty"`
+}
+
+// AutoPurge defines the periodic deletion of the old snapshots and transaction logs
+type AutoPurge struct {
+	// SnapRetainCount is the number of the recent snapshots, with their transaction logs, kept
+	// +kubebuilder:validation:Minimum=3
+	// +optional
+	SnapRetainCount *int32 `json:"snapRetainCount,omitempty"`
+	// PurgeInterval is the interval in hours of the purge; 0 disables it
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PurgeInterval *int32 `json:"purgeInterval,omitempty"`
+}
+
+// Monitoring defines the prometheus-operator objects monitoring the cluster
+type Monitoring struct {
+	// PodMonitor creates a PodMonitor scraping the metrics of each member
+	// +optional
+	PodMonitor bool `json:"podMonitor,omitempty"`
+	// PrometheusRule creates a PrometheusRule alerting on the quorum loss, the outstanding
+	// requests, the fsync latency and the frequent leader elections
+	// +optional
+	PrometheusRule bool `json:"prometheusRule,omitempty"`
+	// ScrapeInterval is the interval the members metrics are scraped at. Defaults to 30s
+	// +optional
+	ScrapeInterval string `json:"scrapeInterval,omitempty"`
+	// Labels are added to the monitoring objects, e.g. to match the selectors of the Prometheus instance
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Image defines the zookeeper container image
+type Image struct {
+	// Repository is the image repository. Defaults to monime/zookeeper
+	// +optional
+	Repository string `json:"repository,omitempty"`
+	// Tag is the image tag. Defaults to the zookeeperVersion, which the image must run
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// Digest pins the image by its digest, e.g. sha256:<hex>; it takes precedence over the tag
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]+:[a-f0-9]{32,}$`
+	// +optional
+	Digest string `json:"digest,omitempty"`
+	// PullSecrets are the secrets to pull the image with
+	// +optional
+	PullSecrets []v1.LocalObjectReference `json:"pullSecrets,omitempty"`
+}
+
+// UpgradePolicy defines how the operator handles the stalled rollouts
+type UpgradePolicy struct {
+	// ProgressDeadlineSeconds is how long a restarted member has to rejoin the ensemble and catch
+	// up with the leader before the rollout is considered stalled, and how long the members have
+	// to reload the renewed certificates before they're restarted instead. Defaults to 600
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+	// DisableRollback leaves a stalled version upgrade paused for a manual intervention instead
+	// of rolling the upgraded members back to the last known-good version
+	// +optional
+	DisableRollback bool `json:"disableRollback,omitempty"`
+}
+
+// Authentication defines the authentication settings of the cluster
+type Authentication struct {
+	// OperatorCredentialsSecret is the name of the Secret holding the `username` and `password`
+	// the operator authenticates with, using the digest scheme, when it manages the ensemble
+	// +optional
+	OperatorCredentialsSecret string `json:"operatorCredentialsSecret,omitempty"`
+	// Quorum enables the SASL DIGEST-MD5 authentication between the ensemble members
+	// +optional
+	Quorum *QuorumAuthentication `json:"quorum,omitempty"`
+	// Client enables the SASL authentication of the clients
+	// +optional
+	Client *ClientAuthentication `json:"client,omitempty"`
+	// SkipACL disables the znode ACL checks. Defaults to true; turning it off
+	// requires the OperatorCredentialsSecret which is granted the super user
+	// +optional
+	SkipACL *bool `json:"skipACL,omitempty"`
+}
+
+// QuorumAuthentication defines the SASL credentials the ensemble members authenticate each other with
+type QuorumAuthentication struct {
+	// CredentialsSecret is the name of the Secret holding the `username` and `password` shared by the members
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// SASLMechanism defines the SASL mechanism the clients authenticate with: DIGEST-MD5 or GSSAPI
+type SASLMechanism string
+
+const (
+	// SASLMechanismDigestMD5 authenticates the clients against the users of a Secret
+	SASLMechanismDigestMD5 SASLMechanism = "DIGEST-MD5"
+	// SASLMechanismGSSAPI authenticates the clients with Kerberos
+	SASLMechanismGSSAPI SASLMechanism = "GSSAPI"
+)
+
+// ClientAuthentication defines how the clients authenticate with SASL
+type ClientAuthentication struct {
+	// Mechanism is the SASL mechanism of the clients. Defaults to DIGEST-MD5
+	// +kubebuilder:validation:Enum="DIGEST-MD5";"GSSAPI"
+	// +optional
+	Mechanism SASLMechanism `json:"mechanism,omitempty"`
+	// UsersSecret is the name of the Secret whose keys are the DIGEST-MD5 usernames
+	// and values their passwords
+	// +optional
+	UsersSecret string `json:"usersSecret,omitempty"`
+	// Kerberos configures the GSSAPI mechanism
+	// +optional
+	Kerberos *Kerberos `json:"kerberos,omitempty"`
+}
+
+// Kerberos defines the service principal the members authenticate the GSSAPI clients with
+type Kerberos struct {
+	// Principal is the service principal of the members; `_HOST` is replaced by the pod FQDN.
+	// e.g. zookeeper/_HOST@EXAMPLE.COM
+	Principal string `json:"principal"`
+	// KeytabSecret is the name of the Secret holding the `keytab` of the service principal
+	KeytabSecret string `json:"keytabSecret"`
+	// Krb5ConfigMap is the name of the ConfigMap holding the `krb5.conf`. The image default is used if not set
+	// +optional
+	Krb5ConfigMap string `json:"krb5ConfigMap,omitempty"`
+}
+
+func (in *Authentication) setDefaults() (changed bool) {
+	if in.SkipACL == nil {
+		changed = true
+		skipACL := true
+		in.SkipACL = &skipACL
+	}
+	if in.Client != nil && in.Client.Mechanism == "" {
+		changed = true
+		in.Client.Mechanism = SASLMechanismDigestMD5
+	}
+	return
+}
+
+const (
+	// TLSCertKey is the key of the PEM certificate chain in the TLS secret
+	TLSCertKey = "tls.crt"
+	// TLSPrivateKeyKey is the key of the PEM private key in the TLS secret
+	TLSPrivateKeyKey = "tls.key"
+	// TLSCAKey is the key of the PEM CA bundle in the TLS secret
+	TLSCAKey = "ca.crt"
+	// CredentialsUsernameKey is the key of the username in a credentials secret
+	CredentialsUsernameKey = "username"
+	// CredentialsPasswordKey is the key of the password in a credentials secret
+	CredentialsPasswordKey = "password"
+	// KeytabKey is the key of the kerberos keytab in the keytab secret
+	KeytabKey = "keytab"
+)
+
+// TLS defines how the cluster traffic is encrypted
+type TLS struct {
+	// Client enables TLS on the secure client port
+	// +optional
+	Client bool `json:"client,omitempty"`
+	// Quorum enables TLS for the quorum and leader election traffic between the members.
+	// It can't be changed once the cluster is created
+	// +optional
+	Quorum bool `json:"quorum,omitempty"`
+	// SecretName is the name of the Secret holding the PEM encoded `tls.crt`, `tls.key`
+	// and `ca.crt`. It is ignored when CertManager is set
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// CertManager makes the operator request the certificate from cert-manager
+	// +optional
+	CertManager *CertManagerCertificate `json:"certManager,omitempty"`
+	// ClientAuth defines whether the clients must present a certificate. Defaults to zookeeper's "need"
+	// +kubebuilder:validation:Enum="none";"want";"need"
+	// +optional
+	ClientAuth string `json:"clientAuth,omitempty"`
+	// RotationStrategy defines how renewed certificates are picked up by the running members.
+	// RollingRestart restarts the members one at a time; Reload relies on zookeeper reloading
+	// the key stores files and falls back to restarting the members when they don't reload them
+	// within the upgradePolicy progress deadline. Defaults to RollingRestart
+	// +kubebuilder:validation:Enum="RollingRestart";"Reload"
+	// +optional
+	RotationStrategy TLSRotationStrategy `json:"rotationStrategy,omitempty"`
+}
+
+// TLSRotationStrategy defines how renewed certificates are rolled out: RollingRestart or Reload
+type TLSRotationStrategy string
+
+const (
+	// TLSRotationRollingRestart restarts the members one at a time after a certificate renewal
+	TLSRotationRollingRestart TLSRotationStrategy = "RollingRestart"
+	// TLSRotationReload lets zookeeper reload the renewed key stores without a restart
+	TLSRotationReload TLSRotationStrategy = "Reload"
+)
+
+func (in *TLS) setDefaults() (changed bool) {
+	if in.RotationStrategy == "" {
+		changed = true
+		in.RotationStrategy = TLSRotationRollingRestart
+	}
+	return
+}
+
+// CertManagerCertificate defines the cert-manager Certificate to request for the cluster
+type CertManagerCertificate struct {
+	// IssuerRef references the cert-manager issuer of the certificate
+	IssuerRef IssuerReference `json:"issuerRef"`
+	// Duration is the requested lifetime of the certificate
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// RenewBefore is how long before the expiry the certificate is renewed
+	// +optional
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+}
+
+// IssuerReference references a cert-manager Issuer or ClusterIssuer
+type IssuerReference struct {
+	Name string `json:"name"`
+	// Kind of the issuer; Issuer or ClusterIssuer. Defaults to Issuer
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Group of the issuer. Defaults to cert-manager.io
+	// +optional
+	Group string `json:"group,omitempty"`
 }
 
 type Ports struct {
@@ -158,8 +467,10 @@ func (in *Ports) setDefaults() (changed bool) {
 }
 
 type Directories struct {
+	// Data is the directory of the snapshots, on the data volume. Defaults to /data
 	Data string `json:"data,omitempty"`
-	Log  string `json:"log,omitempty"`
+	// Log is the directory of the transaction logs, on the data log volume. Defaults to <data>-log
+	Log string `json:"log,omitempty"`
 }
 
 // VolumeReclaimPolicy defines the possible volume reclaim policy: Delete or Retain
@@ -177,6 +488,47 @@ type Persistence struct {
 	// VolumeClaimSpec describes the common attributes of storage devices
 	// and allows a Source for provider-specific attributes
 	VolumeClaimSpec v1.PersistentVolumeClaimSpec `json:"volumeClaimSpec,omitempty"`
+	// Ephemeral keeps the data and the transaction logs in emptyDir volumes instead of the volume claims.
+	// A member whose pod is recreated starts empty and resyncs from the leader; meant for the dev and CI ensembles
+	// +optional
+	Ephemeral *EphemeralStorage `json:"ephemeral,omitempty"`
+}
+
+// EphemeralStorage defines the emptyDir volumes of the members
+type EphemeralStorage struct {
+	// Medium is the storage medium of the volumes; Memory backs them with tmpfs, counted in the container memory
+	// +kubebuilder:validation:Enum="";"Memory"
+	// +optional
+	Medium v1.StorageMedium `json:"medium,omitempty"`
+	// SizeLimit is the size limit of each volume
+	// +optional
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+}
+
+// DataLogPersistence defines the volume of the transaction logs
+type DataLogPersistence struct {
+	// Size is the size of the volume. Defaults to 3Gi
+	// +optional
+	Size resource.Quantity `json:"size,omitempty"`
+	// StorageClassName is the storage class of the volume, e.g. of a fast disk. Defaults to the one of the data volume
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// Annotations defines the annotations to attach to the volume claim. Defaults to the ones of the data volume
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (in *DataLogPersistence) setDefault(persistence *Persistence) (changed bool) {
+	if in.Size.IsZero() {
+		in.Size = resource.MustParse(defaultDataLogStorageVolumeSize)
+		changed = true
+	}
+	if in.StorageClassName == nil && persistence.VolumeClaimSpec.StorageClassName != nil {
+		className := *persistence.VolumeClaimSpec.StorageClassName
+		in.StorageClassName = &className
+		changed = true
+	}
+	return
 }
 
 func (in *Persistence) setDefault() (changed bool) {
@@ -197,29 +549,39 @@ func (in *Persistence) setDefault() (changed bool) {
 }
 
 func (in *ZookeeperClusterSpec) setMetricsDefault() (changed bool) {
-	return false
+	if in.Monitoring != nil && in.Monitoring.PodMonitor && in.Monitoring.ScrapeInterval == "" {
+		changed = true
+		in.Monitoring.ScrapeInterval = defaultScrapeInterval
+	}
+	return
 }
 
 func (in *ZookeeperClusterSpec) createAnnotations() map[string]string {
 	return in.Annotations
 }
 
-func (in *ZookeeperClusterSpec) GetDefaultDataLogStorageVolumeSize() string {
-	return defaultDataLogStorageVolumeSize
-}
-
 func (in *ZookeeperClusterSpec) createLabels(clusterName string) map[string]string {
-	labels := in.Labels
-	if labels == nil {
-		labels = map[string]string{}
+	labels := map[string]string{}
+	for key, value := range in.Labels {
+		labels[key] = value
+	}
+	for key, value := range createSelectorLabels(clusterName) {
+		labels[key] = value
 	}
-	labels["app"] = "zookeeper"
-	labels[k8s.LabelAppName] = "zookeeper"
-	labels[k8s.LabelAppInstance] = clusterName
-	labels[k8s.LabelAppManagedBy] = internal.OperatorName
 	return labels
 }
 
+// createSelectorLabels creates the labels the cluster objects are selected with; unlike the
+// spec labels, they never change so the selectors stay valid
+func createSelectorLabels(clusterName string) map[string]string {
+	return map[string]string{
+		"app":                 "zookeeper",
+		k8s.LabelAppName:      "zookeeper",
+		k8s.LabelAppInstance:  clusterName,
+		k8s.LabelAppManagedBy: internal.OperatorName,
+	}
+}
+
 // setDefaults set the defaults for the cluster spec and returns true otherwise false
 //
 //nolint:nakedret
@@ -253,6 +615,18 @@ func (in *ZookeeperClusterSpec) setDefaults() (changed bool) { //nolint:cyclop
 	} else if in.Ports.setDefaults() {
 		changed = true
 	}
+	if in.TLS != nil {
+		if in.TLS.setDefaults() {
+			changed = true
+		}
+		if in.TLS.Client && in.Ports.SecureClient <= 0 {
+			changed = true
+			in.Ports.SecureClient = defaultSecureClientTLSPort
+		}
+	}
+	if in.Authentication != nil && in.Authentication.setDefaults() {
+		changed = true
+	}
 	if in.ProbeConfig == nil {
 		changed = true
 		in.ProbeConfig = &pod.Probes{}
@@ -267,6 +641,13 @@ func (in *ZookeeperClusterSpec) setDefaults() (changed bool) { //nolint:cyclop
 	} else if in.Persistence.setDefault() {
 		changed = true
 	}
+	if in.DataLogPersistence == nil {
+		in.DataLogPersistence = &DataLogPersistence{}
+		changed = true
+	}
+	if in.DataLogPersistence.setDefault(in.Persistence) {
+		changed = true
+	}
 	if in.setMetricsDefault() {
 		changed = true
 	}
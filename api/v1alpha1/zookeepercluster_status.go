@@ -16,12 +16,285 @@
 
 package v1alpha1
 
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionAvailable indicates the ensemble has a leader and enough ready members to serve clients
+	ConditionAvailable = "Available"
+	// ConditionProgressing indicates the ensemble is being created, scaled or updated
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded indicates some members of the ensemble are not ready
+	ConditionDegraded = "Degraded"
+	// ConditionQuorumHealthy indicates a majority of the voting members are ready and following a leader
+	ConditionQuorumHealthy = "QuorumHealthy"
+	// ConditionReconciled indicates whether the last reconciliation succeeded
+	ConditionReconciled = "Reconciled"
+)
+
+// MemberRole defines the role of a member in the zookeeper ensemble
+type MemberRole string
+
+const (
+	MemberRoleLeader     MemberRole = "leader"
+	MemberRoleFollower   MemberRole = "follower"
+	MemberRoleObserver   MemberRole = "observer"
+	MemberRoleStandalone MemberRole = "standalone"
+	MemberRoleUnknown    MemberRole = "unknown"
+)
+
 // ZookeeperClusterStatus defines the observed state of ZookeeperCluster
 type ZookeeperClusterStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// ObservedGeneration is the most recent spec generation observed by the operator
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the cluster state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ReadyReplicas is the number of the ready members
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Selector is the label selector of the member pods, for the scale subresource
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// LeaderPod is the pod of the current ensemble leader
+	// +optional
+	LeaderPod string `json:"leader,omitempty"`
+
+	// Members defines the live state of each ensemble member
+	// +optional
+	Members []MemberStatus `json:"members,omitempty"`
+
+	// TLS defines the state of the cluster certificates
+	// +optional
+	TLS *TLSStatus `json:"tls,omitempty"`
+
+	// Ensemble defines the membership of the ensemble dynamic configuration
+	// +optional
+	Ensemble *EnsembleStatus `json:"ensemble,omitempty"`
+
+	// Rollout defines the progress of the operator orchestrated restart of the members
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+
+	// Upgrade defines the state of the zookeeper version upgrades
+	// +optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+
+	// Volumes defines the progress of the expansion of the members volumes
+	// +optional
+	Volumes []VolumeStatus `json:"volumes,omitempty"`
+
+	// AuthenticationHash is the hash of the JAAS configuration and credentials the members run with
+	// +optional
+	AuthenticationHash string `json:"authenticationHash,omitempty"`
+
 	Metadata Metadata `json:"metadata,omitempty"`
 }
 
+// MemberStatus defines the observed state of a single ensemble member
+type MemberStatus struct {
+	// Pod is the name of the pod running the member
+	Pod string `json:"pod"`
+	// MyID is the zookeeper server id of the member
+	MyID int32 `json:"myid"`
+	// Role is the role the member currently plays in the ensemble
+	Role MemberRole `json:"role,omitempty"`
+	// Zxid is the last transaction id processed by the member, in hex
+	Zxid string `json:"zxid,omitempty"`
+	// Ready tells whether the member pod is ready
+	Ready bool `json:"ready"`
+}
+
+// MembershipTransitionType defines the kind of change of the ensemble membership
+type MembershipTransitionType string
+
+const (
+	// MembershipTransitionPromoted means a caught up observer was made a voting member
+	MembershipTransitionPromoted MembershipTransitionType = "Promoted"
+	// MembershipTransitionRemoved means a member was removed from the ensemble
+	MembershipTransitionRemoved MembershipTransitionType = "Removed"
+)
+
+// maxMembershipTransitions is the number of latest membership transitions kept in the status
+const maxMembershipTransitions = 10
+
+// EnsembleStatus defines the observed membership of the ensemble
+type EnsembleStatus struct {
+	// ConfigVersion is the version of the ensemble dynamic configuration, in hex
+	// +optional
+	ConfigVersion string `json:"configVersion,omitempty"`
+	// Participants are the ids of the voting members
+	// +optional
+	Participants []int32 `json:"participants,omitempty"`
+	// Observers are the ids of the non-voting members
+	// +optional
+	Observers []int32 `json:"observers,omitempty"`
+	// Transitions are the latest membership changes made by the operator, the most recent last
+	// +optional
+	Transitions []MembershipTransition `json:"transitions,omitempty"`
+}
+
+// MembershipTransition defines a change of the ensemble membership
+type MembershipTransition struct {
+	// MyID is the server id of the member
+	MyID int32 `json:"myid"`
+	// Type is the kind of the change
+	Type MembershipTransitionType `json:"type"`
+	// ConfigVersion is the version of the dynamic configuration the change was applied on, in hex
+	// +optional
+	ConfigVersion string `json:"configVersion,omitempty"`
+	// Time is when the change was applied
+	Time metav1.Time `json:"time"`
+}
+
+// IsParticipant tells whether the server with the id is a voting member
+func (in *EnsembleStatus) IsParticipant(id int32) bool {
+	for _, p := range in.Participants {
+		if p == id {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMember tells whether the server with the id is a voting or non-voting member
+func (in *EnsembleStatus) IsMember(id int32) bool {
+	if in.IsParticipant(id) {
+		return true
+	}
+	for _, o := range in.Observers {
+		if o == id {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordTransition appends the transition and drops the oldest ones beyond the kept count
+func (in *EnsembleStatus) RecordTransition(transition MembershipTransition) {
+	in.Transitions = append(in.Transitions, transition)
+	if len(in.Transitions) > maxMembershipTransitions {
+		in.Transitions = in.Transitions[len(in.Transitions)-maxMembershipTransitions:]
+	}
+}
+
+// RolloutPhase defines the progress of a rollout
+type RolloutPhase string
+
+const (
+	// RolloutPhaseProgressing means the outdated members are being restarted one at a time
+	RolloutPhaseProgressing RolloutPhase = "Progressing"
+	// RolloutPhasePaused means a restarted member didn't rejoin the ensemble in time; no other member is restarted
+	RolloutPhasePaused RolloutPhase = "Paused"
+	// RolloutPhaseCompleted means every member runs the latest pod template
+	RolloutPhaseCompleted RolloutPhase = "Completed"
+)
+
+// RolloutStatus defines the observed state of the rollout of the statefulset pod template.
+// The followers are restarted one at a time, each once the previous one caught up with the leader, and the leader last
+type RolloutStatus struct {
+	// UpdateRevision is the statefulset revision being rolled out
+	UpdateRevision string `json:"updateRevision"`
+	// Phase is the progress of the rollout
+	Phase RolloutPhase `json:"phase"`
+	// Pod is the member being restarted
+	// +optional
+	Pod string `json:"pod,omitempty"`
+	// StepStartTime is when the member being restarted was deleted
+	// +optional
+	StepStartTime *metav1.Time `json:"stepStartTime,omitempty"`
+	// Message describes the rollout state
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// UpgradeStatus defines the observed state of the zookeeper version upgrades
+type UpgradeStatus struct {
+	// LastKnownGoodVersion is the latest version all the members ran with while following a leader
+	// +optional
+	LastKnownGoodVersion string `json:"lastKnownGoodVersion,omitempty"`
+	// LastKnownGoodImage is the image the members ran the LastKnownGoodVersion with
+	// +optional
+	LastKnownGoodImage string `json:"lastKnownGoodImage,omitempty"`
+	// RolledBackVersion is the version whose stalled upgrade was rolled back to the LastKnownGoodVersion.
+	// The members run the LastKnownGoodVersion until the zookeeperVersion is changed
+	// +optional
+	RolledBackVersion string `json:"rolledBackVersion,omitempty"`
+	// RollbackTime is when the stalled upgrade was rolled back
+	// +optional
+	RollbackTime *metav1.Time `json:"rollbackTime,omitempty"`
+}
+
+// VolumeExpansionPhase defines the progress of a volume expansion
+type VolumeExpansionPhase string
+
+const (
+	// VolumeExpansionPhaseRequested means the larger size is requested on the claim
+	VolumeExpansionPhaseRequested VolumeExpansionPhase = "Requested"
+	// VolumeExpansionPhaseResizing means the volume is being expanded by its provisioner
+	VolumeExpansionPhaseResizing VolumeExpansionPhase = "Resizing"
+	// VolumeExpansionPhaseFileSystemResizePending means the file system is expanded once the member restarts
+	VolumeExpansionPhaseFileSystemResizePending VolumeExpansionPhase = "FileSystemResizePending"
+	// VolumeExpansionPhaseCompleted means the volume has the requested size
+	VolumeExpansionPhaseCompleted VolumeExpansionPhase = "Completed"
+	// VolumeExpansionPhaseUnsupported means the storage class of the claim doesn't allow expanding its volume
+	VolumeExpansionPhaseUnsupported VolumeExpansionPhase = "Unsupported"
+)
+
+// VolumeStatus defines the observed state of the expansion of a member volume
+type VolumeStatus struct {
+	// Name is the name of the persistent volume claim
+	Name string `json:"name"`
+	// Size is the requested size of the volume
+	Size resource.Quantity `json:"size"`
+	// Capacity is the actual size of the volume
+	// +optional
+	Capacity *resource.Quantity `json:"capacity,omitempty"`
+	// Phase is the progress of the expansion
+	Phase VolumeExpansionPhase `json:"phase"`
+}
+
+// TLSRotationPhase defines the progress of a certificate rotation
+type TLSRotationPhase string
+
+const (
+	// TLSRotationPhaseRotating means the members are being restarted, or are reloading the key stores, to pick up renewed certificates
+	TLSRotationPhaseRotating TLSRotationPhase = "Rotating"
+	// TLSRotationPhaseCompleted means every member serves the current certificates
+	TLSRotationPhaseCompleted TLSRotationPhase = "Completed"
+)
+
+// TLSStatus defines the observed state of the cluster certificates
+type TLSStatus struct {
+	// CertificateHash is the hash of the certificates the key stores were generated from
+	CertificateHash string `json:"certificateHash,omitempty"`
+	// NotAfter is the expiry time of the serving certificate
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+	// RotationPhase is the progress of the last certificate rotation
+	// +optional
+	RotationPhase TLSRotationPhase `json:"rotationPhase,omitempty"`
+	// RotationStartTime is when the last certificate rotation started
+	// +optional
+	RotationStartTime *metav1.Time `json:"rotationStartTime,omitempty"`
+	// LastRotationTime is when the last certificate rotation completed
+	// +optional
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+	// RestartHash is the hash of the certificates the members were last restarted with because
+	// they didn't reload them in time
+	// +optional
+	RestartHash string `json:"restartHash,omitempty"`
+}
+
 // Metadata defines the metadata status of the ZookeeperCluster
 type Metadata struct {
 	Size                  int32             `json:"size,omitempty"`
@@ -31,6 +304,21 @@ type Metadata struct {
 	Data                  map[string]string `json:"data,omitempty"`
 }
 
+// IsVoter tells whether the member takes part in the quorum votes
+func (in *MemberStatus) IsVoter() bool {
+	return in.Role == MemberRoleLeader || in.Role == MemberRoleFollower
+}
+
+// Leader returns the current leader member or nil if none
+func (in *ZookeeperClusterStatus) Leader() *MemberStatus {
+	for i := range in.Members {
+		if in.Members[i].Role == MemberRoleLeader {
+			return &in.Members[i]
+		}
+	}
+	return nil
+}
+
 // setDefaults set the defaults for the cluster status and returns true otherwise false
 func (in *ZookeeperClusterStatus) setDefaults() (changed bool) {
 	return
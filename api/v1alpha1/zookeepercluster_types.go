@@ -18,12 +18,15 @@ package v1alpha1
 
 import (
 	"fmt"
-	"github.com/monimesl/operator-helper/basetype"
 	"github.com/monimesl/operator-helper/config"
 	"github.com/monimesl/operator-helper/k8s"
 	"github.com/monimesl/operator-helper/reconciler"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"time"
 )
 
 var (
@@ -44,7 +47,14 @@ func init() {
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=zk
 // +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.size,statuspath=.status.readyReplicas,selectorpath=.status.selector
+// +kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.spec.size`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Leader",type=string,JSONPath=`.status.leader`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.zookeeperVersion`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // ZookeeperCluster is the Schema for the zookeeperclusters API
 type ZookeeperCluster struct {
@@ -63,6 +73,19 @@ func (in *ZookeeperCluster) GenerateLabels() map[string]string {
 	return in.Spec.createLabels(in.Name)
 }
 
+// DataLogDir returns the directory of the transaction logs, where the data log volume is mounted
+func (in *ZookeeperCluster) DataLogDir() string {
+	if in.Spec.Directories.Log != "" {
+		return in.Spec.Directories.Log
+	}
+	return strings.TrimSuffix(in.Spec.Directories.Data, "/") + "-log"
+}
+
+// SelectorLabels returns the labels the members and the other cluster objects are selected with
+func (in *ZookeeperCluster) SelectorLabels() map[string]string {
+	return createSelectorLabels(in.Name)
+}
+
 func (in *ZookeeperCluster) generateName() string {
 	return in.GetName()
 }
@@ -77,6 +100,16 @@ func (in *ZookeeperCluster) HeadlessServiceName() string {
 	return fmt.Sprintf("%s-headless", in.ClientServiceName())
 }
 
+// MemberPodName defines the name of the pod running the member with the server id
+func (in *ZookeeperCluster) MemberPodName(myid int32) string {
+	return fmt.Sprintf("%s-%d", in.generateName(), myid-1)
+}
+
+// MemberFQDN defines the FQDN of the member with the server id, through the headless service
+func (in *ZookeeperCluster) MemberFQDN(myid int32) string {
+	return fmt.Sprintf("%s.%s.%s.svc.%s", in.MemberPodName(myid), in.HeadlessServiceName(), in.Namespace, in.Spec.ClusterDomain)
+}
+
 // ClientServiceFQDN defines the FQDN of the client service object
 func (in *ZookeeperCluster) ClientServiceFQDN() string {
 	return fmt.Sprintf("%s.%s.svc.%s", in.ClientServiceName(), in.Namespace, in.Spec.ClusterDomain)
@@ -87,12 +120,81 @@ func (in *ZookeeperCluster) IsSslClientSupported() bool {
 	return in.Spec.Ports.SecureClient > 0
 }
 
+// IsTLSEnabled returns whether TLS is enabled for either the client or quorum traffic
+func (in *ZookeeperCluster) IsTLSEnabled() bool {
+	return in.IsClientTLSEnabled() || in.IsQuorumTLSEnabled()
+}
+
+// IsClientTLSEnabled returns whether the secure client port is served over TLS
+func (in *ZookeeperCluster) IsClientTLSEnabled() bool {
+	return in.Spec.TLS != nil && in.Spec.TLS.Client && in.IsSslClientSupported()
+}
+
+// IsQuorumTLSEnabled returns whether the traffic between the members is encrypted
+func (in *ZookeeperCluster) IsQuorumTLSEnabled() bool {
+	return in.Spec.TLS != nil && in.Spec.TLS.Quorum
+}
+
+// TLSSecretName defines the name of the Secret holding the PEM certificates of the cluster
+func (in *ZookeeperCluster) TLSSecretName() string {
+	if in.Spec.TLS == nil {
+		return ""
+	}
+	if in.Spec.TLS.CertManager != nil {
+		return fmt.Sprintf("%s-tls", in.generateName())
+	}
+	return in.Spec.TLS.SecretName
+}
+
+// IsAvailable returns whether the cluster has a quorum serving the clients
+func (in *ZookeeperCluster) IsAvailable() bool {
+	return in.DeletionTimestamp.IsZero() && meta.IsStatusConditionTrue(in.Status.Conditions, ConditionAvailable)
+}
+
+// IsQuorumSaslEnabled returns whether the members authenticate each other with SASL
+func (in *ZookeeperCluster) IsQuorumSaslEnabled() bool {
+	return in.Spec.Authentication != nil && in.Spec.Authentication.Quorum != nil
+}
+
+// IsClientSaslEnabled returns whether the clients authenticate with SASL
+func (in *ZookeeperCluster) IsClientSaslEnabled() bool {
+	return in.Spec.Authentication != nil && in.Spec.Authentication.Client != nil
+}
+
+// IsSaslEnabled returns whether a JAAS configuration is required by the members
+func (in *ZookeeperCluster) IsSaslEnabled() bool {
+	return in.IsQuorumSaslEnabled() || in.IsClientSaslEnabled()
+}
+
+// IsACLSkipped returns whether the znode ACL checks are disabled
+func (in *ZookeeperCluster) IsACLSkipped() bool {
+	return isACLSkipped(in.Spec.Authentication)
+}
+
+// OperatorCredentialsSecretName returns the name of the Secret with the operator credentials, if any
+func (in *ZookeeperCluster) OperatorCredentialsSecretName() string {
+	if in.Spec.Authentication == nil {
+		return ""
+	}
+	return in.Spec.Authentication.OperatorCredentialsSecret
+}
+
+// AuthSecretName defines the name of the operator generated Secret holding the JAAS configuration
+func (in *ZookeeperCluster) AuthSecretName() string {
+	return fmt.Sprintf("%s-auth", in.GetName())
+}
+
+// KeystoreSecretName defines the name of the Secret holding the PKCS12 stores generated from the certificates
+func (in *ZookeeperCluster) KeystoreSecretName() string {
+	return fmt.Sprintf("%s-keystore", in.generateName())
+}
+
 // WaitClusterTermination wait for all the zookeeper pods in cluster to terminated
 func (in *ZookeeperCluster) WaitClusterTermination(kubeClient client.Client) (err error) {
 	config.RequireRootLogger().Info(
 		"Waiting for the cluster to terminate",
 		"cluster", in.GetName())
-	labels := in.GenerateLabels()
+	labels := in.SelectorLabels()
 	return k8s.WaitForPodsToTerminate(kubeClient, in.Namespace, labels)
 }
 
@@ -106,13 +208,67 @@ func (in *ZookeeperCluster) SetStatusDefaults() bool {
 	return in.Status.setDefaults()
 }
 
-// Image the zookeeper docker image for the cluster
-func (in *ZookeeperCluster) Image() basetype.Image {
-	return basetype.Image{
-		Repository: imageRepository,
-		PullPolicy: in.Spec.ImagePullPolicy,
-		Tag:        in.Spec.ZookeeperVersion,
+// Image returns the reference of the zookeeper image the members should run:
+// <repository>:<tag> or <repository>@<digest>. The image of the last known-good
+// version is returned when the upgrade to the spec version was rolled back
+func (in *ZookeeperCluster) Image() string {
+	if in.isUpgradeRolledBack() && in.Status.Upgrade.LastKnownGoodImage != "" {
+		return in.Status.Upgrade.LastKnownGoodImage
+	}
+	repository, tag := imageRepository, in.TargetVersion()
+	if image := in.Spec.Image; image != nil {
+		if image.Repository != "" {
+			repository = image.Repository
+		}
+		if image.Digest != "" {
+			return fmt.Sprintf("%s@%s", repository, image.Digest)
+		}
+		if image.Tag != "" {
+			tag = image.Tag
+		}
+	}
+	return fmt.Sprintf("%s:%s", repository, tag)
+}
+
+// ImagePullSecrets returns the secrets to pull the zookeeper image with
+func (in *ZookeeperCluster) ImagePullSecrets() []v1.LocalObjectReference {
+	if in.Spec.Image == nil {
+		return nil
+	}
+	return in.Spec.Image.PullSecrets
+}
+
+// TargetVersion returns the zookeeper version the members should run: the spec one,
+// unless its upgrade stalled and was rolled back to the last known-good version
+func (in *ZookeeperCluster) TargetVersion() string {
+	if in.isUpgradeRolledBack() {
+		return in.Status.Upgrade.LastKnownGoodVersion
 	}
+	return in.Spec.ZookeeperVersion
+}
+
+func (in *ZookeeperCluster) isUpgradeRolledBack() bool {
+	upgrade := in.Status.Upgrade
+	return upgrade != nil && upgrade.LastKnownGoodVersion != "" &&
+		upgrade.RolledBackVersion == in.Spec.ZookeeperVersion
+}
+
+// RolloutProgressDeadline returns how long a restarted member has to rejoin the ensemble
+func (in *ZookeeperCluster) RolloutProgressDeadline() time.Duration {
+	if policy := in.Spec.UpgradePolicy; policy != nil && policy.ProgressDeadlineSeconds != nil {
+		return time.Duration(*policy.ProgressDeadlineSeconds) * time.Second
+	}
+	return defaultRolloutProgressDeadline
+}
+
+// IsUpgradeRollbackEnabled tells whether the stalled version upgrades are rolled back
+func (in *ZookeeperCluster) IsUpgradeRollbackEnabled() bool {
+	return in.Spec.UpgradePolicy == nil || !in.Spec.UpgradePolicy.DisableRollback
+}
+
+// IsEphemeral tells whether the members keep their data in emptyDir volumes instead of the volume claims
+func (in *ZookeeperCluster) IsEphemeral() bool {
+	return in.Spec.Persistence != nil && in.Spec.Persistence.Ephemeral != nil
 }
 
 // ShouldDeleteStorage returns whether the PV should be deleted or not
@@ -0,0 +1,375 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"fmt"
+	"github.com/monimesl/operator-helper/webhook"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sort"
+)
+
+var specPath = field.NewPath("spec")
+
+// validate validates the spec of the cluster; the old object is nil on creation.
+// The returned warnings describe the risky but allowed settings or changes
+func (in *ZookeeperCluster) validate(old *ZookeeperCluster) (admission.Warnings, error) {
+	warnings := admission.Warnings{}
+	if !in.DeletionTimestamp.IsZero() {
+		// Only the finalizers are updated while the cluster is being deleted
+		return warnings, nil
+	}
+	err := webhook.Validate(GroupVersion.WithKind("ZookeeperCluster"), in.Name,
+		in.Spec.validatePorts,
+		func(list *webhook.ErrorList) {
+			warnings = append(warnings, in.Spec.validateVersion(list)...)
+		},
+		func(list *webhook.ErrorList) {
+			warnings = append(warnings, in.validateZkConfig(list)...)
+		},
+		in.Spec.Config.validate,
+		in.validateTLSRotation,
+		func(list *webhook.ErrorList) {
+			warnings = append(warnings, in.clientAuthWarnings()...)
+		},
+		func(list *webhook.ErrorList) {
+			warnings = append(warnings, in.Spec.validateSize(list)...)
+		},
+		func(list *webhook.ErrorList) {
+			warnings = append(warnings, in.Spec.persistenceWarnings(old == nil)...)
+		},
+		func(list *webhook.ErrorList) {
+			if old != nil {
+				in.Spec.validateImmutableFields(&old.Spec, list)
+				in.validateVersionChange(old, list)
+				warnings = append(warnings, in.Spec.updateWarnings(&old.Spec)...)
+			}
+		},
+	)
+	return warnings, err
+}
+
+// validatePorts rejects the enabled ports which collide
+func (in *ZookeeperClusterSpec) validatePorts(list *webhook.ErrorList) {
+	if in.Ports == nil {
+		return
+	}
+	path := specPath.Child("ports")
+	ports := []struct {
+		name string
+		port int32
+	}{
+		{"client", in.Ports.Client},
+		{"secureClient", in.Ports.SecureClient},
+		{"metrics", in.Ports.Metrics},
+		{"quorum", in.Ports.Quorum},
+		{"leader", in.Ports.Leader},
+		{"admin", in.Ports.Admin},
+	}
+	used := map[int32]string{}
+	for _, p := range ports {
+		if p.port <= 0 {
+			continue
+		}
+		if p.port > 65535 {
+			list.Add(field.Invalid(path.Child(p.name), p.port, "must be a valid port number"))
+			continue
+		}
+		if other, ok := used[p.port]; ok {
+			list.Add(field.Duplicate(path.Child(p.name), fmt.Sprintf("%d is already used by the %s port", p.port, other)))
+			continue
+		}
+		used[p.port] = p.name
+	}
+}
+
+// validateVersion rejects the versions the operator has no image for and warns about the deprecated ones
+func (in *ZookeeperClusterSpec) validateVersion(list *webhook.ErrorList) admission.Warnings {
+	if in.ZookeeperVersion == "" {
+		return nil
+	}
+	if _, ok := lookupVersion(in.ZookeeperVersion); !ok {
+		list.Add(field.NotSupported(specPath.Child("zookeeperVersion"), in.ZookeeperVersion, SupportedVersions()))
+		return nil
+	}
+	if deprecation := versionDeprecation(in.ZookeeperVersion); deprecation != "" {
+		return admission.Warnings{deprecation}
+	}
+	return nil
+}
+
+// validateVersionChange rejects the version changes the members can't follow. They're checked
+// against the version the members run, which differs from the spec one after a rollback
+func (in *ZookeeperCluster) validateVersionChange(old *ZookeeperCluster, list *webhook.ErrorList) {
+	if in.Spec.ZookeeperVersion == old.Spec.ZookeeperVersion {
+		return
+	}
+	if problem := checkVersionChange(old.TargetVersion(), in.Spec.ZookeeperVersion); problem != "" {
+		list.Add(field.Forbidden(specPath.Child("zookeeperVersion"), problem))
+	}
+}
+
+// validateZkConfig rejects the zoo.cfg overrides which aren't a flat yaml map, and the keys unknown to the
+// zookeeper version in strict mode. The overrides of the keys set by the operator or the config are warned about
+func (in *ZookeeperCluster) validateZkConfig(list *webhook.ErrorList) admission.Warnings {
+	if in.Spec.ZkConfig == "" {
+		return nil
+	}
+	path := specPath.Child("zkCfg")
+	cfg := map[string]string{}
+	if err := yaml.Unmarshal([]byte(in.Spec.ZkConfig), &cfg); err != nil {
+		list.Add(field.Invalid(path, in.Spec.ZkConfig,
+			fmt.Sprintf("must be a yaml map of the zoo.cfg keys to their values: %s", err)))
+		return nil
+	}
+	owned := map[string]bool{}
+	for _, key := range in.OperatorConfigKeys() {
+		owned[key] = true
+	}
+	typed := in.Spec.Config.KeyValues()
+	keys := make([]string, 0, len(cfg))
+	for key := range cfg {
+		keys = append(keys, key)
+	}
+	// Sorted so the warnings are stable
+	sort.Strings(keys)
+	var warnings admission.Warnings
+	for _, key := range keys {
+		if owned[key] {
+			warnings = append(warnings, fmt.Sprintf("the zkCfg key %s is set by the operator; its value is ignored", key))
+			continue
+		}
+		if _, ok := typed[key]; ok {
+			warnings = append(warnings, fmt.Sprintf("the zkCfg key %s is set by the config; its value is ignored", key))
+			continue
+		}
+		if key == "jute.maxbuffer" {
+			warnings = append(warnings, "the zkCfg key jute.maxbuffer has no effect; set the config juteMaxBuffer instead")
+			continue
+		}
+		if isKnownConfigKey(in.Spec.ZookeeperVersion, key) {
+			continue
+		}
+		problem := fmt.Sprintf("%s is not a zoo.cfg key of zookeeper %s", key, in.Spec.ZookeeperVersion)
+		if in.Spec.StrictValidation {
+			list.Add(field.Invalid(path, key, problem))
+		} else {
+			warnings = append(warnings, problem)
+		}
+	}
+	return warnings
+}
+
+// validateTLSRotation rejects the Reload rotation strategy on the versions which can't reload the key stores
+func (in *ZookeeperCluster) validateTLSRotation(list *webhook.ErrorList) {
+	for _, key := range in.TLSReloadConfigKeys() {
+		if !isKnownConfigKey(in.Spec.ZookeeperVersion, key) {
+			list.Add(field.Forbidden(specPath.Child("tls", "rotationStrategy"),
+				fmt.Sprintf("zookeeper %s can't reload the key stores (%s); use the RollingRestart strategy",
+					in.Spec.ZookeeperVersion, key)))
+			return
+		}
+	}
+}
+
+// clientAuthWarnings warns about the versions which can't close the sessions of the unauthenticated clients
+func (in *ZookeeperCluster) clientAuthWarnings() admission.Warnings {
+	if !in.IsClientSaslEnabled() || len(in.ClientAuthEnforcementConfig()) > 0 {
+		return nil
+	}
+	if in.OperatorCredentialsSecretName() == "" {
+		return admission.Warnings{"the clients aren't required to authenticate without the operator credentials, " +
+			"which keep the operator let in; the unauthenticated clients are only limited by the ACLs"}
+	}
+	return admission.Warnings{fmt.Sprintf("zookeeper %s can't require the clients to authenticate while letting "+
+		"the operator in; use 3.8+, the unauthenticated clients are only limited by the ACLs", in.Spec.ZookeeperVersion)}
+}
+
+// validate rejects the session timeouts bounds which zookeeper refuses to start with. The unset
+// bounds default to 2 and 20 ticks
+func (in *ZooConfig) validate(list *webhook.ErrorList) {
+	if in == nil || (in.MinSessionTimeout == nil && in.MaxSessionTimeout == nil) {
+		return
+	}
+	tickTime := int32(2000)
+	if in.TickTime != nil {
+		tickTime = *in.TickTime
+	}
+	minTimeout, maxTimeout := 2*tickTime, 20*tickTime
+	if in.MinSessionTimeout != nil {
+		minTimeout = *in.MinSessionTimeout
+	}
+	if in.MaxSessionTimeout != nil {
+		maxTimeout = *in.MaxSessionTimeout
+	}
+	if minTimeout > maxTimeout {
+		list.Add(field.Invalid(specPath.Child("config", "minSessionTimeout"), minTimeout,
+			fmt.Sprintf("must not be greater than the max session timeout %d", maxTimeout)))
+	}
+}
+
+// validateSize rejects the even and too-small sizes in strict mode; they're only warned about otherwise
+func (in *ZookeeperClusterSpec) validateSize(list *webhook.ErrorList) admission.Warnings {
+	if in.Size == nil {
+		return nil
+	}
+	if *in.Size == 0 {
+		if !in.AllowScaleToZero {
+			list.Add(field.Forbidden(specPath.Child("size"),
+				"scaling to zero stops all the members; set allowScaleToZero to allow it"))
+		}
+		return nil
+	}
+	size := *in.Size
+	var problem string
+	if size < 3 {
+		problem = fmt.Sprintf("a %d member ensemble can't tolerate a member failure", size)
+	} else if size%2 == 0 {
+		problem = fmt.Sprintf("a %d member ensemble tolerates no more failures than a %d member one", size, size-1)
+	} else {
+		return nil
+	}
+	if in.StrictValidation {
+		list.Add(field.Invalid(specPath.Child("size"), size, problem+"; use an odd size of at least 3"))
+		return nil
+	}
+	return admission.Warnings{problem}
+}
+
+// persistenceWarnings describes the data loss the ephemeral storage is exposed to
+func (in *ZookeeperClusterSpec) persistenceWarnings(created bool) admission.Warnings {
+	var warnings admission.Warnings
+	if in.Persistence == nil || in.Persistence.Ephemeral == nil {
+		return warnings
+	}
+	if created {
+		warnings = append(warnings, "the storage is ephemeral; a member loses its data when its pod is recreated, "+
+			"and the ensemble loses it all when most members are recreated at once")
+	}
+	if in.Persistence.Ephemeral.Medium == v1.StorageMediumMemory && in.Persistence.Ephemeral.SizeLimit == nil {
+		warnings = append(warnings, "the memory backed volumes have no size limit; "+
+			"they're bounded by the container memory limit only")
+	}
+	return warnings
+}
+
+// validateImmutableFields rejects the changes which the running members or their volumes can't follow
+func (in *ZookeeperClusterSpec) validateImmutableFields(old *ZookeeperClusterSpec, list *webhook.ErrorList) {
+	if old.Directories != nil && !equality.Semantic.DeepEqual(old.Directories, in.Directories) {
+		list.Add(field.Forbidden(specPath.Child("directories"), "the directories can't be changed"))
+	}
+	if old.Persistence != nil && in.Persistence != nil && (old.Persistence.Ephemeral == nil) != (in.Persistence.Ephemeral == nil) {
+		list.Add(field.Forbidden(specPath.Child("persistence", "ephemeral"),
+			"the storage can't be switched between ephemeral and persistent"))
+	}
+	if old.Persistence != nil && old.Persistence.VolumeClaimSpec.StorageClassName != nil {
+		oldClass := *old.Persistence.VolumeClaimSpec.StorageClassName
+		if in.Persistence == nil || in.Persistence.VolumeClaimSpec.StorageClassName == nil ||
+			*in.Persistence.VolumeClaimSpec.StorageClassName != oldClass {
+			list.Add(field.Forbidden(specPath.Child("persistence", "volumeClaimSpec", "storageClassName"),
+				"the storage class can't be changed"))
+		}
+	}
+	if old.Persistence != nil && in.Persistence != nil {
+		oldSize := old.Persistence.VolumeClaimSpec.Resources.Requests[v1.ResourceStorage]
+		size := in.Persistence.VolumeClaimSpec.Resources.Requests[v1.ResourceStorage]
+		if !oldSize.IsZero() && !size.IsZero() && size.Cmp(oldSize) < 0 {
+			list.Add(field.Forbidden(specPath.Child("persistence", "volumeClaimSpec", "resources", "requests", "storage"),
+				fmt.Sprintf("the volumes can't shrink from %s to %s", oldSize.String(), size.String())))
+		}
+	}
+	if old.DataLogPersistence != nil && in.DataLogPersistence != nil {
+		path := specPath.Child("dataLogPersistence")
+		if old.DataLogPersistence.StorageClassName != nil && (in.DataLogPersistence.StorageClassName == nil ||
+			*in.DataLogPersistence.StorageClassName != *old.DataLogPersistence.StorageClassName) {
+			list.Add(field.Forbidden(path.Child("storageClassName"), "the storage class can't be changed"))
+		}
+		oldSize, size := old.DataLogPersistence.Size, in.DataLogPersistence.Size
+		if !oldSize.IsZero() && !size.IsZero() && size.Cmp(oldSize) < 0 {
+			list.Add(field.Forbidden(path.Child("size"),
+				fmt.Sprintf("the volumes can't shrink from %s to %s", oldSize.String(), size.String())))
+		}
+	}
+	if old.Ports != nil && in.Ports != nil {
+		path := specPath.Child("ports")
+		if old.Ports.Quorum > 0 && old.Ports.Quorum != in.Ports.Quorum {
+			list.Add(field.Forbidden(path.Child("quorum"), "the quorum port can't be changed"))
+		}
+		if old.Ports.Leader > 0 && old.Ports.Leader != in.Ports.Leader {
+			list.Add(field.Forbidden(path.Child("leader"), "the leader election port can't be changed"))
+		}
+	}
+	if old.ClusterDomain != "" && old.ClusterDomain != in.ClusterDomain {
+		list.Add(field.Forbidden(specPath.Child("clusterDomain"), "the cluster domain can't be changed"))
+	}
+	// The restarted members would no longer talk to the ones still running with the previous setting
+	if (old.TLS != nil && old.TLS.Quorum) != (in.TLS != nil && in.TLS.Quorum) {
+		list.Add(field.Forbidden(specPath.Child("tls", "quorum"), "the quorum TLS can't be turned on or off"))
+	}
+}
+
+// updateWarnings describes the allowed changes which restart the members or may lock the clients out
+func (in *ZookeeperClusterSpec) updateWarnings(old *ZookeeperClusterSpec) admission.Warnings {
+	var warnings admission.Warnings
+	if old.Size != nil && in.Size != nil && *old.Size-*in.Size > 1 {
+		warnings = append(warnings, fmt.Sprintf("the members are removed one at a time from %d to %d, "+
+			"each once the remaining ensemble is synced", *old.Size, *in.Size))
+	}
+	if old.ZookeeperVersion != "" && old.ZookeeperVersion != in.ZookeeperVersion {
+		warnings = append(warnings, fmt.Sprintf("changing the zookeeper version from %s to %s restarts all the members",
+			old.ZookeeperVersion, in.ZookeeperVersion))
+	}
+	if old.Persistence != nil && in.Persistence != nil && in.Persistence.Ephemeral == nil {
+		oldSize := old.Persistence.VolumeClaimSpec.Resources.Requests[v1.ResourceStorage]
+		size := in.Persistence.VolumeClaimSpec.Resources.Requests[v1.ResourceStorage]
+		if !oldSize.IsZero() && size.Cmp(oldSize) > 0 {
+			warnings = append(warnings, fmt.Sprintf("the volumes are expanded online from %s to %s only if their "+
+				"storage class allows it; see status.volumes", oldSize.String(), size.String()))
+		}
+	}
+	if old.DataLogPersistence != nil && in.DataLogPersistence != nil && (in.Persistence == nil || in.Persistence.Ephemeral == nil) {
+		oldSize, size := old.DataLogPersistence.Size, in.DataLogPersistence.Size
+		if !oldSize.IsZero() && size.Cmp(oldSize) > 0 {
+			warnings = append(warnings, fmt.Sprintf("the data log volumes are expanded online from %s to %s only if "+
+				"their storage class allows it; see status.volumes", oldSize.String(), size.String()))
+		}
+	}
+	if old.ZkConfig != in.ZkConfig || !equality.Semantic.DeepEqual(old.Config, in.Config) {
+		warnings = append(warnings, "changing the zoo.cfg restarts all the members")
+	}
+	if !equality.Semantic.DeepEqual(old.TLS, in.TLS) {
+		warnings = append(warnings, "changing the TLS settings restarts all the members")
+	}
+	if !equality.Semantic.DeepEqual(old.Authentication, in.Authentication) {
+		warnings = append(warnings, "changing the authentication settings restarts all the members")
+	}
+	if isACLSkipped(old.Authentication) && !isACLSkipped(in.Authentication) {
+		warnings = append(warnings, "enabling the ACL checks denies the clients access to the znodes their ACLs don't grant")
+	}
+	if old.Ports != nil && in.Ports != nil && old.Ports.Client > 0 && in.Ports.Client <= 0 {
+		warnings = append(warnings, "disabling the plain client port disconnects the clients which don't use TLS")
+	}
+	return warnings
+}
+
+func isACLSkipped(auth *Authentication) bool {
+	return auth == nil || auth.SkipACL == nil || *auth.SkipACL
+}
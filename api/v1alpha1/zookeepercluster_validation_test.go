@@ -0,0 +1,490 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+)
+
+// testCluster returns a defaulted cluster changed by the mutate function. The changed spec is
+// defaulted again, as the webhook does before validating it
+func testCluster(mutate func(c *ZookeeperCluster)) *ZookeeperCluster {
+	c := &ZookeeperCluster{ObjectMeta: metav1.ObjectMeta{Name: "zk", Namespace: "default"}}
+	c.SetSpecDefaults()
+	if mutate != nil {
+		mutate(c)
+		c.SetSpecDefaults()
+	}
+	return c
+}
+
+func withSize(size int32) func(c *ZookeeperCluster) {
+	return func(c *ZookeeperCluster) {
+		c.Spec.Size = &size
+	}
+}
+
+func withStorage(size string) func(c *ZookeeperCluster) {
+	return func(c *ZookeeperCluster) {
+		c.Spec.Persistence.VolumeClaimSpec.Resources.Requests = v1.ResourceList{
+			v1.ResourceStorage: resource.MustParse(size),
+		}
+	}
+}
+
+func withStorageClass(class string) func(c *ZookeeperCluster) {
+	return func(c *ZookeeperCluster) {
+		c.Spec.Persistence.VolumeClaimSpec.StorageClassName = &class
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *ZookeeperCluster)
+		err     string
+		warning string
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name: "port collision",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Ports.Metrics = c.Spec.Ports.Client
+			},
+			err: "2181 is already used by the client port",
+		},
+		{
+			name: "port collision with the secure client port",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Ports.SecureClient = 3888
+			},
+			err: "3888 is already used by the secureClient port",
+		},
+		{
+			name: "disabled ports don't collide",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Ports.Admin = -1
+				c.Spec.Ports.SecureClient = -1
+			},
+		},
+		{
+			name: "port out of range",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Ports.Admin = 65536
+			},
+			err: "must be a valid port number",
+		},
+		{
+			name:    "even size",
+			mutate:  withSize(4),
+			warning: "a 4 member ensemble tolerates no more failures than a 3 member one",
+		},
+		{
+			name:    "too small size",
+			mutate:  withSize(1),
+			warning: "a 1 member ensemble can't tolerate a member failure",
+		},
+		{
+			name: "even size in strict mode",
+			mutate: func(c *ZookeeperCluster) {
+				withSize(2)(c)
+				c.Spec.StrictValidation = true
+			},
+			err: "a 2 member ensemble can't tolerate a member failure; use an odd size of at least 3",
+		},
+		{
+			name: "odd size in strict mode",
+			mutate: func(c *ZookeeperCluster) {
+				withSize(5)(c)
+				c.Spec.StrictValidation = true
+			},
+		},
+		{
+			name:   "scale to zero",
+			mutate: withSize(0),
+			err:    "set allowScaleToZero to allow it",
+		},
+		{
+			name: "allowed scale to zero",
+			mutate: func(c *ZookeeperCluster) {
+				withSize(0)(c)
+				c.Spec.AllowScaleToZero = true
+			},
+		},
+		{
+			name: "unsupported version",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.4.14"
+			},
+			err: "Unsupported value: \"3.4.14\"",
+		},
+		{
+			name: "session timeouts bounds",
+			mutate: func(c *ZookeeperCluster) {
+				minTimeout, maxTimeout := int32(30000), int32(10000)
+				c.Spec.Config = &ZooConfig{MinSessionTimeout: &minTimeout, MaxSessionTimeout: &maxTimeout}
+			},
+			err: "must not be greater than the max session timeout 10000",
+		},
+		{
+			name: "min session timeout above the default max",
+			mutate: func(c *ZookeeperCluster) {
+				minTimeout := int32(50000)
+				c.Spec.Config = &ZooConfig{MinSessionTimeout: &minTimeout}
+			},
+			err: "must not be greater than the max session timeout 40000",
+		},
+		{
+			name: "tls reload on 3.5",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.5.7"
+				c.Spec.TLS = &TLS{Quorum: true, SecretName: "zk-tls", RotationStrategy: TLSRotationReload}
+			},
+			err: "zookeeper 3.5.7 can't reload the key stores (sslQuorumReloadCertFiles)",
+		},
+		{
+			name: "tls reload on 3.8",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.TLS = &TLS{Client: true, Quorum: true, SecretName: "zk-tls", RotationStrategy: TLSRotationReload}
+			},
+		},
+		{
+			name: "zkCfg not a map",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZkConfig = "- tickTime"
+			},
+			err: "must be a yaml map of the zoo.cfg keys to their values",
+		},
+		{
+			name: "unknown zkCfg key",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZkConfig = "notAKey: \"1\""
+			},
+			warning: "notAKey is not a zoo.cfg key of zookeeper 3.8.4",
+		},
+		{
+			name: "unknown zkCfg key in strict mode",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZkConfig = "notAKey: \"1\""
+				c.Spec.StrictValidation = true
+			},
+			err: "notAKey is not a zoo.cfg key of zookeeper 3.8.4",
+		},
+		{
+			name: "zkCfg key set by the operator",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZkConfig = "dataDir: /tmp"
+			},
+			warning: "the zkCfg key dataDir is set by the operator; its value is ignored",
+		},
+		{
+			name: "client sasl enforced",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Authentication = &Authentication{
+					OperatorCredentialsSecret: "zk-operator",
+					Client:                    &ClientAuthentication{Mechanism: SASLMechanismDigestMD5},
+				}
+			},
+		},
+		{
+			name: "client sasl without the operator credentials",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Authentication = &Authentication{Client: &ClientAuthentication{Mechanism: SASLMechanismDigestMD5}}
+			},
+			warning: "the clients aren't required to authenticate without the operator credentials",
+		},
+		{
+			name: "client sasl on 3.6",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.6.3"
+				c.Spec.Authentication = &Authentication{
+					OperatorCredentialsSecret: "zk-operator",
+					Client:                    &ClientAuthentication{Mechanism: SASLMechanismDigestMD5},
+				}
+			},
+			warning: "zookeeper 3.6.3 can't require the clients to authenticate while letting the operator in",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testCluster(tt.mutate)
+			warnings, err := c.validate(nil)
+			checkValidation(t, warnings, err, tt.warning, tt.err)
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     func(c *ZookeeperCluster)
+		mutate  func(c *ZookeeperCluster)
+		err     string
+		warning string
+	}{
+		{
+			name: "no change",
+		},
+		{
+			name: "directories",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Directories = &Directories{Data: "/var/lib/zookeeper"}
+			},
+			err: "the directories can't be changed",
+		},
+		{
+			name:   "storage class",
+			old:    withStorageClass("standard"),
+			mutate: withStorageClass("fast"),
+			err:    "the storage class can't be changed",
+		},
+		{
+			name: "unset storage class",
+			old:  withStorageClass("standard"),
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Persistence.VolumeClaimSpec.StorageClassName = nil
+			},
+			err: "the storage class can't be changed",
+		},
+		{
+			name: "data log storage class",
+			mutate: func(c *ZookeeperCluster) {
+				class := "fast"
+				c.Spec.DataLogPersistence.StorageClassName = &class
+			},
+		},
+		{
+			name: "changed data log storage class",
+			old: func(c *ZookeeperCluster) {
+				class := "standard"
+				c.Spec.DataLogPersistence.StorageClassName = &class
+			},
+			mutate: func(c *ZookeeperCluster) {
+				class := "fast"
+				c.Spec.DataLogPersistence.StorageClassName = &class
+			},
+			err: "the storage class can't be changed",
+		},
+		{
+			name: "ephemeral toggle",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Persistence.Ephemeral = &EphemeralStorage{}
+			},
+			err: "the storage can't be switched between ephemeral and persistent",
+		},
+		{
+			name: "persistent toggle",
+			old: func(c *ZookeeperCluster) {
+				c.Spec.Persistence.Ephemeral = &EphemeralStorage{}
+			},
+			err: "the storage can't be switched between ephemeral and persistent",
+		},
+		{
+			name:   "storage shrink",
+			old:    withStorage("20Gi"),
+			mutate: withStorage("10Gi"),
+			err:    "the volumes can't shrink from 20Gi to 10Gi",
+		},
+		{
+			name:    "storage expansion",
+			old:     withStorage("20Gi"),
+			mutate:  withStorage("30Gi"),
+			warning: "the volumes are expanded online from 20Gi to 30Gi",
+		},
+		{
+			name: "data log shrink",
+			old: func(c *ZookeeperCluster) {
+				c.Spec.DataLogPersistence.Size = resource.MustParse("5Gi")
+			},
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.DataLogPersistence.Size = resource.MustParse("2Gi")
+			},
+			err: "the volumes can't shrink from 5Gi to 2Gi",
+		},
+		{
+			name: "data log expansion",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.DataLogPersistence.Size = resource.MustParse("10Gi")
+			},
+			warning: "the data log volumes are expanded online",
+		},
+		{
+			name: "quorum port",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Ports.Quorum = 2889
+			},
+			err: "the quorum port can't be changed",
+		},
+		{
+			name: "leader election port",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Ports.Leader = 3889
+			},
+			err: "the leader election port can't be changed",
+		},
+		{
+			name: "client port",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Ports.Client = 2182
+			},
+		},
+		{
+			name: "disabled client port",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.Ports.Client = -1
+			},
+			warning: "disabling the plain client port disconnects the clients which don't use TLS",
+		},
+		{
+			name: "cluster domain",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ClusterDomain = "example.org"
+			},
+			err: "the cluster domain can't be changed",
+		},
+		{
+			name: "quorum TLS turned on",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.TLS = &TLS{Quorum: true, SecretName: "zk-tls"}
+			},
+			err: "the quorum TLS can't be turned on or off",
+		},
+		{
+			name: "quorum TLS turned off",
+			old: func(c *ZookeeperCluster) {
+				c.Spec.TLS = &TLS{Client: true, Quorum: true, SecretName: "zk-tls"}
+			},
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.TLS = &TLS{Client: true, SecretName: "zk-tls"}
+			},
+			err: "the quorum TLS can't be turned on or off",
+		},
+		{
+			name: "client TLS turned on",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.TLS = &TLS{Client: true, SecretName: "zk-tls"}
+			},
+			warning: "changing the TLS settings restarts all the members",
+		},
+		{
+			name: "zkCfg change",
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZkConfig = "tickTime: \"3000\""
+			},
+			warning: "changing the zoo.cfg restarts all the members",
+		},
+		{
+			name:    "scale down by several members",
+			old:     withSize(5),
+			mutate:  withSize(3),
+			warning: "the members are removed one at a time from 5 to 3",
+		},
+		{
+			name: "upgrade to the next line",
+			old: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.5.7"
+			},
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.6.3"
+			},
+			warning: "changing the zookeeper version from 3.5.7 to 3.6.3 restarts all the members",
+		},
+		{
+			name: "upgrade skipping a line",
+			old: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.5.7"
+			},
+			err: "the upgrade from 3.5.7 to 3.8.4 skips a release line; upgrade to 3.6.3 first",
+		},
+		{
+			name: "downgrade to 3.5",
+			old: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.6.3"
+			},
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.5.7"
+			},
+			err: "the downgrade from 3.6.3 to 3.5.7 is not possible",
+		},
+		{
+			name: "downgrade within the snapshot format",
+			old: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.6.3"
+			},
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.6.1"
+			},
+		},
+		{
+			name: "version checked against the rolled back one",
+			old: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.8.4"
+				c.Status.Upgrade = &UpgradeStatus{LastKnownGoodVersion: "3.5.7", RolledBackVersion: "3.8.4"}
+			},
+			mutate: func(c *ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = "3.6.3"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := testCluster(tt.old)
+			c := testCluster(tt.mutate)
+			warnings, err := c.validate(old)
+			checkValidation(t, warnings, err, tt.warning, tt.err)
+		})
+	}
+}
+
+func TestValidateDeletedCluster(t *testing.T) {
+	old := testCluster(nil)
+	c := testCluster(func(c *ZookeeperCluster) {
+		now := metav1.Now()
+		c.DeletionTimestamp = &now
+		c.Spec.ClusterDomain = "example.org"
+	})
+	if _, err := c.validate(old); err != nil {
+		t.Errorf("a deleted cluster is not validated, got %v", err)
+	}
+}
+
+// checkValidation checks the validation failed with the expected error and warned with the expected warning.
+// An empty expectation means no error or no warning of that kind
+func checkValidation(t *testing.T, warnings []string, err error, warning, expectedErr string) {
+	t.Helper()
+	if expectedErr == "" && err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if expectedErr != "" && (err == nil || !strings.Contains(err.Error(), expectedErr)) {
+		t.Errorf("expected an error containing %q, got %v", expectedErr, err)
+	}
+	if warning == "" {
+		return
+	}
+	for _, w := range warnings {
+		if strings.Contains(w, warning) {
+			return
+		}
+	}
+	t.Errorf("expected a warning containing %q, got %q", warning, warnings)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// releaseLine defines a zookeeper release line the operator images are built for
+type releaseLine struct {
+	// name is the major.minor version of the line
+	name string
+	// snapshotFormat is the on-disk format of the snapshots and transaction logs; the members
+	// can't be downgraded to a line which doesn't read the format they wrote
+	snapshotFormat int
+	// versions are the patch versions of the line, mirroring deployments/docker/zookeeper/versions
+	versions []string
+	// deprecation tells why the line shouldn't be used anymore, or is empty
+	deprecation string
+	// configKeys are the zoo.cfg keys introduced by the line; the later lines read them too
+	configKeys []string
+}
+
+// versionMatrix lists the supported release lines in their upgrade order. An upgrade moves to
+// at most the next line; 3.5 clusters go through 3.6 before 3.8
+var versionMatrix = []releaseLine{
+	{
+		name:           "3.5",
+		snapshotFormat: 1,
+		versions:       []string{"3.5.7"},
+		deprecation:    "the 3.5 line reached its end of life",
+		configKeys:     configKeys35,
+	},
+	{
+		name: "3.6",
+		// The snapshots carry a digest the 3.5 members can't read
+		snapshotFormat: 2,
+		versions:       []string{"3.6.1", "3.6.3"},
+		deprecation:    "the 3.6 line reached its end of life",
+		configKeys:     configKeys36,
+	},
+	{
+		name:           "3.8",
+		snapshotFormat: 2,
+		versions:       []string{defaultImageTag},
+		configKeys:     configKeys38,
+	},
+}
+
+// SupportedVersions returns the zookeeper versions the operator images are built for
+func SupportedVersions() []string {
+	var versions []string
+	for _, line := range versionMatrix {
+		versions = append(versions, line.versions...)
+	}
+	return versions
+}
+
+// lookupVersion returns the index of the release line of the supported version, or false if unsupported
+func lookupVersion(version string) (int, bool) {
+	for i, line := range versionMatrix {
+		for _, v := range line.versions {
+			if v == version {
+				return i, true
+			}
+		}
+	}
+	return -1, false
+}
+
+// versionDeprecation returns why the supported version is deprecated, or empty if it isn't
+func versionDeprecation(version string) string {
+	if i, ok := lookupVersion(version); ok && versionMatrix[i].deprecation != "" {
+		return fmt.Sprintf("zookeeper %s is deprecated: %s", version, versionMatrix[i].deprecation)
+	}
+	return ""
+}
+
+// checkVersionChange returns why the members can't move from the version to the other, or empty if
+// they can: an upgrade skipping a release line or a downgrade to a line with an older snapshot format.
+// The changes from an unsupported version aren't checked
+func checkVersionChange(from, to string) string {
+	fromLine, fromOK := lookupVersion(from)
+	toLine, toOK := lookupVersion(to)
+	if !fromOK || !toOK {
+		return ""
+	}
+	switch {
+	case toLine > fromLine+1:
+		var hops []string
+		for _, line := range versionMatrix[fromLine+1 : toLine] {
+			hops = append(hops, line.versions[len(line.versions)-1])
+		}
+		return fmt.Sprintf("the upgrade from %s to %s skips a release line; upgrade to %s first",
+			from, to, strings.Join(hops, " then "))
+	case versionMatrix[toLine].snapshotFormat < versionMatrix[fromLine].snapshotFormat:
+		return fmt.Sprintf("the downgrade from %s to %s is not possible: %s can't read the data written by %s",
+			from, to, versionMatrix[toLine].name, versionMatrix[fromLine].name)
+	}
+	return ""
+}
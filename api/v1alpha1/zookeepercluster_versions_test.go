@@ -0,0 +1,112 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVersionMatrixMatchesTheImages(t *testing.T) {
+	data, err := os.ReadFile("../../deployments/docker/zookeeper/versions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := strings.Fields(string(data))
+	if versions := SupportedVersions(); !reflect.DeepEqual(versions, images) {
+		t.Errorf("the supported versions %v don't match the built images %v", versions, images)
+	}
+	if _, ok := lookupVersion(defaultImageTag); !ok {
+		t.Errorf("the default version %s is not supported", defaultImageTag)
+	}
+	for i := 1; i < len(versionMatrix); i++ {
+		if versionMatrix[i].snapshotFormat < versionMatrix[i-1].snapshotFormat {
+			t.Errorf("the %s line can't upgrade to the %s one", versionMatrix[i-1].name, versionMatrix[i].name)
+		}
+	}
+}
+
+func TestLookupVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		line    string
+	}{
+		{"3.5.7", "3.5"},
+		{"3.6.1", "3.6"},
+		{"3.6.3", "3.6"},
+		{"3.8.4", "3.8"},
+		{"3.6.2", ""},
+		{"3.8", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			i, ok := lookupVersion(tt.version)
+			if tt.line == "" {
+				if ok {
+					t.Errorf("expected %q to be unsupported, got the %s line", tt.version, versionMatrix[i].name)
+				}
+				return
+			}
+			if !ok || versionMatrix[i].name != tt.line {
+				t.Errorf("expected the %s line, got %d, %t", tt.line, i, ok)
+			}
+		})
+	}
+}
+
+func TestVersionDeprecation(t *testing.T) {
+	tests := map[string]bool{
+		"3.5.7": true,
+		"3.6.3": true,
+		"3.8.4": false,
+		"3.4.1": false,
+	}
+	for version, deprecated := range tests {
+		if got := versionDeprecation(version) != ""; got != deprecated {
+			t.Errorf("expected %s deprecated to be %t", version, deprecated)
+		}
+	}
+}
+
+func TestCheckVersionChange(t *testing.T) {
+	tests := []struct {
+		from, to string
+		problem  string
+	}{
+		{"3.5.7", "3.6.1", ""},
+		{"3.6.1", "3.6.3", ""},
+		{"3.6.3", "3.6.1", ""},
+		{"3.6.3", "3.8.4", ""},
+		{"3.8.4", "3.6.3", ""},
+		{"3.5.7", "3.8.4", "skips a release line; upgrade to 3.6.3 first"},
+		{"3.6.1", "3.5.7", "is not possible: 3.5 can't read the data written by 3.6"},
+		{"3.8.4", "3.5.7", "is not possible: 3.5 can't read the data written by 3.8"},
+		{"3.4.14", "3.8.4", ""},
+		{"3.8.4", "3.9.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			problem := checkVersionChange(tt.from, tt.to)
+			if (tt.problem == "") != (problem == "") || !strings.Contains(problem, tt.problem) {
+				t.Errorf("expected the problem %q, got %q", tt.problem, problem)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@
 package v1alpha1
 
 import (
+	"fmt"
 	"github.com/monimesl/operator-helper/config"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -44,27 +45,31 @@ func (in *ZookeeperCluster) Default() {
 	in.SetStatusDefaults()
 }
 
-// TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
-//+kubebuilder:webhook:path=/validate-zookeeper-monime-sl-v1alpha1-zookeepercluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=zookeeper.monime.sl,resources=zookeeperclusters,verbs=create;update,versions=v1alpha1,name=vzookeepercluster.kb.io,admissionReviewVersions={v1,v1beta1}
+//+kubebuilder:webhook:path=/validate-zookeeper-monime-sl-v1alpha1-zookeepercluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=zookeeper.monime.sl,resources=zookeeperclusters,verbs=create;update;delete,versions=v1alpha1,name=vzookeepercluster.kb.io,admissionReviewVersions={v1,v1beta1}
 
 var _ webhook.Validator = &ZookeeperCluster{}
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (in *ZookeeperCluster) ValidateCreate() (admission.Warnings, error) {
 	config.RequireRootLogger().Info("[validate create]", "name", in.Name)
-
-	// TODO(user): fill in your validation logic upon object creation.
-	return admission.Warnings{}, nil
+	return in.validate(nil)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (in *ZookeeperCluster) ValidateUpdate(_ runtime.Object) (admission.Warnings, error) {
+func (in *ZookeeperCluster) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
 	config.RequireRootLogger().Info("[validate update]", "name", in.Name)
-	return admission.Warnings{}, nil
+	oldCluster, ok := old.(*ZookeeperCluster)
+	if !ok {
+		return nil, fmt.Errorf("expected a ZookeeperCluster but got a %T", old)
+	}
+	return in.validate(oldCluster)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
 func (in *ZookeeperCluster) ValidateDelete() (warnings admission.Warnings, err error) {
 	config.RequireRootLogger().Info("[validate delete]", "name", in.Name)
-	return admission.Warnings{}, nil
+	if in.Spec.Persistence != nil && in.Spec.Persistence.ReclaimPolicy == VolumeReclaimPolicyDelete {
+		warnings = append(warnings, "the reclaim policy is Delete; the data volumes are deleted with the cluster")
+	}
+	return warnings, nil
 }
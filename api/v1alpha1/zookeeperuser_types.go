@@ -0,0 +1,135 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionACLsApplied indicates whether the declared ACLs are applied on the ensemble
+	ConditionACLsApplied = "ACLsApplied"
+	// CredentialsDigestKey is the key of the digest scheme id in a generated credentials secret
+	CredentialsDigestKey = "digest"
+)
+
+// ACLPermission defines a znode permission: read, write, create, delete or admin
+// +kubebuilder:validation:Enum="read";"write";"create";"delete";"admin"
+type ACLPermission string
+
+const (
+	ACLPermissionRead   ACLPermission = "read"
+	ACLPermissionWrite  ACLPermission = "write"
+	ACLPermissionCreate ACLPermission = "create"
+	ACLPermissionDelete ACLPermission = "delete"
+	ACLPermissionAdmin  ACLPermission = "admin"
+)
+
+// ZookeeperUserSpec defines the desired state of ZookeeperUser
+type ZookeeperUserSpec struct {
+	// ClusterRef is the name of the ZookeeperCluster, in the same namespace, the user belongs to
+	ClusterRef string `json:"clusterRef"`
+	// Username of the user. Defaults to the object name. It can't contain a colon or a whitespace
+	// since the digest scheme id is <username>:<hash>
+	// +kubebuilder:validation:Pattern=`^[^:\s]+$`
+	// +optional
+	Username string `json:"username,omitempty"`
+	// SecretName is the name of the Secret the generated `username`, `password` and `digest`
+	// are written to. Defaults to <name>-zookeeper-user
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// ACLs are the permissions granted to the user on the znodes; missing znodes are created
+	// +optional
+	ACLs []ACL `json:"acls,omitempty"`
+}
+
+// ACL defines the permissions granted on a znode
+type ACL struct {
+	// Path is the absolute path of the znode
+	// +kubebuilder:validation:Pattern=`^/.*`
+	Path string `json:"path"`
+	// Permissions are the granted permissions
+	// +kubebuilder:validation:MinItems=1
+	Permissions []ACLPermission `json:"permissions"`
+}
+
+// ZookeeperUserStatus defines the observed state of ZookeeperUser
+type ZookeeperUserStatus struct {
+	// ObservedGeneration is the most recent generation observed by the operator
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions are the latest observations of the user state
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// SecretName is the name of the Secret holding the user credentials
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// Username is the username the ACLs are applied for
+	// +optional
+	Username string `json:"username,omitempty"`
+	// ACLs are the permissions applied on the ensemble; they're revoked when removed from the spec
+	// +optional
+	ACLs []ACL `json:"acls,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterRef`
+// +kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+// +kubebuilder:printcolumn:name="Applied",type=string,JSONPath=`.status.conditions[?(@.type=="ACLsApplied")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ZookeeperUser is the Schema for the zookeeperusers API
+type ZookeeperUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZookeeperUserSpec   `json:"spec,omitempty"`
+	Status ZookeeperUserStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ZookeeperUserList contains a list of ZookeeperUser
+type ZookeeperUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ZookeeperUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ZookeeperUser{}, &ZookeeperUserList{})
+}
+
+// Username returns the name the user authenticates with
+func (in *ZookeeperUser) Username() string {
+	if in.Spec.Username != "" {
+		return in.Spec.Username
+	}
+	return in.GetName()
+}
+
+// SecretName returns the name of the Secret holding the user credentials
+func (in *ZookeeperUser) SecretName() string {
+	if in.Spec.SecretName != "" {
+		return in.Spec.SecretName
+	}
+	return fmt.Sprintf("%s-zookeeper-user", in.GetName())
+}
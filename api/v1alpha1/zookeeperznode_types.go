@@ -0,0 +1,152 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionZNodeReady indicates whether the znode, its ACLs and quota are in the declared state
+	ConditionZNodeReady = "Ready"
+)
+
+// ZNodeDeletionPolicy defines what happens to the znode subtree when the ZookeeperZNode is deleted
+type ZNodeDeletionPolicy string
+
+const (
+	// ZNodeDeletionPolicyRetain keeps the znode subtree
+	ZNodeDeletionPolicyRetain ZNodeDeletionPolicy = "Retain"
+	// ZNodeDeletionPolicyDelete removes the znode subtree and its quota
+	ZNodeDeletionPolicyDelete ZNodeDeletionPolicy = "Delete"
+)
+
+// ZookeeperZNodeSpec defines the desired state of ZookeeperZNode
+type ZookeeperZNodeSpec struct {
+	// ClusterRef is the name of the ZookeeperCluster, in the same namespace, the znode is created in
+	ClusterRef string `json:"clusterRef"`
+	// Path is the absolute path of the znode; the missing parents are created. It can't be changed
+	// +kubebuilder:validation:Pattern=`^/[^/].*$`
+	Path string `json:"path"`
+	// Data is the initial data of the znode; it's only set when the znode is created
+	// +optional
+	Data string `json:"data,omitempty"`
+	// ACLs are the ACL entries of the znode. The world ACL is used if not set. Only the declared
+	// entries are managed; the others, like the ZookeeperUser grants, are kept
+	// +optional
+	ACLs []ZNodeACL `json:"acls,omitempty"`
+	// Quota sets the limits of the znode subtree
+	// +optional
+	Quota *ZNodeQuota `json:"quota,omitempty"`
+	// DeletionPolicy defines whether the znode subtree is deleted with the object. Defaults to Retain
+	// +kubebuilder:validation:Enum="Retain";"Delete"
+	// +optional
+	DeletionPolicy ZNodeDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// ZNodeACL defines an ACL entry of a znode
+type ZNodeACL struct {
+	// Scheme is the authentication scheme of the entry
+	// +kubebuilder:validation:Enum="world";"auth";"digest";"ip";"sasl";"x509"
+	Scheme string `json:"scheme"`
+	// ID is the id in the scheme format. e.g. `anyone` for world or the `digest`
+	// of a ZookeeperUser secret for digest
+	ID string `json:"id"`
+	// Permissions are the granted permissions
+	// +kubebuilder:validation:MinItems=1
+	Permissions []ACLPermission `json:"permissions"`
+}
+
+// ZNodeQuota defines the limits of a znode subtree. Zookeeper logs a warning when they're exceeded
+type ZNodeQuota struct {
+	// Count is the maximum number of znodes in the subtree
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Count *int64 `json:"count,omitempty"`
+	// Bytes is the maximum size of the subtree data
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Bytes *int64 `json:"bytes,omitempty"`
+}
+
+// ZNodeUsage defines the usage of a znode subtree as tracked by the quota
+type ZNodeUsage struct {
+	// Count is the number of znodes in the subtree
+	Count int64 `json:"count"`
+	// Bytes is the size of the subtree data
+	Bytes int64 `json:"bytes"`
+}
+
+// ZookeeperZNodeStatus defines the observed state of ZookeeperZNode
+type ZookeeperZNodeStatus struct {
+	// ObservedGeneration is the most recent generation observed by the operator
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions are the latest observations of the znode state
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Path is the path of the created znode
+	// +optional
+	Path string `json:"path,omitempty"`
+	// ACLs are the applied ACL entries; they're removed from the znode once no longer declared
+	// +optional
+	ACLs []ZNodeACL `json:"acls,omitempty"`
+	// Quota is the applied quota
+	// +optional
+	Quota *ZNodeQuota `json:"quota,omitempty"`
+	// Usage is the usage of the subtree versus the quota
+	// +optional
+	Usage *ZNodeUsage `json:"usage,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterRef`
+// +kubebuilder:printcolumn:name="Path",type=string,JSONPath=`.spec.path`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Count",type=integer,JSONPath=`.status.usage.count`
+// +kubebuilder:printcolumn:name="Bytes",type=integer,JSONPath=`.status.usage.bytes`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ZookeeperZNode is the Schema for the zookeeperznodes API
+type ZookeeperZNode struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZookeeperZNodeSpec   `json:"spec,omitempty"`
+	Status ZookeeperZNodeStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ZookeeperZNodeList contains a list of ZookeeperZNode
+type ZookeeperZNodeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ZookeeperZNode `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ZookeeperZNode{}, &ZookeeperZNodeList{})
+}
+
+// ShouldDeleteSubtree returns whether the znode subtree is deleted with the object
+func (in *ZookeeperZNode) ShouldDeleteSubtree() bool {
+	return in.Spec.DeletionPolicy == ZNodeDeletionPolicyDelete
+}
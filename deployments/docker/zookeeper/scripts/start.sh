@@ -20,8 +20,6 @@ echo "Starting zookeeper setup..."
 
 source /scripts/common.sh
 
-#enable job control
-set -m
 set -ex
 
 mkdir -p "$CONFIG_DIR"
@@ -34,72 +32,59 @@ else
   exit 1
 fi
 
-MYID_FILE_PRESENT=false
-DYNAMIC_CONFIG_FILE_PRESENT=false
-
-if [ -f "$MYID_FILE" ]; then
-  CURRENT_ID=$(cat "$MYID_FILE")
-  if [[ "$CURRENT_ID" == "$MYID" && -f $STATIC_CONFIG_FILE ]]; then
-    # If Id is correct and configuration is present under `/data/conf`
-    MYID_FILE_PRESENT=true
-  fi
+if [[ -n "$DATA_LOG_DIR" && "$DATA_LOG_DIR" != "$DATA_DIR" ]] && compgen -G "$DATA_DIR/version-2/log.*" >/dev/null; then
+  # The transaction logs were kept with the snapshots before they got their own volume; zookeeper
+  # refuses to start with logs in the data dir when the data log dir is set apart
+  echo "Moving the transaction logs from $DATA_DIR to $DATA_LOG_DIR"
+  mkdir -p "$DATA_LOG_DIR/version-2"
+  mv -f "$DATA_DIR"/version-2/log.* "$DATA_LOG_DIR/version-2/"
 fi
 
-if [ -f "$DYNAMIC_CONFIG_FILE" ]; then
-  set +e
-  # shellcheck disable=SC2002
-  cat "$DYNAMIC_CONFIG_FILE" | grep -q "server.${MYID}="
-  # shellcheck disable=SC2181
-  if [[ $? -eq 0 ]]; then
-    DYNAMIC_CONFIG_FILE_PRESENT=true
-  fi
-fi
+echo "Writing myid: $MYID to: $MYID_FILE"
+echo $MYID >"$MYID_FILE"
 
-ADD_NODE=true
+# Zookeeper writes the reconfigured ensemble to a new zoo.cfg.dynamic.<version> it points the persisted static
+# config to; the member restarts from the latest ensemble it knows since the static config is replaced below
+LAST_DYNAMIC_CONFIG_FILE=$(sed -n "s/^dynamicConfigFile=//p" "$STATIC_CONFIG_FILE" 2>/dev/null || true)
+if [[ -n "$LAST_DYNAMIC_CONFIG_FILE" && "$LAST_DYNAMIC_CONFIG_FILE" != "$DYNAMIC_CONFIG_FILE" && -f "$LAST_DYNAMIC_CONFIG_FILE" ]]; then
+  echo "Restoring the last dynamic config $LAST_DYNAMIC_CONFIG_FILE to $DYNAMIC_CONFIG_FILE"
+  cp -f "$LAST_DYNAMIC_CONFIG_FILE" "$DYNAMIC_CONFIG_FILE"
+fi
 
-set +e
-checkEnsemblePresence $MYID
-# shellcheck disable=SC2181
-if [[ $? -ne 0 ]]; then
-  echo "Couldn't detect an ensemble; this may be the first node or the ensemble service in unavailable"
-  ADD_NODE=false
-elif [[ "$MYID_FILE_PRESENT" == true && "$DYNAMIC_CONFIG_FILE_PRESENT" == true ]]; then
-  echo "This node is already a member of the ensemble"
-  ADD_NODE=false
+# The operator manages the ensemble membership; it supplies the dynamic config listing this member, as an
+# observer until it's promoted to a participant once caught up with the leader. It only seeds the members
+# without one, e.g. new or with an ephemeral storage, or whose one no longer lists them since they were removed
+if [[ ! -f "$DYNAMIC_CONFIG_FILE" ]] || ! grep -q "^server\.$MYID=" "$DYNAMIC_CONFIG_FILE"; then
+  echo "Copying the operator supplied dynamic config to $DYNAMIC_CONFIG_FILE"
+  cp -f /config/zoo.cfg.dynamic "$DYNAMIC_CONFIG_FILE"
 fi
-set -e
 
-SERVER_CONFIG=""
-if [[ "$MYID_FILE_PRESENT" == false || "$DYNAMIC_CONFIG_FILE_PRESENT" == false ]]; then
-  echo "Node configuration is missing; writing myid: $MYID to: $MYID_FILE"
-  echo $MYID >"$MYID_FILE"
-  if [[ $MYID -eq 1 ]]; then
-    ADD_NODE=false
-    echo "I'm the first server pod in the statefulset. Generating my dynamic config..."
-    SERVER_CONFIG="server.${MYID}=$(zkServerConfig participant)"
-    echo "Writing my dynamic configuration to $DYNAMIC_CONFIG_FILE"
-    echo "$SERVER_CONFIG"
-    echo "$SERVER_CONFIG" >"$DYNAMIC_CONFIG_FILE"
-  else
-    echo "I'm a subsequent server pod in the statefulset. Retrieving the current ensemble config..."
-    ZK_URL=$(zkClientUrl)
-    SERVER_CONFIG="server.${MYID}=$(zkServerConfig observer)"
-    DYNAMIC_CONFIG=$(zk-shell "$ZK_URL" --run-once "get /zookeeper/config" | cat | head -n -1)
-    if [[ $DYNAMIC_CONFIG == Failed* ]]; then
-      echo "Unable to read the current ensemble configuration to generate this node configuration file"
-      exit 1
-    fi
-    DYNAMIC_CONFIG+="\n$SERVER_CONFIG"
-    echo "Writing my dynamic configuration to $DYNAMIC_CONFIG_FILE"
-    echo -e "$DYNAMIC_CONFIG"
-    echo -e "$DYNAMIC_CONFIG" >"$DYNAMIC_CONFIG_FILE"
+if [[ "$EPHEMERAL_STORAGE" == "true" && ! -d "$DATA_DIR/version-2" ]]; then
+  # The ephemeral member lost its data with its pod and starts as a fresh node. It waits for another member
+  # to serve so it syncs from the ensemble leader instead of electing one with the other fresh members;
+  # a new ensemble, or one whose members were all recreated, has none and starts after the wait. The peers are
+  # probed on the plain client port the dynamic config lists; without it, the member starts right away
+  PEERS=$(grep -E "^server\.[0-9]+=.*:participant;" "$DYNAMIC_CONFIG_FILE" | grep -v "^server\.$MYID=" |
+    sed -E "s/^server\.[0-9]+=([^:]+):.*;([0-9]+)$/\1:\2/" || true)
+  if [[ -n "$PEERS" ]]; then
+    echo "Waiting up to ${PEER_WAIT_SECONDS:=60}s for a serving member to rejoin the ensemble"
+    set +x
+    for ((i = 0; i < PEER_WAIT_SECONDS; i += 2)); do
+      for PEER in $PEERS; do
+        if echo srvr | nc "${PEER%:*}" "${PEER##*:}" 2>/dev/null | grep -q "^Mode: "; then
+          echo "The member $PEER is serving; rejoining the ensemble as a fresh node"
+          break 2
+        fi
+      done
+      sleep 2
+    done
+    set -x
   fi
 fi
 
-if [[ ! -f $STATIC_CONFIG_FILE ]]; then
-  echo "The static config file does not exists. copying /conf/zoo.cfg to $CONFIG_DIR"
-  cp -f /config/zoo.cfg "$CONFIG_DIR"
-fi
+# Zookeeper rewrites the persisted static config on reconfig; it's replaced so the operator supplied one is used
+echo "Copying the operator supplied static config to $STATIC_CONFIG_FILE"
+cp -f /config/zoo.cfg "$STATIC_CONFIG_FILE"
 
 cp -f /config/logback.xml "$CONFIG_DIR"
 
@@ -107,45 +92,44 @@ echo -e "\n$STATIC_CONFIG_FILE: \n"
 cat "$STATIC_CONFIG_FILE"
 echo -e "\n$DYNAMIC_CONFIG_FILE: \n"
 cat "$DYNAMIC_CONFIG_FILE"
-echo -e "\nStarting the zookeeper service in the background"
+echo -e "\nStarting the zookeeper service"
 ZK_SERVER_HEAP="${ZK_SERVER_HEAP:-500}"
 SERVER_JVMFLAGS="${SERVER_JVMFLAGS:-""}"
-export ZK_SERVER_HEAP SERVER_JVMFLAGS
-/zk/bin/zkServer.sh --config "$CONFIG_DIR" start-foreground &
-SERVICE_PID=$!
-SERVICE_JOB=$(jobs -l | grep $SERVICE_PID | cut -d"[" -f2 | cut -d"]" -f1)
-
-if [[ "$ADD_NODE" == false ]]; then
-  createNodeReadinessFile
-  # put the process back into foreground
-  fg "$SERVICE_JOB"
-else
-  set +e
-  sleep 3
-  /scripts/preAddNodeCheck.sh $SERVICE_PID
-  # shellcheck disable=SC2181
-  if [[ $? -eq 0 ]]; then
-    set -e
-    echo "Adding the node to the ensemble"
-    ZK_URL=$(zkClientUrl)
-    ROLE=participant
-    REMOTE_SERVER_CONFIG="server.${MYID}=$(zkServerConfig $ROLE true)"
-    DYNAMIC_CONFIG=$(zk-shell "$ZK_URL" --run-once "reconfig add $REMOTE_SERVER_CONFIG")
-    if ! echo "$DYNAMIC_CONFIG" | grep -q "server.${MYID}="; then
-      echo "Unable to add the node to the ensemble. See error below:"
-      if [[ "$MYID_FILE_PRESENT" == false || "$DYNAMIC_CONFIG_FILE_PRESENT" == false ]]; then
-        # Unable to setup the node, so we do a clean up for the next retry
-        rm -f "$MYID_FILE" "$DYNAMIC_CONFIG_FILE"
-      fi
-      echo "$DYNAMIC_CONFIG"
-      exit 1
-    else
-      createNodeReadinessFile
-      echo "The node is successfully added to the ensemble"
-      # put the process back into foreground
-      fg "$SERVICE_JOB"
-    fi
-  else
-    exit 1
-  fi
+if [[ -f /tls/password ]]; then
+  # The operator generated key stores password is appended to the copied zoo.cfg, after it's printed, so
+  # it's neither in the configmap nor on the command line; zookeeper reads the ssl.* keys of any version
+  set +x
+  chmod 600 "$STATIC_CONFIG_FILE"
+  SSL_PASSWORD=$(cat /tls/password)
+  for PREFIX in ssl ssl.quorum; do
+    echo "$PREFIX.keyStore.password=$SSL_PASSWORD" >>"$STATIC_CONFIG_FILE"
+    echo "$PREFIX.trustStore.password=$SSL_PASSWORD" >>"$STATIC_CONFIG_FILE"
+  done
+  unset SSL_PASSWORD
+  set -x
+fi
+if [[ -n "$JUTE_MAXBUFFER" ]]; then
+  # Zookeeper only reads it as a system property
+  SERVER_JVMFLAGS+=" -Djute.maxbuffer=$JUTE_MAXBUFFER"
+fi
+if [[ -f /config/jaas.conf ]]; then
+  SERVER_JVMFLAGS+=" -Djava.security.auth.login.config=/config/jaas.conf"
 fi
+if [[ -n "$ZK_KERBEROS_PRINCIPAL" ]]; then
+  SERVER_JVMFLAGS+=" -Dzookeeper.kerberos.principal=${ZK_KERBEROS_PRINCIPAL//_HOST/$POD_LONG_NAME}"
+fi
+if [[ -f /krb5/krb5.conf ]]; then
+  SERVER_JVMFLAGS+=" -Djava.security.krb5.conf=/krb5/krb5.conf"
+fi
+if [[ -n "$ZK_SUPER_DIGEST" ]]; then
+  # The operator credentials are granted the super user so they keep managing the ensemble when the ACLs are
+  # enforced. Zookeeper sets the zoo.cfg keys it doesn't know as zookeeper.<key> system properties, so the
+  # digest is appended to the copied zoo.cfg instead of being on the command line
+  set +x
+  chmod 600 "$STATIC_CONFIG_FILE"
+  echo "DigestAuthenticationProvider.superDigest=$ZK_SUPER_DIGEST" >>"$STATIC_CONFIG_FILE"
+  set -x
+fi
+export ZK_SERVER_HEAP SERVER_JVMFLAGS
+createNodeReadinessFile
+exec /zk/bin/zkServer.sh --config "$CONFIG_DIR" start-foreground
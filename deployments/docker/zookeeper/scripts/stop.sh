@@ -20,39 +20,10 @@ source /scripts/common.sh
 
 set -x +e
 
-MYID=$(cat "$MYID_FILE")
-ZK_URL=$(zkClientUrl)
-
 echo "Removing the node readiness file: $NODE_READY_FILE"
 rm -f "$NODE_READY_FILE"
 
-echo "Syncing and fetching the size of the cluster $CLUSTER_NAME"
-SIZE=""
-for ((i = 0; i < 15; i++)); do
-  SYNC=$(zk-shell "$ZK_URL" --run-once "sync $CLUSTER_META_SIZE_NODE_PATH")
-  if [[ -z "${SYNC}" ]]; then
-    SIZE=$(zk-shell "$ZK_URL" --run-once "get $CLUSTER_META_SIZE_NODE_PATH")
-    break
-  fi
-  echo "Failed to connect. Retrying($i) after 2 seconds"
-  SIZE=""
-done
-
-echo "Cluster current SIZE=$SIZE, myid=$MYID"
-
-# Since we're using kubernetes statefulset to start the node in an ordered fashion,
-# the cluster size at any arbitrary normal point in time equals the highest `myid`.
-# which is 1 increment of the ordinal of the pod running the container. On cluster
-# down scaling($SIZE reduction), the pod with the highest ordinal hence `myid` is deleted.
-# This means any node whose `myid` is greater than the current cluster size is being
-# permanently removed from the ensemble
-if [[ -n "$SIZE" && "$MYID" -gt "$SIZE" ]]; then
-  echo "Removing the node with id $MYID from the cluster: $CLUSTER_NAME"
-  zk-shell "$ZK_URL" --run-once "reconfig remove $MYID"
-  # Ensure a quorum has activated the new configuration.
-  # See `Progress guarantees` https://zookeeper.apache.org/doc/r3.6.3/zookeeperReconfig.html#ch_reconfig_dyn
-  zk-shell "$ZK_URL" --run-once "set $CLUSTER_META_UPDATE_TIME_NODE_PATH '$(($(date +%s%N) / 1000000))'"
-fi
+# The operator removes the leaving members from the ensemble before they're stopped
 
 # Wait the server to drain it's remote client connections
 echo "Waiting the server to drain it's remote client connections"
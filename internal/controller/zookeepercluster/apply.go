@@ -0,0 +1,71 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// csaFieldManagers are the field managers of the Create and Update calls the objects were made with before
+// the operator applied them; "manager" is the name the api server derives from the operator binary
+var csaFieldManagers = sets.New("manager", "before-first-apply", internal.OperatorName)
+
+// applyObject applies the desired state of the object owned by the cluster with server-side apply.
+// The fields the operator applied before and left out of the desired state are removed, while the
+// ones set by the other managers are kept. It returns whether the live object, nil if not found,
+// was created or changed
+func applyObject(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, desired, live client.Object) (bool, error) {
+	liveVersion := ""
+	if live != nil {
+		if err := upgradeManagedFields(ctx, live); err != nil {
+			return false, err
+		}
+		liveVersion = live.GetResourceVersion()
+	}
+	if err := controllerutil.SetControllerReference(c, desired, ctx.Scheme()); err != nil {
+		return false, err
+	}
+	if err := ctx.Client().Patch(context.TODO(), desired, client.Apply,
+		client.FieldOwner(internal.OperatorName), client.ForceOwnership); err != nil {
+		return false, err
+	}
+	return desired.GetResourceVersion() != liveVersion, nil
+}
+
+// upgradeManagedFields hands the fields set by the Create and Update calls over to the apply field
+// manager once, so the ones later left out of the desired state are removed instead of kept
+func upgradeManagedFields(ctx reconciler.Context, live client.Object) error {
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(live, csaFieldManagers, internal.OperatorName)
+	if err != nil || patch == nil {
+		return err
+	}
+	ctx.Logger().Info("Migrating the object fields to the server-side apply field manager",
+		"kind", fmt.Sprintf("%T", live), "name", live.GetName(), "namespace", live.GetNamespace())
+	if err = ctx.Client().Patch(context.TODO(), live, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+		return fmt.Errorf("error migrating the managed fields of %s: %w", live.GetName(), err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func testApplyService(labels map[string]string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "zk-client", Namespace: "default", Labels: labels},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Name: "tcp-client", Port: 2181}},
+		},
+	}
+}
+
+func getApplyService(t *testing.T, ctx *reconcilertest.Context) *v1.Service {
+	t.Helper()
+	svc := &v1.Service{}
+	if err := ctx.Client().Get(context.TODO(), types.NamespacedName{Name: "zk-client", Namespace: "default"}, svc); err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestApplyObject(t *testing.T) {
+	c := testConfigCluster(nil)
+	ctx := reconcilertest.NewApplyContext(c)
+	changed, err := applyObject(ctx, c, testApplyService(map[string]string{"app": "zk", "tier": "zookeeper"}), nil)
+	if err != nil || !changed {
+		t.Fatalf("expected the service to be created, got %t, %v", changed, err)
+	}
+	svc := getApplyService(t, ctx)
+	if owner := metav1.GetControllerOf(svc); owner == nil || owner.Name != c.Name {
+		t.Errorf("expected the service to be controlled by the cluster, got %v", owner)
+	}
+
+	// another manager labels the service
+	svc.Labels["team"] = "storage"
+	if err = ctx.Client().Update(context.TODO(), svc, client.FieldOwner("kubectl-edit")); err != nil {
+		t.Fatal(err)
+	}
+	svc = getApplyService(t, ctx)
+	changed, err = applyObject(ctx, c, testApplyService(map[string]string{"app": "zk", "tier": "zookeeper"}), svc)
+	if err != nil || changed {
+		t.Fatalf("expected the unchanged service to be left as is, got %t, %v", changed, err)
+	}
+
+	// the label left out of the desired state is removed, the other manager's one is kept
+	changed, err = applyObject(ctx, c, testApplyService(map[string]string{"app": "zk"}), svc)
+	if err != nil || !changed {
+		t.Fatalf("expected the service to be changed, got %t, %v", changed, err)
+	}
+	expected := map[string]string{"app": "zk", "team": "storage"}
+	if labels := getApplyService(t, ctx).Labels; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected the labels %v, got %v", expected, labels)
+	}
+}
+
+func TestApplyObjectMigratesTheClientSideFields(t *testing.T) {
+	c := testConfigCluster(nil)
+	ctx := reconcilertest.NewApplyContext(c)
+	// the service created by the operator before it applied the objects
+	if err := ctx.Client().Create(context.TODO(), testApplyService(map[string]string{"app": "zk", "legacy": "true"})); err != nil {
+		t.Fatal(err)
+	}
+	svc := getApplyService(t, ctx)
+	svc.Labels["team"] = "storage"
+	if err := ctx.Client().Update(context.TODO(), svc, client.FieldOwner("kubectl-edit")); err != nil {
+		t.Fatal(err)
+	}
+	svc = getApplyService(t, ctx)
+	changed, err := applyObject(ctx, c, testApplyService(map[string]string{"app": "zk"}), svc)
+	if err != nil || !changed {
+		t.Fatalf("expected the service to be changed, got %t, %v", changed, err)
+	}
+	svc = getApplyService(t, ctx)
+	expected := map[string]string{"app": "zk", "team": "storage"}
+	if !reflect.DeepEqual(svc.Labels, expected) {
+		t.Errorf("expected the labels %v, got %v", expected, svc.Labels)
+	}
+	for _, entry := range svc.ManagedFields {
+		if entry.Operation == metav1.ManagedFieldsOperationUpdate && csaFieldManagers.Has(entry.Manager) {
+			t.Errorf("expected the client-side fields to be migrated, got %v", entry)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/secret"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+)
+
+const (
+	jaasConfigKey         = "jaas.conf"
+	superDigestKey        = "superDigest"
+	keytabVolume          = "keytab"
+	keytabMountPath       = "/keytab"
+	krb5Volume            = "krb5"
+	krb5MountPath         = "/krb5"
+	superDigestEnv        = "ZK_SUPER_DIGEST"
+	kerberosPrincipalEnv  = "ZK_KERBEROS_PRINCIPAL"
+	digestLoginModule     = "org.apache.zookeeper.server.auth.DigestLoginModule"
+	kerberosLoginModule   = "com.sun.security.auth.module.Krb5LoginModule"
+	saslAuthProviderClass = "org.apache.zookeeper.server.auth.SASLAuthenticationProvider"
+)
+
+// authHashAnnotation records on the pod template the hash of the credentials the members run with
+var authHashAnnotation = fmt.Sprintf("%s/auth-hash", internal.Domain)
+
+// ReconcileAuthentication reconcile the secret holding the JAAS configuration
+// and the super user digest of the specified cluster
+func ReconcileAuthentication(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error {
+	if !cluster.DeletionTimestamp.IsZero() || (!cluster.IsSaslEnabled() && cluster.OperatorCredentialsSecretName() == "") {
+		return nil
+	}
+	if !cluster.IsACLSkipped() && cluster.OperatorCredentialsSecretName() == "" {
+		return fmt.Errorf("the cluster (%s) can not turn off skipACL without the operator credentials", cluster.Name)
+	}
+	data, sourceHash, err := createAuthSecretData(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if err = reconcileAuthSecret(ctx, cluster, data); err != nil {
+		return err
+	}
+	if cluster.Status.AuthenticationHash == sourceHash {
+		return nil
+	}
+	ctx.Logger().Info("The cluster authentication settings changed",
+		"cluster", cluster.Name)
+	if cluster.Status.AuthenticationHash != "" {
+		recordEvent(ctx, cluster, v1.EventTypeNormal, eventReasonAuthenticationUpdated,
+			"The authentication settings changed; restarting the members")
+	}
+	cluster.Status.AuthenticationHash = sourceHash
+	return ctx.Client().Status().Update(context.TODO(), cluster)
+}
+
+func reconcileAuthSecret(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster, data map[string][]byte) error {
+	s := &v1.Secret{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      cluster.AuthSecretName(),
+		Namespace: cluster.Namespace,
+	}, s,
+		// Found
+		func() error {
+			if hashSecretData(s.Data) == hashSecretData(data) && s.Labels[internal.WatchedSecretLabel] != "" {
+				return nil
+			}
+			s.Data = data
+			s.Labels = internal.MergeLabels(s.Labels, internal.WatchedSecretLabels())
+			ctx.Logger().Info("Updating the zookeeper authentication secret.",
+				"Secret.Name", s.GetName(),
+				"Secret.Namespace", s.GetNamespace())
+			return ctx.Client().Update(context.TODO(), s)
+		},
+		// Not Found
+		func() error {
+			s = secret.New(cluster.Namespace, cluster.AuthSecretName(), data)
+			s.Labels = internal.MergeLabels(cluster.GenerateLabels(), internal.WatchedSecretLabels())
+			if err := ctx.SetOwnershipReference(cluster, s); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the zookeeper authentication secret.",
+				"Secret.Name", s.GetName(),
+				"Secret.Namespace", s.GetNamespace())
+			return ctx.Client().Create(context.TODO(), s)
+		})
+}
+
+// createAuthSecretData creates the JAAS configuration and the super user digest from the
+// referenced credentials. The returned hash also covers the kerberos keytab so the members
+// are restarted when any of the credentials change
+func createAuthSecretData(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster) (map[string][]byte, string, error) {
+	auth := c.Spec.Authentication
+	data := map[string][]byte{}
+	if auth.OperatorCredentialsSecret != "" {
+		username, password, err := getCredentials(ctx, c.Namespace, auth.OperatorCredentialsSecret)
+		if err != nil {
+			return nil, "", err
+		}
+		data[superDigestKey] = []byte(zk.Digest(username, password))
+	}
+	var sections []string
+	if c.IsQuorumSaslEnabled() {
+		username, password, err := getCredentials(ctx, c.Namespace, auth.Quorum.CredentialsSecret)
+		if err != nil {
+			return nil, "", err
+		}
+		sections = append(sections,
+			jaasSection("QuorumServer", digestLoginModule, fmt.Sprintf("user_%s=%s", username, jaasQuote(password))),
+			jaasSection("QuorumLearner", digestLoginModule,
+				fmt.Sprintf("username=%s", jaasQuote(username)), fmt.Sprintf("password=%s", jaasQuote(password))))
+	}
+	var keytab []byte
+	if c.IsClientSaslEnabled() {
+		switch auth.Client.Mechanism {
+		case v1alpha1.SASLMechanismGSSAPI:
+			if auth.Client.Kerberos == nil {
+				return nil, "", fmt.Errorf("the cluster (%s) GSSAPI authentication requires the kerberos settings", c.Name)
+			}
+			s, err := getSecret(ctx, c.Namespace, auth.Client.Kerberos.KeytabSecret)
+			if err != nil {
+				return nil, "", err
+			}
+			keytab = s.Data[v1alpha1.KeytabKey]
+			// The principal is resolved per member by the start script
+			sections = append(sections, jaasSection("Server", kerberosLoginModule,
+				"useKeyTab=true", fmt.Sprintf("keyTab=%s", jaasQuote(keytabMountPath+"/"+v1alpha1.KeytabKey)),
+				"storeKey=true", "useTicketCache=false", `principal="${zookeeper.kerberos.principal}"`))
+		default:
+			users, err := getSecret(ctx, c.Namespace, auth.Client.UsersSecret)
+			if err != nil {
+				return nil, "", err
+			}
+			names := make([]string, 0, len(users.Data))
+			for name := range users.Data {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			options := make([]string, 0, len(names))
+			for _, name := range names {
+				options = append(options, fmt.Sprintf("user_%s=%s", name, jaasQuote(string(users.Data[name]))))
+			}
+			sections = append(sections, jaasSection("Server", digestLoginModule, options...))
+		}
+	}
+	if len(sections) > 0 {
+		data[jaasConfigKey] = []byte(strings.Join(sections, "\n"))
+	}
+	return data, hashData(data[jaasConfigKey], data[superDigestKey], keytab), nil
+}
+
+// createAuthConfig creates the zoo.cfg entries enabling the cluster authentication
+func createAuthConfig(c *v1alpha1.ZookeeperCluster) map[string]string {
+	cfg := map[string]string{}
+	if c.IsQuorumSaslEnabled() {
+		cfg["quorum.auth.enableSasl"] = "true"
+		cfg["quorum.auth.learnerRequireSasl"] = "true"
+		cfg["quorum.auth.serverRequireSasl"] = "true"
+		cfg["quorum.auth.learner.saslLoginContext"] = "QuorumLearner"
+		cfg["quorum.auth.server.saslLoginContext"] = "QuorumServer"
+	}
+	if c.IsClientSaslEnabled() {
+		cfg["authProvider.sasl"] = saslAuthProviderClass
+		for key, value := range c.ClientAuthEnforcementConfig() {
+			cfg[key] = value
+		}
+		if c.Spec.Authentication.Client.Mechanism == v1alpha1.SASLMechanismGSSAPI {
+			cfg["kerberos.removeHostFromPrincipal"] = "true"
+			cfg["kerberos.removeRealmFromPrincipal"] = "true"
+		}
+	}
+	return cfg
+}
+
+// authPodAnnotations returns the pod annotations which restart the members when the credentials change
+func authPodAnnotations(c *v1alpha1.ZookeeperCluster) map[string]string {
+	if c.Status.AuthenticationHash == "" {
+		return nil
+	}
+	return map[string]string{authHashAnnotation: c.Status.AuthenticationHash}
+}
+
+// createAuthVolumes creates the volumes and mounts of the kerberos keytab and krb5.conf
+func createAuthVolumes(c *v1alpha1.ZookeeperCluster) ([]v1.Volume, []v1.VolumeMount) {
+	if !c.IsClientSaslEnabled() || c.Spec.Authentication.Client.Kerberos == nil {
+		return nil, nil
+	}
+	kerberos := c.Spec.Authentication.Client.Kerberos
+	volumes := []v1.Volume{{
+		Name: keytabVolume,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: kerberos.KeytabSecret},
+		},
+	}}
+	mounts := []v1.VolumeMount{{Name: keytabVolume, MountPath: keytabMountPath, ReadOnly: true}}
+	if kerberos.Krb5ConfigMap != "" {
+		volumes = append(volumes, v1.Volume{
+			Name: krb5Volume,
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: kerberos.Krb5ConfigMap},
+				},
+			},
+		})
+		mounts = append(mounts, v1.VolumeMount{Name: krb5Volume, MountPath: krb5MountPath, ReadOnly: true})
+	}
+	return volumes, mounts
+}
+
+// createAuthEnvVars creates the environment variables the start script configures the authentication with
+func createAuthEnvVars(c *v1alpha1.ZookeeperCluster) []v1.EnvVar {
+	var env []v1.EnvVar
+	if c.OperatorCredentialsSecretName() != "" {
+		env = append(env, secretEnvVar(superDigestEnv, c.AuthSecretName(), superDigestKey))
+	}
+	if c.IsClientSaslEnabled() && c.Spec.Authentication.Client.Kerberos != nil {
+		env = append(env, v1.EnvVar{Name: kerberosPrincipalEnv, Value: c.Spec.Authentication.Client.Kerberos.Principal})
+	}
+	return env
+}
+
+func secretEnvVar(name, secretName, key string) v1.EnvVar {
+	return v1.EnvVar{
+		Name: name,
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+				Key:                  key,
+			},
+		},
+	}
+}
+
+func getSecret(ctx reconciler.Context, namespace, name string) (*v1.Secret, error) {
+	s := &v1.Secret{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, s)
+	if errors.IsNotFound(err) {
+		return nil, fmt.Errorf("the secret (%s) referenced by the cluster authentication is not found", name)
+	} else if err != nil {
+		return nil, err
+	}
+	return s, watchSecret(ctx, s)
+}
+
+// watchSecret labels the referenced secret so the operator caches it and its changes reach the clusters
+func watchSecret(ctx reconciler.Context, s *v1.Secret) error {
+	if s.Labels[internal.WatchedSecretLabel] != "" {
+		return nil
+	}
+	patch := client.MergeFrom(s.DeepCopy())
+	s.Labels = internal.MergeLabels(s.Labels, internal.WatchedSecretLabels())
+	if err := ctx.Client().Patch(context.TODO(), s, patch); err != nil {
+		return fmt.Errorf("error labelling the secret (%s) to watch it: %w", s.Name, err)
+	}
+	return nil
+}
+
+func getCredentials(ctx reconciler.Context, namespace, name string) (username, password string, err error) {
+	s, err := getSecret(ctx, namespace, name)
+	if err != nil {
+		return "", "", err
+	}
+	username = string(s.Data[v1alpha1.CredentialsUsernameKey])
+	password = string(s.Data[v1alpha1.CredentialsPasswordKey])
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("the credentials secret (%s) requires a username and a password", name)
+	}
+	return username, password, nil
+}
+
+func jaasSection(name, loginModule string, options ...string) string {
+	return fmt.Sprintf("%s {\n  %s required\n  %s;\n};\n", name, loginModule, strings.Join(options, "\n  "))
+}
+
+func jaasQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+func hashSecretData(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([][]byte, 0, 2*len(keys))
+	for _, key := range keys {
+		values = append(values, []byte(key), data[key])
+	}
+	return hashData(values...)
+}
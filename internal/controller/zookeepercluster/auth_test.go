@@ -0,0 +1,251 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+	"testing"
+)
+
+func testAuthCluster(auth *v1alpha1.Authentication) *v1alpha1.ZookeeperCluster {
+	cluster := &v1alpha1.ZookeeperCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "zk", Namespace: "default"},
+		Spec:       v1alpha1.ZookeeperClusterSpec{Authentication: auth},
+	}
+	cluster.SetSpecDefaults()
+	return cluster
+}
+
+func testSecret(name string, data map[string]string) *v1.Secret {
+	s := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string][]byte{},
+	}
+	for key, value := range data {
+		s.Data[key] = []byte(value)
+	}
+	return s
+}
+
+func testCredentials(name, username, password string) *v1.Secret {
+	return testSecret(name, map[string]string{
+		v1alpha1.CredentialsUsernameKey: username,
+		v1alpha1.CredentialsPasswordKey: password,
+	})
+}
+
+func TestJaasQuote(t *testing.T) {
+	if quoted := jaasQuote(`pa"ss\word`); quoted != `"pa\"ss\\word"` {
+		t.Errorf("unexpected quoted value: %s", quoted)
+	}
+}
+
+func TestCreateAuthSecretData(t *testing.T) {
+	ctx := reconcilertest.NewContext(
+		testCredentials("operator", "operator", "secret"),
+		testCredentials("quorum", "member", "quorum-secret"),
+		testSecret("users", map[string]string{"bob": "bob-secret", "alice": "alice-secret"}))
+	cluster := testAuthCluster(&v1alpha1.Authentication{
+		OperatorCredentialsSecret: "operator",
+		Quorum:                    &v1alpha1.QuorumAuthentication{CredentialsSecret: "quorum"},
+		Client:                    &v1alpha1.ClientAuthentication{UsersSecret: "users"},
+	})
+	data, hash, err := createAuthSecretData(ctx, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data[superDigestKey]) != zk.Digest("operator", "secret") {
+		t.Errorf("unexpected super digest: %s", data[superDigestKey])
+	}
+	jaas := string(data[jaasConfigKey])
+	for _, expected := range []string{
+		"QuorumServer {\n  " + digestLoginModule + " required\n  user_member=\"quorum-secret\";\n};\n",
+		"QuorumLearner {\n  " + digestLoginModule + " required\n  username=\"member\"\n  password=\"quorum-secret\";\n};\n",
+		"Server {\n  " + digestLoginModule + " required\n  user_alice=\"alice-secret\"\n  user_bob=\"bob-secret\";\n};\n",
+	} {
+		if !strings.Contains(jaas, expected) {
+			t.Errorf("expected the JAAS configuration to contain %q, got:\n%s", expected, jaas)
+		}
+	}
+
+	users := &v1.Secret{}
+	if err = ctx.Client().Get(context.TODO(), types.NamespacedName{Name: "users", Namespace: "default"}, users); err != nil {
+		t.Fatal(err)
+	}
+	users.Data["bob"] = []byte("rotated")
+	if err = ctx.Client().Update(context.TODO(), users); err != nil {
+		t.Fatal(err)
+	}
+	_, rotatedHash, err := createAuthSecretData(ctx, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotatedHash == hash {
+		t.Error("expected the hash to change with the credentials")
+	}
+}
+
+func TestCreateAuthSecretDataKerberos(t *testing.T) {
+	ctx := reconcilertest.NewContext(testSecret("keytab", map[string]string{v1alpha1.KeytabKey: "keytab-v1"}))
+	cluster := testAuthCluster(&v1alpha1.Authentication{
+		Client: &v1alpha1.ClientAuthentication{
+			Mechanism: v1alpha1.SASLMechanismGSSAPI,
+			Kerberos:  &v1alpha1.Kerberos{Principal: "zookeeper/_HOST@EXAMPLE.COM", KeytabSecret: "keytab"},
+		},
+	})
+	data, hash, err := createAuthSecretData(ctx, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jaas := string(data[jaasConfigKey]); !strings.Contains(jaas, kerberosLoginModule) ||
+		!strings.Contains(jaas, `principal="${zookeeper.kerberos.principal}"`) {
+		t.Errorf("unexpected JAAS configuration:\n%s", jaas)
+	}
+	// the keytab is not copied in the secret but still rolls the members when it changes
+	if hash == hashData(data[jaasConfigKey], data[superDigestKey], nil) {
+		t.Error("expected the hash to cover the keytab")
+	}
+}
+
+func TestCreateAuthSecretDataErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     *v1alpha1.Authentication
+		expected string
+	}{
+		{
+			name:     "missing operator credentials",
+			auth:     &v1alpha1.Authentication{OperatorCredentialsSecret: "missing"},
+			expected: "the secret (missing) referenced by the cluster authentication is not found",
+		},
+		{
+			name:     "quorum credentials without password",
+			auth:     &v1alpha1.Authentication{Quorum: &v1alpha1.QuorumAuthentication{CredentialsSecret: "incomplete"}},
+			expected: "the credentials secret (incomplete) requires a username and a password",
+		},
+		{
+			name: "GSSAPI without kerberos",
+			auth: &v1alpha1.Authentication{
+				Client: &v1alpha1.ClientAuthentication{Mechanism: v1alpha1.SASLMechanismGSSAPI},
+			},
+			expected: "the cluster (zk) GSSAPI authentication requires the kerberos settings",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := reconcilertest.NewContext(testSecret("incomplete", map[string]string{v1alpha1.CredentialsUsernameKey: "member"}))
+			_, _, err := createAuthSecretData(ctx, testAuthCluster(test.auth))
+			if err == nil || err.Error() != test.expected {
+				t.Errorf("expected the error %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestReconcileAuthentication(t *testing.T) {
+	cluster := testAuthCluster(&v1alpha1.Authentication{OperatorCredentialsSecret: "operator"})
+	ctx := reconcilertest.NewContext(cluster, testCredentials("operator", "operator", "secret"))
+	if err := ReconcileAuthentication(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	s := &v1.Secret{}
+	key := types.NamespacedName{Name: cluster.AuthSecretName(), Namespace: cluster.Namespace}
+	if err := ctx.Client().Get(context.TODO(), key, s); err != nil {
+		t.Fatal(err)
+	}
+	if string(s.Data[superDigestKey]) != zk.Digest("operator", "secret") {
+		t.Errorf("unexpected super digest: %s", s.Data[superDigestKey])
+	}
+	if cluster.Status.AuthenticationHash == "" {
+		t.Error("expected the authentication hash in the status")
+	}
+	if annotations := authPodAnnotations(cluster); annotations[authHashAnnotation] != cluster.Status.AuthenticationHash {
+		t.Errorf("unexpected pod annotations: %v", annotations)
+	}
+	if len(ctx.Recorder.Events) > 0 {
+		t.Errorf("expected no event for the initial settings, got %q", <-ctx.Recorder.Events)
+	}
+
+	operator := testCredentials("operator", "operator", "rotated")
+	if err := ctx.Client().Update(context.TODO(), operator); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReconcileAuthentication(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, ctx, v1.EventTypeNormal, eventReasonAuthenticationUpdated)
+
+	skipACL := false
+	cluster = testAuthCluster(&v1alpha1.Authentication{
+		SkipACL: &skipACL,
+		Quorum:  &v1alpha1.QuorumAuthentication{CredentialsSecret: "quorum"},
+	})
+	err := ReconcileAuthentication(reconcilertest.NewContext(cluster), cluster)
+	if err == nil || err.Error() != "the cluster (zk) can not turn off skipACL without the operator credentials" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCreateAuthConfig(t *testing.T) {
+	if cfg := createAuthConfig(testAuthCluster(nil)); len(cfg) != 0 {
+		t.Errorf("expected no auth config, got %v", cfg)
+	}
+	cfg := createAuthConfig(testAuthCluster(&v1alpha1.Authentication{
+		Quorum: &v1alpha1.QuorumAuthentication{CredentialsSecret: "quorum"},
+		Client: &v1alpha1.ClientAuthentication{
+			Mechanism: v1alpha1.SASLMechanismGSSAPI,
+			Kerberos:  &v1alpha1.Kerberos{Principal: "zookeeper/_HOST@EXAMPLE.COM", KeytabSecret: "keytab"},
+		},
+	}))
+	for key, value := range map[string]string{
+		"quorum.auth.enableSasl":            "true",
+		"quorum.auth.learnerRequireSasl":    "true",
+		"quorum.auth.serverRequireSasl":     "true",
+		"authProvider.sasl":                 saslAuthProviderClass,
+		"kerberos.removeHostFromPrincipal":  "true",
+		"kerberos.removeRealmFromPrincipal": "true",
+	} {
+		if cfg[key] != value {
+			t.Errorf("expected %s=%s, got %q", key, value, cfg[key])
+		}
+	}
+}
+
+func TestReconcileAuthenticationWatchesTheSecrets(t *testing.T) {
+	cluster := testAuthCluster(&v1alpha1.Authentication{OperatorCredentialsSecret: "operator"})
+	ctx := reconcilertest.NewContext(cluster, testCredentials("operator", "operator", "secret"))
+	if err := ReconcileAuthentication(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"operator", cluster.AuthSecretName()} {
+		s := &v1.Secret{}
+		if err := ctx.Client().Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cluster.Namespace}, s); err != nil {
+			t.Fatal(err)
+		}
+		if s.Labels[internal.WatchedSecretLabel] != "true" {
+			t.Errorf("expected the secret (%s) to be watched, got the labels %v", name, s.Labels)
+		}
+	}
+}
@@ -18,15 +18,70 @@ package zookeepercluster
 
 import (
 	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/pod"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/metrics"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
 	"github.com/monimesl/zookeeper-operator/internal/zk"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	statusRequeueDelay = 15 * time.Second
+)
+
+const (
+	reasonQuorumAvailable   = "QuorumAvailable"
+	reasonQuorumUnavailable = "QuorumUnavailable"
+	reasonNoLeader          = "NoLeader"
+	reasonRolloutInProgress = "RolloutInProgress"
+	reasonRolloutComplete   = "RolloutComplete"
+	reasonRolloutPaused     = "RolloutPaused"
+	reasonUpgradeRolledBack = "UpgradeRolledBack"
+	reasonMembersNotReady   = "MembersNotReady"
+	reasonAllMembersReady   = "AllMembersReady"
+	reasonReconcileSuccess  = "ReconcileSucceeded"
+	reasonReconcileError    = "ReconcileFailed"
 )
 
 // ReconcileClusterStatus reconcile the status of the specified cluster
 func ReconcileClusterStatus(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) (err error) {
-	err = updateMetadata(ctx, cluster)
-	return err
+	if err = updateMetadata(ctx, cluster); err != nil {
+		return err
+	}
+	return updateClusterStatus(ctx, cluster)
+}
+
+// RecordReconcileFailure marks the cluster as not reconciled with the error as the reason
+func RecordReconcileFailure(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster, reconcileErr error) {
+	if !cluster.DeletionTimestamp.IsZero() {
+		return
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionReconciled,
+		Status:             metav1.ConditionFalse,
+		Reason:             reasonReconcileError,
+		Message:            reconcileErr.Error(),
+		ObservedGeneration: cluster.Generation,
+	})
+	recordEvent(ctx, cluster, v12.EventTypeWarning, eventReasonReconcileFailed, "%s", reconcileErr)
+	if err := ctx.Client().Status().Update(context.TODO(), cluster); err != nil {
+		ctx.Logger().Info("Error recording the cluster reconcile failure",
+			"cluster", cluster.GetName(), "error", err)
+	}
 }
 
 func updateMetadata(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster) error {
@@ -42,13 +97,10 @@ func updateMetadata(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster) error
 			c.Status.Metadata.Size = *c.Spec.Size
 			c.Status.Metadata.ZkConfig = c.Spec.ZkConfig
 			c.Status.Metadata.ZkVersion = c.Spec.ZookeeperVersion
-			if *c.Spec.Size != c.Status.Metadata.Size {
-				ctx.Logger().Info("Updating the cluster status zookeeper metadata",
-					"cluster", c.GetName(), "specSize", *c.Spec.Size,
-					"statusSize", c.Status.Metadata.Size)
-				if err := zk.UpdateMetadata(c); err != nil {
-					return err
-				}
+			if upgrade := c.Status.Upgrade; upgrade != nil && upgrade.RolledBackVersion != c.Spec.ZookeeperVersion {
+				// Another version is asked for; setting the rolled back one again retries its upgrade
+				upgrade.RolledBackVersion = ""
+				upgrade.RollbackTime = nil
 			}
 			ctx.Logger().Info("Updating the cluster status", "cluster", c.GetName(), "status", c.Status)
 			if err := ctx.Client().Status().Update(context.TODO(), c); err != nil {
@@ -59,3 +111,197 @@ func updateMetadata(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster) error
 	}
 	return nil
 }
+
+func updateClusterStatus(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster) error {
+	if !c.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	sts := &v1.StatefulSet{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      c.GetName(),
+		Namespace: c.Namespace,
+	}, sts)
+	if errors.IsNotFound(err) {
+		sts = nil
+	} else if err != nil {
+		return err
+	}
+	members, err := getMembersStatus(ctx, c)
+	if err != nil {
+		return err
+	}
+	oldStatus := c.Status.DeepCopy()
+	c.Status.Members = members
+	c.Status.ObservedGeneration = c.Generation
+	c.Status.Selector = labels.SelectorFromSet(c.SelectorLabels()).String()
+	c.Status.ReadyReplicas = 0
+	for _, member := range members {
+		if member.Ready {
+			c.Status.ReadyReplicas++
+		}
+	}
+	c.Status.LeaderPod = ""
+	if leader := c.Status.Leader(); leader != nil {
+		c.Status.LeaderPod = leader.Pod
+	}
+	settled := updateConditions(c, sts)
+	completeTLSRotation(c, sts)
+	metrics.SetClusterMembers(c.Namespace, c.Name, *c.Spec.Size, c.Status.ReadyReplicas,
+		meta.IsStatusConditionTrue(c.Status.Conditions, v1alpha1.ConditionQuorumHealthy))
+	if oldStatus.LeaderPod != "" && c.Status.LeaderPod != "" && oldStatus.LeaderPod != c.Status.LeaderPod {
+		metrics.RecordLeaderChange(c.Namespace, c.Name)
+	}
+	if !equality.Semantic.DeepEqual(oldStatus, &c.Status) {
+		ctx.Logger().Info("Updating the cluster status conditions and members",
+			"cluster", c.GetName(), "members", c.Status.Members)
+		if err = ctx.Client().Status().Update(context.TODO(), c); err != nil {
+			return err
+		}
+	}
+	if !settled {
+		return requeue.After(statusRequeueDelay, "the cluster is not yet settled")
+	}
+	return nil
+}
+
+// getMembersStatus collects the live state of the ensemble members from their pods
+func getMembersStatus(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster) ([]v1alpha1.MemberStatus, error) {
+	pods, err := pod.ListAllWithMatchingLabels(ctx.Client(), c.Namespace, c.SelectorLabels())
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := zk.NewEndpoint(ctx.Client(), c)
+	if err != nil {
+		ctx.Logger().Info("Unable to resolve the cluster endpoint; the members state won't be queried",
+			"cluster", c.GetName(), "error", err)
+		endpoint = nil
+	}
+	members := make([]v1alpha1.MemberStatus, 0, len(pods.Items))
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		myid, ok := podMyID(c, p.Name)
+		if !ok {
+			continue
+		}
+		member := v1alpha1.MemberStatus{
+			Pod:   p.Name,
+			MyID:  myid,
+			Role:  v1alpha1.MemberRoleUnknown,
+			Ready: pod.IsReady(p),
+		}
+		if endpoint != nil && p.Status.Phase == v12.PodRunning && p.Status.PodIP != "" {
+			if stat, err := endpoint.Srvr(p.Status.PodIP); err != nil {
+				ctx.Logger().Info("Unable to query the member state",
+					"cluster", c.GetName(), "pod", p.Name, "error", err)
+			} else {
+				member.Role = v1alpha1.MemberRole(stat.Mode)
+				member.Zxid = fmt.Sprintf("0x%x", stat.Zxid)
+			}
+		}
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].MyID < members[j].MyID
+	})
+	return members, nil
+}
+
+// podMyID returns the zookeeper server id of the member running in the named pod
+func podMyID(c *v1alpha1.ZookeeperCluster, podName string) (int32, bool) {
+	ordinal, found := strings.CutPrefix(podName, c.GetName()+"-")
+	if !found {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(ordinal, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(i) + 1, true
+}
+
+// updateConditions sets the cluster conditions from the members status
+// and returns whether the cluster has settled into its desired state
+func updateConditions(c *v1alpha1.ZookeeperCluster, sts *v1.StatefulSet) bool {
+	size := *c.Spec.Size
+	var ready, readyVoters int32
+	for _, member := range c.Status.Members {
+		if member.Ready {
+			ready++
+			if member.IsVoter() {
+				readyVoters++
+			}
+		}
+	}
+	voters := size
+	if c.Status.Ensemble != nil && len(c.Status.Ensemble.Participants) > 0 {
+		voters = int32(len(c.Status.Ensemble.Participants))
+	}
+	quorum := voters/2 + 1
+	setCondition(c, v1alpha1.ConditionReconciled, true, reasonReconcileSuccess, "")
+
+	quorumHealthy := c.Status.Leader() != nil && readyVoters >= quorum
+	switch {
+	case quorumHealthy:
+		setCondition(c, v1alpha1.ConditionQuorumHealthy, true, reasonQuorumAvailable,
+			fmt.Sprintf("%d of %d voting members are ready", readyVoters, voters))
+	case c.Status.Leader() == nil:
+		setCondition(c, v1alpha1.ConditionQuorumHealthy, false, reasonNoLeader,
+			"no member reported itself as the ensemble leader")
+	default:
+		setCondition(c, v1alpha1.ConditionQuorumHealthy, false, reasonQuorumUnavailable,
+			fmt.Sprintf("%d of %d voting members are ready; a quorum needs %d", readyVoters, voters, quorum))
+	}
+
+	if quorumHealthy {
+		setCondition(c, v1alpha1.ConditionAvailable, true, reasonQuorumAvailable, "")
+	} else {
+		setCondition(c, v1alpha1.ConditionAvailable, false, reasonQuorumUnavailable, "")
+	}
+
+	progressing := isRollingOut(sts) || ready != size || int32(len(c.Status.Members)) != size
+	if rollout := c.Status.Rollout; rollout != nil && rollout.Phase == v1alpha1.RolloutPhasePaused {
+		setCondition(c, v1alpha1.ConditionProgressing, false, reasonRolloutPaused, rollout.Message)
+	} else if version := c.TargetVersion(); version != c.Spec.ZookeeperVersion {
+		setCondition(c, v1alpha1.ConditionProgressing, false, reasonUpgradeRolledBack,
+			fmt.Sprintf("the upgrade to %s stalled and was rolled back to %s", c.Spec.ZookeeperVersion, version))
+	} else if progressing {
+		setCondition(c, v1alpha1.ConditionProgressing, true, reasonRolloutInProgress,
+			fmt.Sprintf("%d of %d members are ready", ready, size))
+	} else {
+		setCondition(c, v1alpha1.ConditionProgressing, true, reasonRolloutComplete, "")
+	}
+
+	switch {
+	case !quorumHealthy && size > 0:
+		setCondition(c, v1alpha1.ConditionDegraded, true, reasonQuorumUnavailable, "")
+	case !isRollingOut(sts) && ready < size:
+		setCondition(c, v1alpha1.ConditionDegraded, true, reasonMembersNotReady,
+			fmt.Sprintf("%d of %d members are ready", ready, size))
+	default:
+		setCondition(c, v1alpha1.ConditionDegraded, false, reasonAllMembersReady, "")
+	}
+	return (quorumHealthy || size == 0) && !progressing
+}
+
+func isRollingOut(sts *v1.StatefulSet) bool {
+	if sts == nil {
+		return true
+	}
+	// The current revision isn't advanced by the OnDelete strategy; the updated replicas are counted instead
+	return sts.Status.ObservedGeneration < sts.Generation ||
+		sts.Status.UpdatedReplicas != sts.Status.Replicas
+}
+
+func setCondition(c *v1alpha1.ZookeeperCluster, conditionType string, status bool, reason, message string) {
+	conditionStatus := metav1.ConditionFalse
+	if status {
+		conditionStatus = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&c.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: c.Generation,
+	})
+}
@@ -22,14 +22,24 @@ import (
 	"github.com/monimesl/operator-helper/oputil"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
-	"github.com/monimesl/zookeeper-operator/internal/zk"
+	"github.com/monimesl/zookeeper-operator/internal"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"log"
+	"sort"
 	"strconv"
+	"strings"
 )
 
+const (
+	// dynamicConfigKey is the key of the ensemble dynamic configuration the members start with
+	dynamicConfigKey = "zoo.cfg.dynamic"
+)
+
+// configHashAnnotation records on the pod template the hash of the config the members run with
+var configHashAnnotation = fmt.Sprintf("%s/config-hash", internal.Domain)
+
 // ReconcileConfigMap reconcile the configmap of the specified cluster
 func ReconcileConfigMap(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error {
 	cm := &v1.ConfigMap{}
@@ -39,7 +49,7 @@ func ReconcileConfigMap(ctx reconciler.Context, cluster *v1alpha1.ZookeeperClust
 	}, cm,
 		// Found
 		func() error {
-			if shouldUpdateConfigmap(ctx, cluster) {
+			if shouldUpdateConfigmap(ctx, cluster, cm) {
 				if err := updateConfigmap(ctx, cm, cluster); err != nil {
 					return err
 				}
@@ -57,6 +67,7 @@ func ReconcileConfigMap(ctx reconciler.Context, cluster *v1alpha1.ZookeeperClust
 					ctx.Logger().Info("ConfigMap creation success.",
 						"ConfigMap.Name", cm.GetName(),
 						"ConfigMap.Namespace", cm.GetNamespace())
+					recordEvent(ctx, cluster, v1.EventTypeNormal, eventReasonCreated, "Created the configmap %s", cm.Name)
 				}
 			}
 			return
@@ -78,13 +89,24 @@ func createConfigMap(c *v1alpha1.ZookeeperCluster) *v1.ConfigMap {
 	}
 }
 
-func shouldUpdateConfigmap(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster) bool {
+func shouldUpdateConfigmap(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, cm *v1.ConfigMap) bool {
 	if c.Spec.ZkConfig != c.Status.Metadata.ZkConfig {
 		ctx.Logger().Info("Zookeeper cluster config changed",
 			"from", c.Status.Metadata.ZkConfig, "to", c.Spec.ZkConfig,
 		)
 		return true
 	}
+	if dynamicConfig := createDynamicConfig(c); cm.Data[dynamicConfigKey] != dynamicConfig {
+		ctx.Logger().Info("Zookeeper ensemble membership changed",
+			"from", cm.Data[dynamicConfigKey], "to", dynamicConfig,
+		)
+		return true
+	}
+	if !equality.Semantic.DeepEqual(cm.Data, createConfigmapData(c)) {
+		ctx.Logger().Info("Zookeeper cluster generated config changed",
+			"ConfigMap.Name", cm.GetName(), "ConfigMap.Namespace", cm.GetNamespace())
+		return true
+	}
 	return false
 }
 
@@ -94,26 +116,41 @@ func updateConfigmap(ctx reconciler.Context, cm *v1.ConfigMap, c *v1alpha1.Zooke
 		"ConfigMap.Namespace", cm.GetNamespace())
 	cm.Labels = c.GenerateLabels()
 	cm.Data = createConfigmapData(c)
-	return ctx.Client().Update(context.TODO(), cm)
+	if err := ctx.Client().Update(context.TODO(), cm); err != nil {
+		return err
+	}
+	recordEvent(ctx, c, v1.EventTypeNormal, eventReasonConfigUpdated, "Updated the zookeeper configuration in the configmap %s", cm.Name)
+	return nil
 }
 
 func createConfigmapData(c *v1alpha1.ZookeeperCluster) map[string]string {
 	return map[string]string{
-		"zoo.cfg":     createZkConfig(c),
-		"bootEnv.sh":  createBootEnvScript(c),
-		"logback.xml": createZkLogbackXmData(c),
+		"zoo.cfg":        createZkConfig(c),
+		dynamicConfigKey: createDynamicConfig(c),
+		"bootEnv.sh":     createBootEnvScript(c),
+		"logback.xml":    createZkLogbackXmData(c),
 	}
 }
 
 func createBootEnvScript(c *v1alpha1.ZookeeperCluster) string {
 	return "#!/usr/bin/env bash\n\n" +
 		fmt.Sprintf("CLUSTER_NAME=%s\n", c.GetName()) +
-		fmt.Sprintf("CLUSTER_METADATA_PARENT_ZNODE=%s\n", zk.ClusterMetadataParentZNode) +
 		fmt.Sprintf("DATA_DIR=%s\n", c.Spec.Directories.Data) +
+		fmt.Sprintf("DATA_LOG_DIR=%s\n", c.DataLogDir()) +
 		fmt.Sprintf("CLIENT_PORT=%d\n", c.Spec.Ports.Client) +
 		fmt.Sprintf("SECURE_CLIENT_PORT=%d\n", c.Spec.Ports.SecureClient) +
 		fmt.Sprintf("QUORUM_PORT=%d\n", c.Spec.Ports.Quorum) +
-		fmt.Sprintf("LEADER_PORT=%d\n", c.Spec.Ports.Leader)
+		fmt.Sprintf("LEADER_PORT=%d\n", c.Spec.Ports.Leader) +
+		fmt.Sprintf("EPHEMERAL_STORAGE=%t\n", c.IsEphemeral()) +
+		juteMaxBufferEnv(c)
+}
+
+// juteMaxBufferEnv returns the boot env line of the jute.maxbuffer the members start with, or empty if unset
+func juteMaxBufferEnv(c *v1alpha1.ZookeeperCluster) string {
+	if c.Spec.Config == nil || c.Spec.Config.JuteMaxBuffer == nil {
+		return ""
+	}
+	return fmt.Sprintf("JUTE_MAXBUFFER=%d\n", *c.Spec.Config.JuteMaxBuffer)
 }
 
 func createZkConfig(c *v1alpha1.ZookeeperCluster) string {
@@ -127,17 +164,21 @@ func createZkConfig(c *v1alpha1.ZookeeperCluster) string {
 		secureClientPort = ""
 	}
 	enableAdmin := c.Spec.Ports.Admin > 0
-	str, _ := oputil.CreateConfigFromYamlString(c.Spec.ZkConfig, "zoo.cfg", map[string]string{
+	skipACL := "yes"
+	if !c.IsACLSkipped() {
+		skipACL = "no"
+	}
+	keyValues := map[string]string{
 		"initLimit":              "10",
 		"syncLimit":              "5",
 		"tickTime":               "2000",
-		"skipACL":                "yes",
+		"skipACL":                skipACL,
 		"reconfigEnabled":        "true",
 		"standaloneEnabled":      "false",
 		"clientPort":             clientPort,
 		"secureClientPort":       secureClientPort,
 		"dataDir":                c.Spec.Directories.Data,
-		"dataLogDir":             c.Spec.Directories.Log,
+		"dataLogDir":             c.DataLogDir(),
 		"dynamicConfigFile":      fmt.Sprintf("%s/conf/zoo.cfg.dynamic", c.Spec.Directories.Data),
 		"4lw.commands.whitelist": "conf, cons, crst, conf, dirs, envi, mntr, ruok, srvr, srst, stat",
 		// MonitoringConfig configs
@@ -147,10 +188,43 @@ func createZkConfig(c *v1alpha1.ZookeeperCluster) string {
 		// Admin configs
 		"admin.enableServer": strconv.FormatBool(enableAdmin),
 		"admin.serverPort":   fmt.Sprintf("%d", c.Spec.Ports.Admin),
-	}, "clientPort", "secureClientPort", "dataDir", "dataLogDir", "dynamicConfigFile",
-		"metricsProvider.httpPort", "admin.enableServer", "admin.serverPort")
-	log.Printf("zoo.cfg values: %s\n", str)
-	return str
+	}
+	for key, value := range createTLSConfig(c) {
+		keyValues[key] = value
+	}
+	for key, value := range createAuthConfig(c) {
+		keyValues[key] = value
+	}
+	// The typed settings take precedence over the zkCfg ones
+	protectedKeys := c.OperatorConfigKeys()
+	for key, value := range c.Spec.Config.KeyValues() {
+		keyValues[key] = value
+		protectedKeys = append(protectedKeys, key)
+	}
+	_, values := oputil.CreateConfigFromYamlString(c.Spec.ZkConfig, "zoo.cfg", keyValues, protectedKeys...)
+	// Sorted so the generated config, hence its hash, is stable
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var cfg strings.Builder
+	for _, key := range keys {
+		cfg.WriteString(fmt.Sprintf("%s=%s\n", key, values[key]))
+	}
+	return cfg.String()
+}
+
+// configPodAnnotations creates the pod template annotation of the hash of the config the members start with.
+// The dynamic config is excluded since the membership changes are applied without restarting the members
+func configPodAnnotations(c *v1alpha1.ZookeeperCluster) map[string]string {
+	data := map[string][]byte{}
+	for key, value := range createConfigmapData(c) {
+		if key != dynamicConfigKey {
+			data[key] = []byte(value)
+		}
+	}
+	return map[string]string{configHashAnnotation: hashSecretData(data)}
 }
 
 // see https://github.com/apache/zookeeper/blob/master/conf/logback.xml
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+)
+
+func testConfigCluster(mutate func(c *v1alpha1.ZookeeperCluster)) *v1alpha1.ZookeeperCluster {
+	cluster := &v1alpha1.ZookeeperCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "zk", Namespace: "default"},
+	}
+	if mutate != nil {
+		mutate(cluster)
+	}
+	cluster.SetSpecDefaults()
+	return cluster
+}
+
+// parseZkConfig parses the key=value lines of the generated zoo.cfg
+func parseZkConfig(t *testing.T, cfg string) map[string]string {
+	t.Helper()
+	values := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(cfg), "\n") {
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			t.Fatalf("unexpected zoo.cfg line: %q", line)
+		}
+		values[key] = value
+	}
+	return values
+}
+
+func TestCreateZkConfig(t *testing.T) {
+	tickTime, snapCount := int32(4000), int32(50000)
+	c := testConfigCluster(func(c *v1alpha1.ZookeeperCluster) {
+		c.Spec.ZkConfig = "tickTime: 3000\nmaxClientCnxns: 100\ndataDir: /tmp/zookeeper\nsnapCount: 1000\n"
+		c.Spec.Config = &v1alpha1.ZooConfig{TickTime: &tickTime, SnapCount: &snapCount}
+	})
+	cfg := parseZkConfig(t, createZkConfig(c))
+	for key, value := range map[string]string{
+		"tickTime":       "4000",
+		"snapCount":      "50000",
+		"maxClientCnxns": "100",
+		"initLimit":      "10",
+		"dataDir":        c.Spec.Directories.Data,
+		"skipACL":        "yes",
+	} {
+		if cfg[key] != value {
+			t.Errorf("expected %s=%s, got %q", key, value, cfg[key])
+		}
+	}
+}
+
+func TestCreateBootEnvScript(t *testing.T) {
+	if env := createBootEnvScript(testConfigCluster(nil)); strings.Contains(env, "JUTE_MAXBUFFER") {
+		t.Errorf("expected no jute.maxbuffer, got:\n%s", env)
+	}
+	maxBuffer := int32(4194304)
+	env := createBootEnvScript(testConfigCluster(func(c *v1alpha1.ZookeeperCluster) {
+		c.Spec.Config = &v1alpha1.ZooConfig{JuteMaxBuffer: &maxBuffer}
+	}))
+	if !strings.Contains(env, "JUTE_MAXBUFFER=4194304\n") {
+		t.Errorf("expected the jute.maxbuffer in the boot env, got:\n%s", env)
+	}
+}
+
+func TestCreateZkConfigClientAuthEnforcement(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		operator string
+		expected map[string]string
+	}{
+		{
+			name:     "enforced",
+			version:  "3.8.4",
+			operator: "operator",
+			expected: map[string]string{"enforce.auth.enabled": "true", "enforce.auth.schemes": "sasl,digest"},
+		},
+		{
+			name:     "without the operator credentials",
+			version:  "3.8.4",
+			expected: map[string]string{"enforce.auth.enabled": "", "enforce.auth.schemes": ""},
+		},
+		{
+			name:     "before zookeeper 3.7",
+			version:  "3.6.3",
+			operator: "operator",
+			expected: map[string]string{"enforce.auth.enabled": "", "enforce.auth.schemes": ""},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := parseZkConfig(t, createZkConfig(testConfigCluster(func(c *v1alpha1.ZookeeperCluster) {
+				c.Spec.ZookeeperVersion = test.version
+				c.Spec.Authentication = &v1alpha1.Authentication{
+					OperatorCredentialsSecret: test.operator,
+					Client:                    &v1alpha1.ClientAuthentication{UsersSecret: "users"},
+				}
+			})))
+			for key, value := range test.expected {
+				if cfg[key] != value {
+					t.Errorf("expected %s=%q, got %q", key, value, cfg[key])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	eventReasonCreated                    = "Created"
+	eventReasonUpdated                    = "Updated"
+	eventReasonConfigUpdated              = "ConfigUpdated"
+	eventReasonScaling                    = "Scaling"
+	eventReasonPVCDeleted                 = "PVCDeleted"
+	eventReasonVolumeExpansion            = "VolumeExpansion"
+	eventReasonVolumeExpansionUnsupported = "VolumeExpansionUnsupported"
+	eventReasonMemberPromoted             = "MemberPromoted"
+	eventReasonMemberRemoved              = "MemberRemoved"
+	eventReasonRolloutStarted             = "RolloutStarted"
+	eventReasonRolloutPaused              = "RolloutPaused"
+	eventReasonUpgradeCompleted           = "UpgradeCompleted"
+	eventReasonUpgradeRolledBack          = "UpgradeRolledBack"
+	eventReasonCertificatesRotated        = "CertificatesRotated"
+	eventReasonCertificatesReloadTimedOut = "CertificatesReloadTimedOut"
+	eventReasonAuthenticationUpdated      = "AuthenticationUpdated"
+	eventReasonDeleting                   = "Deleting"
+	eventReasonMetadataCleanupFailed      = "MetadataCleanupFailed"
+	eventReasonReconcileFailed            = "ReconcileFailed"
+)
+
+// EventRecording is implemented by the reconcile contexts which record events on the clusters
+type EventRecording interface {
+	EventRecorder() record.EventRecorder
+}
+
+// recordEvent records the event on the cluster when the context supports it
+func recordEvent(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, eventType, reason, messageFmt string, args ...interface{}) {
+	if r, ok := ctx.(EventRecording); ok && r.EventRecorder() != nil {
+		r.EventRecorder().Eventf(c, eventType, reason, messageFmt, args...)
+	}
+}
@@ -22,7 +22,9 @@ import (
 	"github.com/monimesl/operator-helper/oputil"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/metrics"
 	"github.com/monimesl/zookeeper-operator/internal/zk"
+	v1 "k8s.io/api/core/v1"
 	"time"
 )
 
@@ -55,6 +57,8 @@ func ReconcileFinalizer(ctx reconciler.Context, cluster *v1alpha1.ZookeeperClust
 			if err := ctx.Client().Update(context.TODO(), cluster); err != nil {
 				return fmt.Errorf("ZookkeeperCluster object (%s) update error: %w", cluster.Name, err)
 			}
+			recordEvent(ctx, cluster, v1.EventTypeNormal, eventReasonDeleting,
+				"Scaling the members to zero before deleting the cluster")
 			return nil
 		}
 		if err := cluster.WaitClusterTermination(ctx.Client()); err != nil {
@@ -70,6 +74,7 @@ func ReconcileFinalizer(ctx reconciler.Context, cluster *v1alpha1.ZookeeperClust
 		if err := ctx.Client().Update(context.TODO(), cluster); err != nil {
 			return fmt.Errorf("ZookkeeperCluster object (%s) update error: %w", cluster.Name, err)
 		}
+		metrics.DeleteCluster(cluster.Namespace, cluster.Name)
 		ctx.Logger().Info("Cluster finalizers update and cleanup success.",
 			"cluster", cluster.GetName())
 		return nil
@@ -77,17 +82,26 @@ func ReconcileFinalizer(ctx reconciler.Context, cluster *v1alpha1.ZookeeperClust
 	return nil
 }
 
+// cleanUpMetadata deletes the cluster metadata znodes. The failed attempts are retried and
+// reported as events, but don't block the deletion since the ensemble may be unavailable
 func cleanUpMetadata(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error {
 	ctx.Logger().Info("Cleaning up the metadata for cluster", "cluster", cluster.Name)
-	for i := 0; i < 3; i++ {
+	const attempts = 3
+	for i := 0; i < attempts; i++ {
 		if i > 0 {
 			time.Sleep(2 * time.Second)
 		}
-		if err := zk.DeleteMetadata(cluster); err != nil {
-			ctx.Logger().Info("Cleaning up the metadata error",
-				"cluster", cluster.Name, "attempts", i, "error", err)
+		err := zk.DeleteMetadata(ctx.Client(), cluster)
+		if err == nil {
+			return nil
 		}
+		ctx.Logger().Info("Cleaning up the metadata error",
+			"cluster", cluster.Name, "attempts", i, "error", err)
+		recordEvent(ctx, cluster, v1.EventTypeWarning, eventReasonMetadataCleanupFailed,
+			"Attempt %d of %d to delete the cluster metadata failed: %s", i+1, attempts, err)
 	}
+	recordEvent(ctx, cluster, v1.EventTypeWarning, eventReasonMetadataCleanupFailed,
+		"Deleting the cluster without its metadata cleaned up")
 	return nil
 }
 
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"os"
+	"strings"
+)
+
+// imageRegistryEnv is the operator-wide registry the zookeeper images are pulled from instead of
+// the one of their reference, e.g. the mirror of an air-gapped cluster
+const imageRegistryEnv = "ZOOKEEPER_IMAGE_REGISTRY"
+
+// zookeeperImage returns the zookeeper image reference of the cluster, its registry rewritten to the operator-wide one
+func zookeeperImage(c *v1alpha1.ZookeeperCluster) string {
+	return rewriteImageRegistry(c.Image(), strings.TrimSpace(os.Getenv(imageRegistryEnv)))
+}
+
+// rewriteImageRegistry replaces the registry of the image reference, docker.io when it has none
+func rewriteImageRegistry(image, registry string) string {
+	if registry == "" {
+		return image
+	}
+	if first, rest, found := strings.Cut(image, "/"); found &&
+		(strings.ContainsAny(first, ".:") || first == "localhost") {
+		image = rest
+	}
+	return strings.TrimSuffix(registry, "/") + "/" + image
+}
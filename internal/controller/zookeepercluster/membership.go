@@ -0,0 +1,269 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	membershipRequeueDelay = 10 * time.Second
+	// maxCatchUpTransactions is how many transactions a member may be behind the leader and count as
+	// caught up; the member and the leader zxids aren't read at the same time
+	maxCatchUpTransactions = 1000
+)
+
+// ReconcileMembership reconciles the dynamic configuration of the ensemble with the statefulset
+// replicas. The started members join as observers; they're promoted to participants one at a
+// time once caught up with the leader, and the members beyond the replicas are removed
+func ReconcileMembership(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error {
+	if !cluster.DeletionTimestamp.IsZero() || cluster.Status.Leader() == nil {
+		return nil
+	}
+	sts := &v1.StatefulSet{}
+	if err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      cluster.GetName(),
+		Namespace: cluster.Namespace,
+	}, sts); err != nil {
+		return err
+	}
+	replicas := *sts.Spec.Replicas
+	if replicas == 0 {
+		return nil
+	}
+	cl, err := zk.NewZkClient(ctx.Client(), cluster)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	oldStatus := cluster.Status.DeepCopy()
+	err = reconcileMembers(ctx, cl, cluster, replicas)
+	if !equality.Semantic.DeepEqual(oldStatus, &cluster.Status) {
+		ctx.Logger().Info("Updating the cluster ensemble status",
+			"cluster", cluster.GetName(), "ensemble", cluster.Status.Ensemble)
+		if updateErr := ctx.Client().Status().Update(context.TODO(), cluster); updateErr != nil && err == nil {
+			err = updateErr
+		}
+	}
+	return err
+}
+
+func reconcileMembers(ctx reconciler.Context, cl *zk.Client, c *v1alpha1.ZookeeperCluster, replicas int32) error {
+	ensemble, err := cl.GetEnsemble()
+	if err != nil {
+		return fmt.Errorf("error reading the ensemble configuration: %w", err)
+	}
+	setEnsembleStatus(c, ensemble)
+	for _, id := range sortedMemberIDs(ensemble) {
+		if id <= replicas {
+			continue
+		}
+		if err = removeMember(ctx, cl, c, ensemble, id); err != nil {
+			return err
+		}
+		// The next changes are made on the updated configuration
+		return requeue.After(membershipRequeueDelay, fmt.Sprintf("member %d removed from the ensemble", id))
+	}
+	for id := int32(1); id <= replicas; id++ {
+		if ensemble.IsParticipant(id) {
+			continue
+		}
+		if reason := memberLag(c, id); reason != "" {
+			return requeue.After(membershipRequeueDelay, reason)
+		}
+		ctx.Logger().Info("Promoting the caught up member to a participant",
+			"cluster", c.GetName(), "myid", id)
+		if err = cl.AddParticipant(memberConfig(c, id, zk.RoleParticipant), ensemble.Version); err != nil {
+			return fmt.Errorf("error promoting the member %d: %w", id, err)
+		}
+		recordTransition(c, id, v1alpha1.MembershipTransitionPromoted, ensemble.Version)
+		recordEvent(ctx, c, v12.EventTypeNormal, eventReasonMemberPromoted, "Promoted the member %d to a participant", id)
+		return requeue.After(membershipRequeueDelay, fmt.Sprintf("member %d promoted to a participant", id))
+	}
+	return nil
+}
+
+// removeMember removes the member from the ensemble and records the transition
+func removeMember(ctx reconciler.Context, cl *zk.Client, c *v1alpha1.ZookeeperCluster, ensemble *zk.Ensemble, id int32) error {
+	ctx.Logger().Info("Removing the member from the ensemble", "cluster", c.GetName(), "myid", id)
+	if err := cl.RemoveMember(id, ensemble.Version); err != nil {
+		return fmt.Errorf("error removing the member %d: %w", id, err)
+	}
+	recordTransition(c, id, v1alpha1.MembershipTransitionRemoved, ensemble.Version)
+	recordEvent(ctx, c, v12.EventTypeNormal, eventReasonMemberRemoved, "Removed the member %d from the ensemble", id)
+	return nil
+}
+
+// removeLeavingMember removes the member from the ensemble before its pod is stopped by a scale-down
+func removeLeavingMember(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, id int32) error {
+	cl, err := zk.NewZkClient(ctx.Client(), c)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	ensemble, err := cl.GetEnsemble()
+	if err != nil {
+		return fmt.Errorf("error reading the ensemble configuration: %w", err)
+	}
+	if _, ok := ensemble.Members[id]; ok {
+		if err = removeMember(ctx, cl, c, ensemble, id); err != nil {
+			return err
+		}
+		if ensemble, err = cl.GetEnsemble(); err != nil {
+			return fmt.Errorf("error reading the ensemble configuration: %w", err)
+		}
+	}
+	setEnsembleStatus(c, ensemble)
+	return ctx.Client().Status().Update(context.TODO(), c)
+}
+
+// memberLag returns why the member can't be promoted yet, or empty if it's caught up with the leader:
+// it's ready and serving as observer or follower with a zxid close to the leader one
+func memberLag(c *v1alpha1.ZookeeperCluster, id int32) string {
+	leader := c.Status.Leader()
+	var member *v1alpha1.MemberStatus
+	for i := range c.Status.Members {
+		if c.Status.Members[i].MyID == id {
+			member = &c.Status.Members[i]
+		}
+	}
+	switch {
+	case member == nil || !member.Ready:
+		return fmt.Sprintf("waiting for the member %d to be ready", id)
+	case member.Role != v1alpha1.MemberRoleObserver && member.Role != v1alpha1.MemberRoleFollower:
+		return fmt.Sprintf("waiting for the member %d to follow the leader", id)
+	case leader == nil:
+		return fmt.Sprintf("waiting for the member %d to catch up with the leader", id)
+	}
+	if lag := zxidLag(parseZxid(member.Zxid), parseZxid(leader.Zxid)); lag != "" {
+		return fmt.Sprintf("waiting for the member %d to catch up with the leader: %s", id, lag)
+	}
+	return ""
+}
+
+// zxidLag returns how far the member zxid is behind the leader one, or empty if it's caught up: in
+// the leader epoch, the high 32 bits, and at most maxCatchUpTransactions behind its counter
+func zxidLag(member, leader int64) string {
+	if member < 0 || leader < 0 {
+		return "the zxids are unknown"
+	}
+	if member>>32 != leader>>32 {
+		return fmt.Sprintf("in the epoch %d instead of %d", member>>32, leader>>32)
+	}
+	if behind := leader&0xffffffff - member&0xffffffff; behind > maxCatchUpTransactions {
+		return fmt.Sprintf("%d transactions behind", behind)
+	}
+	return ""
+}
+
+// parseZxid parses the hex zxid, or returns -1 if it's invalid
+func parseZxid(zxid string) int64 {
+	value, err := strconv.ParseUint(strings.TrimPrefix(zxid, "0x"), 16, 64)
+	if err != nil {
+		return -1
+	}
+	return int64(value)
+}
+
+// memberConfig returns the dynamic configuration line of the member. Only the plain client port is
+// advertised since the dynamic configuration has no secure client address; the line has no client
+// port when the plain one is disabled, and the members serve the secure one of the static configuration
+func memberConfig(c *v1alpha1.ZookeeperCluster, id int32, role string) string {
+	server := fmt.Sprintf("server.%d=%s:%d:%d:%s", id, c.MemberFQDN(id), c.Spec.Ports.Quorum, c.Spec.Ports.Leader, role)
+	if c.Spec.Ports.Client > 0 {
+		server += fmt.Sprintf(";%d", c.Spec.Ports.Client)
+	}
+	return server
+}
+
+// createDynamicConfig creates the dynamic configuration the members start with: the known
+// participants, and the other members up to the size as observers. The first member is
+// the only participant of a new ensemble
+func createDynamicConfig(c *v1alpha1.ZookeeperCluster) string {
+	participants := []int32{1}
+	if c.Status.Ensemble != nil && len(c.Status.Ensemble.Participants) > 0 {
+		participants = c.Status.Ensemble.Participants
+	}
+	size := *c.Spec.Size
+	for _, id := range participants {
+		if id > size {
+			size = id
+		}
+	}
+	lines := make([]string, 0, size)
+	for id := int32(1); id <= size; id++ {
+		role := zk.RoleObserver
+		for _, p := range participants {
+			if p == id {
+				role = zk.RoleParticipant
+			}
+		}
+		lines = append(lines, memberConfig(c, id, role))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func setEnsembleStatus(c *v1alpha1.ZookeeperCluster, ensemble *zk.Ensemble) {
+	if c.Status.Ensemble == nil {
+		c.Status.Ensemble = &v1alpha1.EnsembleStatus{}
+	}
+	c.Status.Ensemble.ConfigVersion = fmt.Sprintf("0x%x", ensemble.Version)
+	c.Status.Ensemble.Participants = nil
+	c.Status.Ensemble.Observers = nil
+	for _, id := range sortedMemberIDs(ensemble) {
+		if ensemble.IsParticipant(id) {
+			c.Status.Ensemble.Participants = append(c.Status.Ensemble.Participants, id)
+		} else {
+			c.Status.Ensemble.Observers = append(c.Status.Ensemble.Observers, id)
+		}
+	}
+}
+
+func recordTransition(c *v1alpha1.ZookeeperCluster, id int32, transition v1alpha1.MembershipTransitionType, version int64) {
+	if c.Status.Ensemble == nil {
+		c.Status.Ensemble = &v1alpha1.EnsembleStatus{}
+	}
+	c.Status.Ensemble.RecordTransition(v1alpha1.MembershipTransition{
+		MyID:          id,
+		Type:          transition,
+		ConfigVersion: fmt.Sprintf("0x%x", version),
+		Time:          metav1.Now(),
+	})
+}
+
+func sortedMemberIDs(ensemble *zk.Ensemble) []int32 {
+	ids := make([]int32, 0, len(ensemble.Members))
+	for id := range ensemble.Members {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+// testCluster returns a defaulted cluster of the size whose members have the roles by their server id
+func testCluster(size int32, roles map[int32]v1alpha1.MemberRole) *v1alpha1.ZookeeperCluster {
+	c := &v1alpha1.ZookeeperCluster{ObjectMeta: metav1.ObjectMeta{Name: "zk", Namespace: "default"}}
+	c.Spec.Size = &size
+	c.SetSpecDefaults()
+	for id := int32(1); id <= size; id++ {
+		c.Status.Members = append(c.Status.Members, v1alpha1.MemberStatus{
+			Pod: c.MemberPodName(id), MyID: id, Role: roles[id], Ready: roles[id] != "",
+		})
+	}
+	return c
+}
+
+func TestCreateDynamicConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int32
+		mutate func(c *v1alpha1.ZookeeperCluster)
+		config string
+	}{
+		{
+			name: "new ensemble",
+			size: 3,
+			config: "server.1=zk-0.zk-headless.default.svc.cluster.local:2888:3888:participant;2181\n" +
+				"server.2=zk-1.zk-headless.default.svc.cluster.local:2888:3888:observer;2181\n" +
+				"server.3=zk-2.zk-headless.default.svc.cluster.local:2888:3888:observer;2181\n",
+		},
+		{
+			name: "known participants",
+			size: 4,
+			mutate: func(c *v1alpha1.ZookeeperCluster) {
+				c.Status.Ensemble = &v1alpha1.EnsembleStatus{Participants: []int32{1, 2, 3}}
+			},
+			config: "server.1=zk-0.zk-headless.default.svc.cluster.local:2888:3888:participant;2181\n" +
+				"server.2=zk-1.zk-headless.default.svc.cluster.local:2888:3888:participant;2181\n" +
+				"server.3=zk-2.zk-headless.default.svc.cluster.local:2888:3888:participant;2181\n" +
+				"server.4=zk-3.zk-headless.default.svc.cluster.local:2888:3888:observer;2181\n",
+		},
+		{
+			name: "participants beyond the size while scaling down",
+			size: 1,
+			mutate: func(c *v1alpha1.ZookeeperCluster) {
+				c.Status.Ensemble = &v1alpha1.EnsembleStatus{Participants: []int32{1, 3}}
+			},
+			config: "server.1=zk-0.zk-headless.default.svc.cluster.local:2888:3888:participant;2181\n" +
+				"server.2=zk-1.zk-headless.default.svc.cluster.local:2888:3888:observer;2181\n" +
+				"server.3=zk-2.zk-headless.default.svc.cluster.local:2888:3888:participant;2181\n",
+		},
+		{
+			name: "custom ports without the plain client port",
+			size: 1,
+			mutate: func(c *v1alpha1.ZookeeperCluster) {
+				c.Spec.Ports.Quorum = 2889
+				c.Spec.Ports.Leader = 3889
+				c.Spec.Ports.Client = -1
+				c.Spec.ClusterDomain = "example.org"
+			},
+			config: "server.1=zk-0.zk-headless.default.svc.example.org:2889:3889:participant\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testCluster(tt.size, nil)
+			if tt.mutate != nil {
+				tt.mutate(c)
+			}
+			if config := createDynamicConfig(c); config != tt.config {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.config, config)
+			}
+		})
+	}
+}
+
+func TestParseZxid(t *testing.T) {
+	tests := map[string]int64{
+		"0x100000005":        0x100000005,
+		"200000002":          0x200000002,
+		"0x0":                0,
+		"0xffffffffffffffff": -1,
+		"":                   -1,
+		"0x":                 -1,
+		"unknown":            -1,
+	}
+	for zxid, expected := range tests {
+		if value := parseZxid(zxid); value != expected {
+			t.Errorf("expected %q to parse to %d, got %d", zxid, expected, value)
+		}
+	}
+}
+
+func TestZxidLag(t *testing.T) {
+	tests := []struct {
+		name           string
+		member, leader int64
+		lag            string
+	}{
+		{"caught up", 0x300000010, 0x300000010, ""},
+		{"within the threshold", 0x300000010, 0x300000010 + maxCatchUpTransactions, ""},
+		{"ahead of the read leader zxid", 0x300000020, 0x300000010, ""},
+		{"beyond the threshold", 0x300000010, 0x300000011 + maxCatchUpTransactions, "1001 transactions behind"},
+		{"previous epoch", 0x2000000ff, 0x300000001, "in the epoch 2 instead of 3"},
+		{"new epoch of the leader only", 0x300000000, 0x400000000, "in the epoch 3 instead of 4"},
+		{"unknown member zxid", -1, 0x300000001, "the zxids are unknown"},
+		{"unknown leader zxid", 0x300000001, -1, "the zxids are unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if lag := zxidLag(tt.member, tt.leader); lag != tt.lag {
+				t.Errorf("expected %q, got %q", tt.lag, lag)
+			}
+		})
+	}
+}
+
+func TestMemberLag(t *testing.T) {
+	roles := map[int32]v1alpha1.MemberRole{
+		1: v1alpha1.MemberRoleLeader,
+		2: v1alpha1.MemberRoleFollower,
+		3: v1alpha1.MemberRoleObserver,
+	}
+	c := testCluster(4, roles)
+	c.Status.Members[0].Zxid = "0x300002000"
+	c.Status.Members[1].Zxid = "0x300001f00"
+	c.Status.Members[2].Zxid = "0x200000010"
+	tests := map[int32]string{
+		2: "",
+		3: "waiting for the member 3 to catch up with the leader: in the epoch 2 instead of 3",
+		4: "waiting for the member 4 to be ready",
+		5: "waiting for the member 5 to be ready",
+	}
+	for id, expected := range tests {
+		if lag := memberLag(c, id); lag != expected {
+			t.Errorf("expected %q for the member %d, got %q", expected, id, lag)
+		}
+	}
+	c.Status.Members[0].Role = v1alpha1.MemberRoleUnknown
+	if lag := memberLag(c, 2); lag != "waiting for the member 2 to catch up with the leader" {
+		t.Errorf("expected the member to wait for a leader, got %q", lag)
+	}
+}
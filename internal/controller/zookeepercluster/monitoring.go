@@ -0,0 +1,232 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	podMonitorGVK = schema.GroupVersionKind{
+		Group:   "monitoring.coreos.com",
+		Version: "v1",
+		Kind:    "PodMonitor",
+	}
+	prometheusRuleGVK = schema.GroupVersionKind{
+		Group:   "monitoring.coreos.com",
+		Version: "v1",
+		Kind:    "PrometheusRule",
+	}
+)
+
+const (
+	// outstandingRequestsThreshold is the number of queued requests a member alerts above
+	outstandingRequestsThreshold = 10
+	// fsyncLatencyThresholdMs is the average fsync time a member alerts above
+	fsyncLatencyThresholdMs = 100
+	// leaderElectionsThreshold is the number of leader elections per hour a member alerts above
+	leaderElectionsThreshold = 3
+)
+
+// ReconcileMonitoring reconcile the PodMonitor and PrometheusRule of the specified cluster.
+// They're skipped when the prometheus-operator resources aren't installed
+func ReconcileMonitoring(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error {
+	if !cluster.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	monitoring := cluster.Spec.Monitoring
+	if err := reconcileMonitoringObject(ctx, cluster, podMonitorGVK,
+		monitoring != nil && monitoring.PodMonitor, createPodMonitorSpec); err != nil {
+		return err
+	}
+	return reconcileMonitoringObject(ctx, cluster, prometheusRuleGVK,
+		monitoring != nil && monitoring.PrometheusRule, createPrometheusRuleSpec)
+}
+
+// reconcileMonitoringObject creates or updates the enabled monitoring object, and deletes the disabled one
+func reconcileMonitoringObject(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster, gvk schema.GroupVersionKind,
+	enabled bool, createSpec func(c *v1alpha1.ZookeeperCluster) map[string]interface{}) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	err := ctx.GetResource(types.NamespacedName{
+		Name:      cluster.GetName(),
+		Namespace: cluster.Namespace,
+	}, obj,
+		// Found
+		func() error {
+			if !enabled {
+				ctx.Logger().Info("Deleting the disabled zookeeper monitoring object.",
+					"Kind", gvk.Kind, "Name", obj.GetName(), "Namespace", obj.GetNamespace())
+				if err := ctx.Client().Delete(context.TODO(), obj); err != nil && !errors.IsNotFound(err) {
+					return err
+				}
+				return nil
+			}
+			spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+			desired := createSpec(cluster)
+			labels := monitoringLabels(cluster)
+			if containsAll(spec, desired) && containsAllLabels(obj.GetLabels(), labels) {
+				return nil
+			}
+			for key, value := range desired {
+				spec[key] = value
+			}
+			obj.Object["spec"] = spec
+			obj.SetLabels(internal.MergeLabels(obj.GetLabels(), labels))
+			ctx.Logger().Info("Updating the zookeeper monitoring object.",
+				"Kind", gvk.Kind, "Name", obj.GetName(), "Namespace", obj.GetNamespace())
+			if err := ctx.Client().Update(context.TODO(), obj); err != nil {
+				return err
+			}
+			recordEvent(ctx, cluster, v1.EventTypeNormal, eventReasonUpdated, "Updated the %s %s", gvk.Kind, obj.GetName())
+			return nil
+		},
+		// Not Found
+		func() error {
+			if !enabled {
+				return nil
+			}
+			obj = &unstructured.Unstructured{Object: map[string]interface{}{
+				"spec": createSpec(cluster),
+			}}
+			obj.SetGroupVersionKind(gvk)
+			obj.SetName(cluster.GetName())
+			obj.SetNamespace(cluster.Namespace)
+			obj.SetLabels(monitoringLabels(cluster))
+			if err := ctx.SetOwnershipReference(cluster, obj); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the zookeeper monitoring object.",
+				"Kind", gvk.Kind, "Name", obj.GetName(), "Namespace", obj.GetNamespace())
+			if err := ctx.Client().Create(context.TODO(), obj); err != nil {
+				return err
+			}
+			recordEvent(ctx, cluster, v1.EventTypeNormal, eventReasonCreated, "Created the %s %s", gvk.Kind, obj.GetName())
+			return nil
+		})
+	if meta.IsNoMatchError(err) {
+		if enabled {
+			ctx.Logger().Info("The prometheus-operator resource is not installed; skipping it",
+				"cluster", cluster.GetName(), "kind", gvk.Kind)
+		}
+		return nil
+	}
+	return err
+}
+
+func monitoringLabels(c *v1alpha1.ZookeeperCluster) map[string]string {
+	return internal.MergeLabels(c.GenerateLabels(), c.Spec.Monitoring.Labels)
+}
+
+func createPodMonitorSpec(c *v1alpha1.ZookeeperCluster) map[string]interface{} {
+	return map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": toInterfaceMap(c.SelectorLabels()),
+		},
+		"namespaceSelector": map[string]interface{}{
+			"matchNames": []interface{}{c.Namespace},
+		},
+		"podMetricsEndpoints": []interface{}{
+			map[string]interface{}{
+				"port":     v1alpha1.ServiceMetricsPortName,
+				"path":     "/metrics",
+				"interval": c.Spec.Monitoring.ScrapeInterval,
+			},
+		},
+	}
+}
+
+// createPrometheusRuleSpec creates the alerts on the metrics scraped by the PodMonitor, whose
+// job label is <namespace>/<name>. The quorum loss is relative to the size of the cluster; it's
+// counted as zero members up when none is scraped, and isn't alerted on a cluster scaled to zero
+func createPrometheusRuleSpec(c *v1alpha1.ZookeeperCluster) map[string]interface{} {
+	selector := fmt.Sprintf(`namespace=%q, job="%s/%s"`, c.Namespace, c.Namespace, c.GetName())
+	var rules []interface{}
+	if size := *c.Spec.Size; size > 0 {
+		quorum := size/2 + 1
+		// The up series vanish with the pods, which would leave the sum empty on a full outage
+		rules = append(rules, alertRule("ZookeeperQuorumLost", "critical", "2m",
+			fmt.Sprintf("(sum(up{%s}) or vector(0)) < %d", selector, quorum),
+			fmt.Sprintf("The zookeeper ensemble %s/%s lost its quorum", c.Namespace, c.GetName()),
+			fmt.Sprintf("Less than %d members of the %d member ensemble are up.", quorum, size)))
+	}
+	rules = append(rules,
+		alertRule("ZookeeperOutstandingRequests", "warning", "5m",
+			fmt.Sprintf("outstanding_requests{%s} > %d", selector, outstandingRequestsThreshold),
+			"The zookeeper member {{ $labels.pod }} queues requests",
+			"The member has {{ $value }} outstanding requests; it can't keep up with the load."),
+		alertRule("ZookeeperFsyncLatencyHigh", "warning", "5m",
+			fmt.Sprintf("rate(fsynctime_sum{%s}[5m]) / rate(fsynctime_count{%s}[5m]) > %d",
+				selector, selector, fsyncLatencyThresholdMs),
+			"The zookeeper member {{ $labels.pod }} syncs its transaction log slowly",
+			"The average fsync time is {{ $value }}ms; the disk slows the writes of the ensemble down."),
+		alertRule("ZookeeperLeaderFlapping", "warning", "0m",
+			fmt.Sprintf("increase(election_time_count{%s}[1h]) > %d", selector, leaderElectionsThreshold),
+			"The zookeeper member {{ $labels.pod }} elects leaders frequently",
+			"The member went through {{ $value }} leader elections in the last hour."),
+	)
+	return map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{
+				"name":  fmt.Sprintf("zookeeper-%s", c.GetName()),
+				"rules": rules,
+			},
+		},
+	}
+}
+
+func alertRule(name, severity, pending, expr, summary, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"alert": name,
+		"expr":  expr,
+		"for":   pending,
+		"labels": map[string]interface{}{
+			"severity": severity,
+		},
+		"annotations": map[string]interface{}{
+			"summary":     summary,
+			"description": description,
+		},
+	}
+}
+
+func toInterfaceMap(values map[string]string) map[string]interface{} {
+	m := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		m[key] = value
+	}
+	return m
+}
+
+func containsAllLabels(actual, expected map[string]string) bool {
+	for key, value := range expected {
+		if actual[key] != value {
+			return false
+		}
+	}
+	return true
+}
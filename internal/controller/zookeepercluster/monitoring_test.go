@@ -0,0 +1,46 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"testing"
+)
+
+func TestCreatePrometheusRuleSpecQuorumLost(t *testing.T) {
+	tests := []struct {
+		size int32
+		expr string
+	}{
+		{3, `(sum(up{namespace="default", job="default/zk"}) or vector(0)) < 2`},
+		{5, `(sum(up{namespace="default", job="default/zk"}) or vector(0)) < 3`},
+		{1, `(sum(up{namespace="default", job="default/zk"}) or vector(0)) < 1`},
+		{0, ""},
+	}
+	for _, tt := range tests {
+		spec := createPrometheusRuleSpec(testCluster(tt.size, nil))
+		group := spec["groups"].([]interface{})[0].(map[string]interface{})
+		expr := ""
+		for _, rule := range group["rules"].([]interface{}) {
+			if rule := rule.(map[string]interface{}); rule["alert"] == "ZookeeperQuorumLost" {
+				expr = rule["expr"].(string)
+			}
+		}
+		if expr != tt.expr {
+			t.Errorf("expected the quorum expression %q for the size %d, got %q", tt.expr, tt.size, expr)
+		}
+	}
+}
@@ -17,9 +17,9 @@
 package zookeepercluster
 
 import (
-	"context"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	v12 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -34,35 +34,36 @@ func ReconcilePodDisruptionBudget(ctx reconciler.Context, cluster *v1alpha1.Zook
 
 func reconcilePodDisruptionBudget(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) (err error) {
 	pdb := &v1.PodDisruptionBudget{}
+	desired := createPodDisruptionBudget(cluster)
 	return ctx.GetResource(types.NamespacedName{
 		Name:      cluster.Name,
 		Namespace: cluster.Namespace,
 	}, pdb,
 		func() error {
-			newMaxFailureNodes := calculateMaxAllowedFailureNodes(cluster)
-			if newMaxFailureNodes.IntVal != pdb.Spec.MaxUnavailable.IntVal {
-				pdb.Spec.MaxUnavailable.IntVal = newMaxFailureNodes.IntVal
-				ctx.Logger().Info("Updating the zookeeper poddisruptionbudget for cluster",
+			changed, err := applyObject(ctx, cluster, desired, pdb)
+			if err == nil && changed {
+				ctx.Logger().Info("Updated the zookeeper poddisruptionbudget for cluster",
 					"cluster", cluster.Name,
-					"PodDisruptionBudget.Name", pdb.GetName(),
-					"PodDisruptionBudget.Namespace", pdb.GetNamespace(),
-					"MaxUnavailable", pdb.Spec.MaxUnavailable.IntVal)
-				return ctx.Client().Update(context.TODO(), pdb)
+					"PodDisruptionBudget.Name", desired.GetName(),
+					"PodDisruptionBudget.Namespace", desired.GetNamespace(),
+					"MaxUnavailable", desired.Spec.MaxUnavailable.IntVal)
+				recordEvent(ctx, cluster, v12.EventTypeNormal, eventReasonUpdated,
+					"Updated the poddisruptionbudget %s to allow %d unavailable members", desired.Name, desired.Spec.MaxUnavailable.IntVal)
 			}
-			return nil
+			return err
 		},
 		// Not Found
 		func() error {
-			pdb = createPodDisruptionBudget(cluster)
-			if err := ctx.SetOwnershipReference(cluster, pdb); err != nil {
-				return err
-			}
 			ctx.Logger().Info("Creating the zookeeper poddisruptionbudget for cluster",
 				"cluster", cluster.Name,
-				"PodDisruptionBudget.Name", pdb.GetName(),
-				"PodDisruptionBudget.Namespace", pdb.GetNamespace(),
-				"MaxUnavailable", pdb.Spec.MaxUnavailable.IntVal)
-			return ctx.Client().Create(context.TODO(), pdb)
+				"PodDisruptionBudget.Name", desired.GetName(),
+				"PodDisruptionBudget.Namespace", desired.GetNamespace(),
+				"MaxUnavailable", desired.Spec.MaxUnavailable.IntVal)
+			if _, err := applyObject(ctx, cluster, desired, nil); err != nil {
+				return err
+			}
+			recordEvent(ctx, cluster, v12.EventTypeNormal, eventReasonCreated, "Created the poddisruptionbudget %s", desired.Name)
+			return nil
 		},
 	)
 }
@@ -89,11 +90,12 @@ func createPodDisruptionBudget(cluster *v1alpha1.ZookeeperCluster) *v1.PodDisrup
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: cluster.Namespace,
 			Name:      cluster.Name,
+			Labels:    cluster.GenerateLabels(),
 		},
 		Spec: v1.PodDisruptionBudgetSpec{
 			MaxUnavailable: &newMaxFailureNodes,
 			Selector: &metav1.LabelSelector{
-				MatchLabels: cluster.GenerateLabels(),
+				MatchLabels: cluster.SelectorLabels(),
 			},
 		},
 	}
@@ -0,0 +1,268 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"time"
+)
+
+const (
+	rolloutRequeueDelay = 10 * time.Second
+)
+
+// stepRollout restarts the members running an outdated pod template, the statefulset being
+// updated OnDelete. The followers are restarted one at a time, each once the previous one rejoined
+// the ensemble and caught up with the leader, and the leader last. When a restarted member doesn't
+// catch up within the progress deadline, a version upgrade is rolled back to the last known-good
+// version and any other rollout is paused; it resumes once the member catches up
+func stepRollout(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, sts *v1.StatefulSet) error {
+	if !c.DeletionTimestamp.IsZero() || sts.Status.ObservedGeneration < sts.Generation {
+		return nil
+	}
+	oldStatus := c.Status.DeepCopy()
+	err := rolloutNextMember(ctx, c, sts)
+	if !equality.Semantic.DeepEqual(oldStatus, &c.Status) {
+		ctx.Logger().Info("Updating the cluster rollout status",
+			"cluster", c.GetName(), "rollout", c.Status.Rollout, "upgrade", c.Status.Upgrade)
+		if updateErr := ctx.Client().Status().Update(context.TODO(), c); updateErr != nil && err == nil {
+			err = updateErr
+		}
+	}
+	return err
+}
+
+func rolloutNextMember(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, sts *v1.StatefulSet) error {
+	revision := sts.Status.UpdateRevision
+	pods, err := pod.ListAllWithMatchingLabels(ctx.Client(), c.Namespace, c.SelectorLabels())
+	if err != nil {
+		return err
+	}
+	rollout := c.Status.Rollout
+	if rollout != nil && rollout.UpdateRevision == revision && rollout.Pod != "" {
+		if reason := restartedMemberLag(ctx, c, rollout.Pod, revision); reason != "" {
+			return waitRestartedMember(ctx, c, rollout, reason)
+		}
+		ctx.Logger().Info("The restarted member caught up with the leader", "cluster", c.GetName(), "pod", rollout.Pod)
+		rollout.Pod = ""
+		rollout.StepStartTime = nil
+		rollout.Phase = v1alpha1.RolloutPhaseProgressing
+		rollout.Message = ""
+	}
+	outdated := outdatedMembers(pods.Items, revision)
+	if len(outdated) == 0 {
+		if rollout != nil && (rollout.Phase != v1alpha1.RolloutPhaseCompleted || rollout.UpdateRevision != revision) {
+			ctx.Logger().Info("The rollout is completed", "cluster", c.GetName(), "revision", revision)
+			c.Status.Rollout = &v1alpha1.RolloutStatus{UpdateRevision: revision, Phase: v1alpha1.RolloutPhaseCompleted}
+		}
+		recordKnownGoodVersion(ctx, c, sts)
+		return nil
+	}
+	if rollout == nil || rollout.UpdateRevision != revision {
+		ctx.Logger().Info("Starting the rollout of the members", "cluster", c.GetName(),
+			"revision", revision, "outdated", len(outdated), "version", c.TargetVersion())
+		rollout = &v1alpha1.RolloutStatus{UpdateRevision: revision, Phase: v1alpha1.RolloutPhaseProgressing}
+		c.Status.Rollout = rollout
+		recordEvent(ctx, c, v12.EventTypeNormal, eventReasonRolloutStarted,
+			"Restarting the %d outdated members one at a time, the leader last", len(outdated))
+	}
+	next := nextRolloutMember(c, outdated)
+	// Restarting a member which isn't ready doesn't weaken the ensemble; a rollback
+	// would otherwise wait forever on the member which didn't rejoin
+	if pod.IsReady(next) {
+		replicas := *sts.Spec.Replicas
+		if sts.Status.ReadyReplicas < replicas {
+			return requeue.After(rolloutRequeueDelay, "waiting for the members to be ready before restarting the next one")
+		}
+		if replicas > 1 {
+			if err = checkEnsembleSynced(ctx, c, replicas); err != nil {
+				ctx.Logger().Info("The ensemble is not ready to restart a member",
+					"cluster", c.GetName(), "reason", err)
+				return requeue.After(rolloutRequeueDelay, err.Error())
+			}
+		}
+	}
+	ctx.Logger().Info("Restarting the outdated member", "cluster", c.GetName(),
+		"pod", next.Name, "revision", revision, "remaining", len(outdated))
+	if err = ctx.Client().Delete(context.TODO(), next); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error restarting the member pod %s: %w", next.Name, err)
+	}
+	now := metav1.Now()
+	rollout.Pod = next.Name
+	rollout.StepStartTime = &now
+	rollout.Message = fmt.Sprintf("restarting %s; %d outdated members", next.Name, len(outdated))
+	return requeue.After(rolloutRequeueDelay, "rollout in progress")
+}
+
+// waitRestartedMember waits for the restarted member to catch up. Past the progress deadline,
+// a version upgrade is rolled back unless disabled, and the rollout is paused otherwise
+func waitRestartedMember(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, rollout *v1alpha1.RolloutStatus, reason string) error {
+	if rollout.StepStartTime == nil || time.Since(rollout.StepStartTime.Time) <= c.RolloutProgressDeadline() {
+		return requeue.After(rolloutRequeueDelay, reason)
+	}
+	if rollbackUpgrade(ctx, c, reason) {
+		return requeue.After(rolloutRequeueDelay, "rolling back the stalled upgrade")
+	}
+	if rollout.Phase != v1alpha1.RolloutPhasePaused {
+		rollout.Phase = v1alpha1.RolloutPhasePaused
+		rollout.Message = fmt.Sprintf("the rollout is paused: %s since %s",
+			reason, rollout.StepStartTime.Format(time.RFC3339))
+		ctx.Logger().Info("Pausing the stalled rollout", "cluster", c.GetName(), "pod", rollout.Pod, "reason", reason)
+		recordEvent(ctx, c, v12.EventTypeWarning, eventReasonRolloutPaused,
+			"The rollout is paused: %s since %s", reason, rollout.StepStartTime.Format(time.RFC3339))
+	}
+	return requeue.After(rolloutRequeueDelay, reason)
+}
+
+// rollbackUpgrade rolls the stalled version upgrade back to the last known-good version. It returns
+// false when the rollout isn't a version upgrade, the rollback is disabled or already happened
+func rollbackUpgrade(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, reason string) bool {
+	upgrade := c.Status.Upgrade
+	version := c.TargetVersion()
+	if !c.IsUpgradeRollbackEnabled() || upgrade == nil ||
+		upgrade.LastKnownGoodVersion == "" || upgrade.LastKnownGoodVersion == version {
+		return false
+	}
+	ctx.Logger().Info("Rolling back the stalled upgrade", "cluster", c.GetName(),
+		"version", version, "lastKnownGoodVersion", upgrade.LastKnownGoodVersion, "reason", reason)
+	now := metav1.Now()
+	upgrade.RolledBackVersion = version
+	upgrade.RollbackTime = &now
+	recordEvent(ctx, c, v12.EventTypeWarning, eventReasonUpgradeRolledBack,
+		"The upgrade to %s stalled: %s; rolling the members back to %s", version, reason, upgrade.LastKnownGoodVersion)
+	return true
+}
+
+// recordKnownGoodVersion records the version the members run with as known-good
+// once all of them are ready and following a leader
+func recordKnownGoodVersion(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, sts *v1.StatefulSet) {
+	replicas := *sts.Spec.Replicas
+	if replicas == 0 || sts.Status.ReadyReplicas < replicas || c.Status.Leader() == nil {
+		return
+	}
+	version := c.TargetVersion()
+	if c.Status.Upgrade == nil {
+		c.Status.Upgrade = &v1alpha1.UpgradeStatus{}
+	}
+	c.Status.Upgrade.LastKnownGoodImage = c.Image()
+	previous := c.Status.Upgrade.LastKnownGoodVersion
+	if previous == version {
+		return
+	}
+	c.Status.Upgrade.LastKnownGoodVersion = version
+	if previous != "" {
+		ctx.Logger().Info("The upgrade is completed", "cluster", c.GetName(), "from", previous, "to", version)
+		recordEvent(ctx, c, v12.EventTypeNormal, eventReasonUpgradeCompleted,
+			"The members were upgraded from %s to %s", previous, version)
+	}
+}
+
+// outdatedMembers returns the pods not running the update revision
+func outdatedMembers(pods []v12.Pod, revision string) []*v12.Pod {
+	var outdated []*v12.Pod
+	for i := range pods {
+		if pods[i].Labels[v1.ControllerRevisionHashLabelKey] != revision && pods[i].DeletionTimestamp.IsZero() {
+			outdated = append(outdated, &pods[i])
+		}
+	}
+	return outdated
+}
+
+// nextRolloutMember returns the outdated member which isn't ready, else the outdated follower
+// with the highest ordinal, or the leader when it's the last one
+func nextRolloutMember(c *v1alpha1.ZookeeperCluster, outdated []*v12.Pod) *v12.Pod {
+	leader := ""
+	if l := c.Status.Leader(); l != nil {
+		leader = l.Pod
+	}
+	sort.Slice(outdated, func(i, j int) bool {
+		if pod.IsReady(outdated[i]) != pod.IsReady(outdated[j]) {
+			return !pod.IsReady(outdated[i])
+		}
+		if (outdated[i].Name == leader) != (outdated[j].Name == leader) {
+			return outdated[j].Name == leader
+		}
+		idI, _ := podMyID(c, outdated[i].Name)
+		idJ, _ := podMyID(c, outdated[j].Name)
+		return idI > idJ
+	})
+	return outdated[0]
+}
+
+// restartedMemberLag returns why the restarted member hasn't rejoined the ensemble yet, or empty if it has:
+// it runs the update revision, is ready and its zxid is close to the leader one
+func restartedMemberLag(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, name, revision string) string {
+	p := &v12.Pod{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{Name: name, Namespace: c.Namespace}, p)
+	switch {
+	case errors.IsNotFound(err):
+		return fmt.Sprintf("waiting for the member pod %s to be recreated", name)
+	case err != nil:
+		return fmt.Sprintf("error getting the member pod %s: %s", name, err)
+	case p.Labels[v1.ControllerRevisionHashLabelKey] != revision || !p.DeletionTimestamp.IsZero():
+		return fmt.Sprintf("waiting for the member pod %s to be recreated", name)
+	case !pod.IsReady(p) || p.Status.PodIP == "":
+		return fmt.Sprintf("waiting for the member pod %s to be ready", name)
+	}
+	endpoint, err := zk.NewEndpoint(ctx.Client(), c)
+	if err != nil {
+		return err.Error()
+	}
+	stat, err := endpoint.Srvr(p.Status.PodIP)
+	if err != nil {
+		return fmt.Sprintf("the member %s is not serving: %s", name, err)
+	}
+	leaderStat, err := leaderSrvr(ctx, c, endpoint)
+	if err != nil {
+		return err.Error()
+	}
+	if lag := zxidLag(stat.Zxid, leaderStat.Zxid); lag != "" {
+		return fmt.Sprintf("waiting for the member %s to catch up with the leader: %s", name, lag)
+	}
+	return ""
+}
+
+// leaderSrvr queries the current leader state
+func leaderSrvr(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, endpoint *zk.Endpoint) (*zk.ServerStat, error) {
+	pods, err := pod.ListAllWithMatchingLabels(ctx.Client(), c.Namespace, c.SelectorLabels())
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		if !pod.IsReady(p) || p.Status.PodIP == "" {
+			continue
+		}
+		if stat, err := endpoint.Srvr(p.Status.PodIP); err == nil && stat.Mode == string(v1alpha1.MemberRoleLeader) {
+			return stat, nil
+		}
+	}
+	return nil, fmt.Errorf("no member reported itself as the ensemble leader")
+}
@@ -0,0 +1,409 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPod(name string, ready bool) *v12.Pod {
+	status := v12.ConditionFalse
+	if ready {
+		status = v12.ConditionTrue
+	}
+	return &v12.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status:     v12.PodStatus{Conditions: []v12.PodCondition{{Type: v12.PodReady, Status: status}}},
+	}
+}
+
+// testMemberPod returns a member pod of the cluster running the revision
+func testMemberPod(c *v1alpha1.ZookeeperCluster, myid int32, revision string) *v12.Pod {
+	p := testPod(c.MemberPodName(myid), true)
+	p.Labels = c.GenerateLabels()
+	p.Labels[v1.ControllerRevisionHashLabelKey] = revision
+	return p
+}
+
+// testRolloutStatefulSet returns the statefulset of the cluster rolling out the update revision
+func testRolloutStatefulSet(c *v1alpha1.ZookeeperCluster) *v1.StatefulSet {
+	return &v1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: c.Name, Namespace: c.Namespace},
+		Spec:       v1.StatefulSetSpec{Replicas: c.Spec.Size},
+		Status: v1.StatefulSetStatus{
+			Replicas:        *c.Spec.Size,
+			ReadyReplicas:   *c.Spec.Size,
+			CurrentRevision: "zk-1",
+			UpdateRevision:  "zk-2",
+		},
+	}
+}
+
+func TestNextRolloutMember(t *testing.T) {
+	roles := map[int32]v1alpha1.MemberRole{
+		1: v1alpha1.MemberRoleFollower,
+		2: v1alpha1.MemberRoleLeader,
+		3: v1alpha1.MemberRoleFollower,
+		4: v1alpha1.MemberRoleFollower,
+		5: v1alpha1.MemberRoleObserver,
+	}
+	tests := []struct {
+		name     string
+		outdated []*v12.Pod
+		next     string
+	}{
+		{
+			name: "the highest ordinal follower",
+			outdated: []*v12.Pod{
+				testPod("zk-0", true), testPod("zk-1", true), testPod("zk-2", true), testPod("zk-3", true),
+			},
+			next: "zk-3",
+		},
+		{
+			name: "the highest ordinal member is an observer",
+			outdated: []*v12.Pod{
+				testPod("zk-4", true), testPod("zk-1", true), testPod("zk-0", true),
+			},
+			next: "zk-4",
+		},
+		{
+			name: "the ordinals compared as numbers",
+			outdated: []*v12.Pod{
+				testPod("zk-2", true), testPod("zk-10", true), testPod("zk-9", true),
+			},
+			next: "zk-10",
+		},
+		{
+			name: "the not ready member first",
+			outdated: []*v12.Pod{
+				testPod("zk-3", true), testPod("zk-0", false), testPod("zk-2", true),
+			},
+			next: "zk-0",
+		},
+		{
+			name: "the not ready leader first",
+			outdated: []*v12.Pod{
+				testPod("zk-3", true), testPod("zk-1", false),
+			},
+			next: "zk-1",
+		},
+		{
+			name: "the leader last",
+			outdated: []*v12.Pod{
+				testPod("zk-1", true), testPod("zk-0", true),
+			},
+			next: "zk-0",
+		},
+		{
+			name:     "the leader alone",
+			outdated: []*v12.Pod{testPod("zk-1", true)},
+			next:     "zk-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if next := nextRolloutMember(testCluster(5, roles), tt.outdated); next.Name != tt.next {
+				t.Errorf("expected %s, got %s", tt.next, next.Name)
+			}
+		})
+	}
+}
+
+func TestNextRolloutMemberWithoutLeader(t *testing.T) {
+	c := testCluster(3, map[int32]v1alpha1.MemberRole{})
+	outdated := []*v12.Pod{testPod("zk-0", true), testPod("zk-2", true), testPod("zk-1", true)}
+	if next := nextRolloutMember(c, outdated); next.Name != "zk-2" {
+		t.Errorf("expected zk-2, got %s", next.Name)
+	}
+}
+
+func TestOutdatedMembers(t *testing.T) {
+	c := testCluster(3, nil)
+	deleted := testMemberPod(c, 3, "zk-1")
+	now := metav1.Now()
+	deleted.DeletionTimestamp = &now
+	pods := []v12.Pod{*testMemberPod(c, 1, "zk-2"), *testMemberPod(c, 2, "zk-1"), *deleted}
+	outdated := outdatedMembers(pods, "zk-2")
+	if len(outdated) != 1 || outdated[0].Name != "zk-1" {
+		t.Errorf("expected only zk-1 to be outdated, got %v", outdated)
+	}
+}
+
+func TestStepRolloutCompleted(t *testing.T) {
+	c := testCluster(1, nil)
+	c.Status.Rollout = &v1alpha1.RolloutStatus{UpdateRevision: "zk-2", Phase: v1alpha1.RolloutPhaseProgressing}
+	ctx := reconcilertest.NewContext(c, testMemberPod(c, 1, "zk-2"))
+	if err := stepRollout(ctx, c, testRolloutStatefulSet(c)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Status.Rollout.Phase != v1alpha1.RolloutPhaseCompleted {
+		t.Errorf("expected the rollout to be completed, got %+v", c.Status.Rollout)
+	}
+}
+
+func TestStepRolloutRestartsTheMember(t *testing.T) {
+	c := testCluster(1, nil)
+	member := testMemberPod(c, 1, "zk-1")
+	ctx := reconcilertest.NewContext(c, member)
+	err := stepRollout(ctx, c, testRolloutStatefulSet(c))
+	if _, ok := requeue.Delay(err); !ok {
+		t.Fatalf("expected a requeue while the member restarts, got %v", err)
+	}
+	rollout := c.Status.Rollout
+	if rollout == nil || rollout.Phase != v1alpha1.RolloutPhaseProgressing || rollout.Pod != "zk-0" || rollout.StepStartTime == nil {
+		t.Fatalf("expected the rollout to restart zk-0, got %+v", rollout)
+	}
+	if err = ctx.Client().Get(context.TODO(), client.ObjectKeyFromObject(member), &v12.Pod{}); err == nil {
+		t.Error("expected the outdated member pod to be deleted")
+	}
+	stored := &v1alpha1.ZookeeperCluster{}
+	if err = ctx.Client().Get(context.TODO(), client.ObjectKeyFromObject(c), stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status.Rollout == nil || stored.Status.Rollout.Pod != "zk-0" {
+		t.Errorf("expected the rollout status to be saved, got %+v", stored.Status.Rollout)
+	}
+}
+
+// testStalledRollout returns a cluster upgraded to 3.8.4 whose restarted member didn't rejoin
+// the ensemble within the progress deadline, the members previously running 3.6.3
+func testStalledRollout(mutate func(c *v1alpha1.ZookeeperCluster)) *v1alpha1.ZookeeperCluster {
+	c := testCluster(2, map[int32]v1alpha1.MemberRole{1: v1alpha1.MemberRoleLeader})
+	c.Spec.ZookeeperVersion = "3.8.4"
+	c.Status.Upgrade = &v1alpha1.UpgradeStatus{LastKnownGoodVersion: "3.6.3"}
+	started := metav1.NewTime(time.Now().Add(-c.RolloutProgressDeadline() - time.Minute))
+	c.Status.Rollout = &v1alpha1.RolloutStatus{
+		UpdateRevision: "zk-2",
+		Phase:          v1alpha1.RolloutPhaseProgressing,
+		Pod:            "zk-1",
+		StepStartTime:  &started,
+	}
+	if mutate != nil {
+		mutate(c)
+	}
+	return c
+}
+
+// expectEvent checks the next recorded event has the type and reason
+func expectEvent(t *testing.T, ctx *reconcilertest.Context, eventType, reason string) {
+	t.Helper()
+	select {
+	case event := <-ctx.Recorder.Events:
+		if !strings.HasPrefix(event, eventType+" "+reason+" ") {
+			t.Errorf("expected a %s %s event, got %q", eventType, reason, event)
+		}
+	default:
+		t.Errorf("expected a %s %s event, got none", eventType, reason)
+	}
+}
+
+func TestStepRolloutRollsBackStalledUpgrade(t *testing.T) {
+	c := testStalledRollout(func(c *v1alpha1.ZookeeperCluster) {
+		c.Status.Upgrade.LastKnownGoodImage = "monime/zookeeper:3.6.3"
+		c.Spec.Image = &v1alpha1.Image{Repository: "mirror.example.com/zookeeper"}
+	})
+	if image := c.Image(); image != "mirror.example.com/zookeeper:3.8.4" {
+		t.Fatalf("expected the upgraded image, got %s", image)
+	}
+	// the restarted member is not recreated yet
+	ctx := reconcilertest.NewContext(c, testMemberPod(c, 1, "zk-1"))
+	err := stepRollout(ctx, c, testRolloutStatefulSet(c))
+	if _, ok := requeue.Delay(err); !ok {
+		t.Fatalf("expected a requeue, got %v", err)
+	}
+	if upgrade := c.Status.Upgrade; upgrade.RolledBackVersion != "3.8.4" || upgrade.RollbackTime == nil {
+		t.Errorf("expected the upgrade to 3.8.4 to be rolled back, got %+v", upgrade)
+	}
+	// the known-good image is kept even though the repository changed with the upgrade
+	if image := c.Image(); image != "monime/zookeeper:3.6.3" {
+		t.Errorf("expected the members to be rolled back to the known-good image, got %s", image)
+	}
+	if c.Status.Upgrade.LastKnownGoodVersion != "3.6.3" {
+		t.Errorf("expected the known-good version to be kept, got %s", c.Status.Upgrade.LastKnownGoodVersion)
+	}
+	if c.Status.Rollout.Phase == v1alpha1.RolloutPhasePaused {
+		t.Error("expected the rollback not to pause the rollout")
+	}
+	expectEvent(t, ctx, v12.EventTypeWarning, eventReasonUpgradeRolledBack)
+
+	// a new version is rolled out again
+	c.Spec.ZookeeperVersion = "3.8.3"
+	if image := c.Image(); image != "mirror.example.com/zookeeper:3.8.3" {
+		t.Errorf("expected the new version to be rolled out, got %s", image)
+	}
+}
+
+func TestStepRolloutPausesStalledRollout(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(c *v1alpha1.ZookeeperCluster)
+	}{
+		{
+			name: "rollback disabled",
+			mutate: func(c *v1alpha1.ZookeeperCluster) {
+				c.Spec.UpgradePolicy = &v1alpha1.UpgradePolicy{DisableRollback: true}
+			},
+		},
+		{
+			name: "not a version upgrade",
+			mutate: func(c *v1alpha1.ZookeeperCluster) {
+				c.Status.Upgrade.LastKnownGoodVersion = "3.8.4"
+			},
+		},
+		{
+			name: "already rolled back",
+			mutate: func(c *v1alpha1.ZookeeperCluster) {
+				c.Status.Upgrade.RolledBackVersion = "3.8.4"
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := testStalledRollout(test.mutate)
+			upgrade := c.Status.Upgrade.DeepCopy()
+			ctx := reconcilertest.NewContext(c, testMemberPod(c, 1, "zk-1"))
+			err := stepRollout(ctx, c, testRolloutStatefulSet(c))
+			if _, ok := requeue.Delay(err); !ok {
+				t.Fatalf("expected a requeue, got %v", err)
+			}
+			if c.Status.Rollout.Phase != v1alpha1.RolloutPhasePaused {
+				t.Errorf("expected the rollout to be paused, got %+v", c.Status.Rollout)
+			}
+			if c.Status.Upgrade.RolledBackVersion != upgrade.RolledBackVersion {
+				t.Errorf("expected no rollback, got %+v", c.Status.Upgrade)
+			}
+			expectEvent(t, ctx, v12.EventTypeWarning, eventReasonRolloutPaused)
+		})
+	}
+}
+
+func TestStepRolloutWaitsWithinTheDeadline(t *testing.T) {
+	c := testStalledRollout(func(c *v1alpha1.ZookeeperCluster) {
+		deadline := int32(3600)
+		c.Spec.UpgradePolicy = &v1alpha1.UpgradePolicy{ProgressDeadlineSeconds: &deadline}
+	})
+	ctx := reconcilertest.NewContext(c, testMemberPod(c, 1, "zk-1"))
+	err := stepRollout(ctx, c, testRolloutStatefulSet(c))
+	if _, ok := requeue.Delay(err); !ok {
+		t.Fatalf("expected a requeue, got %v", err)
+	}
+	if c.Status.Rollout.Phase != v1alpha1.RolloutPhaseProgressing || c.Status.Upgrade.RolledBackVersion != "" {
+		t.Errorf("expected the rollout to keep waiting, got %+v, %+v", c.Status.Rollout, c.Status.Upgrade)
+	}
+}
+
+func TestRecordKnownGoodVersion(t *testing.T) {
+	leader := map[int32]v1alpha1.MemberRole{1: v1alpha1.MemberRoleLeader}
+	tests := []struct {
+		name     string
+		roles    map[int32]v1alpha1.MemberRole
+		ready    int32
+		upgrade  *v1alpha1.UpgradeStatus
+		expected string
+		event    bool
+	}{
+		{
+			name:     "first known-good version",
+			roles:    leader,
+			ready:    3,
+			expected: "3.8.4",
+		},
+		{
+			name:     "completed upgrade",
+			roles:    leader,
+			ready:    3,
+			upgrade:  &v1alpha1.UpgradeStatus{LastKnownGoodVersion: "3.6.3"},
+			expected: "3.8.4",
+			event:    true,
+		},
+		{
+			name:     "member not ready",
+			roles:    leader,
+			ready:    2,
+			upgrade:  &v1alpha1.UpgradeStatus{LastKnownGoodVersion: "3.6.3"},
+			expected: "3.6.3",
+		},
+		{
+			name:     "ensemble without leader",
+			roles:    map[int32]v1alpha1.MemberRole{1: v1alpha1.MemberRoleFollower},
+			ready:    3,
+			upgrade:  &v1alpha1.UpgradeStatus{LastKnownGoodVersion: "3.6.3"},
+			expected: "3.6.3",
+		},
+		{
+			name:     "rolled back version",
+			roles:    leader,
+			ready:    3,
+			upgrade:  &v1alpha1.UpgradeStatus{LastKnownGoodVersion: "3.6.3", RolledBackVersion: "3.8.4"},
+			expected: "3.6.3",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := testCluster(3, test.roles)
+			c.Spec.ZookeeperVersion = "3.8.4"
+			c.Status.Upgrade = test.upgrade
+			sts := testRolloutStatefulSet(c)
+			sts.Status.ReadyReplicas = test.ready
+			ctx := reconcilertest.NewContext()
+			recordKnownGoodVersion(ctx, c, sts)
+			version := ""
+			if c.Status.Upgrade != nil {
+				version = c.Status.Upgrade.LastKnownGoodVersion
+			}
+			if version != test.expected {
+				t.Errorf("expected the known-good version %q, got %q", test.expected, version)
+			}
+			if test.event {
+				if image := c.Status.Upgrade.LastKnownGoodImage; image != c.Image() {
+					t.Errorf("expected the known-good image %s, got %s", c.Image(), image)
+				}
+				expectEvent(t, ctx, v12.EventTypeNormal, eventReasonUpgradeCompleted)
+			} else if len(ctx.Recorder.Events) > 0 {
+				t.Errorf("expected no event, got %q", <-ctx.Recorder.Events)
+			}
+		})
+	}
+}
+
+func TestConfigPodAnnotations(t *testing.T) {
+	c := testCluster(3, nil)
+	hash := configPodAnnotations(c)[configHashAnnotation]
+	if hash == "" || configPodAnnotations(c)[configHashAnnotation] != hash {
+		t.Fatalf("expected a stable config hash, got %q", hash)
+	}
+	// the membership changes are applied without restarting the members
+	size := int32(5)
+	c.Spec.Size = &size
+	if configPodAnnotations(c)[configHashAnnotation] != hash {
+		t.Error("expected the dynamic config to be excluded from the hash")
+	}
+	c.Spec.ZkConfig = "tickTime: \"3000\""
+	if configPodAnnotations(c)[configHashAnnotation] == hash {
+		t.Error("expected the zoo.cfg change to change the hash")
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"time"
+)
+
+const (
+	scalingRequeueDelay = 10 * time.Second
+)
+
+// desiredReplicas returns the replicas the statefulset should run with. A scale-down removes
+// one member at a time, and only when the remaining ensemble is healthy and in sync with the
+// leader; a requeue error is returned with the current replicas when the next step must wait
+func desiredReplicas(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, sts *v1.StatefulSet) (int32, error) {
+	current := *sts.Spec.Replicas
+	desired := *c.Spec.Size
+	if desired == 0 && current > 0 && !c.Spec.AllowScaleToZero {
+		// The size set through the scale subresource isn't validated by the webhook
+		ctx.Logger().Info("Keeping the last member; scaling to zero is not allowed", "cluster", c.GetName())
+		desired = 1
+	}
+	if desired >= current || !c.DeletionTimestamp.IsZero() {
+		return desired, nil
+	}
+	next := current - 1
+	if c.Status.Ensemble != nil && !c.Status.Ensemble.IsMember(current) {
+		// The member has already left the ensemble
+		return next, nil
+	}
+	if sts.Status.ReadyReplicas < current || isRollingOut(sts) {
+		return current, requeue.After(scalingRequeueDelay,
+			fmt.Sprintf("waiting for the %d members to be ready before removing a member", current))
+	}
+	if next > 0 {
+		if err := checkEnsembleSynced(ctx, c, current); err != nil {
+			ctx.Logger().Info("The ensemble is not ready to lose a member",
+				"cluster", c.GetName(), "replicas", current, "reason", err)
+			return current, requeue.After(scalingRequeueDelay, err.Error())
+		}
+		// The member leaves the ensemble before its pod is stopped; the last one has no ensemble to leave
+		if err := removeLeavingMember(ctx, c, current); err != nil {
+			return current, err
+		}
+	}
+	ctx.Logger().Info("Removing a member from the ensemble",
+		"cluster", c.GetName(), "from", current, "to", next, "target", desired)
+	return next, nil
+}
+
+// checkEnsembleSynced checks the ensemble has a leader which all the members are synced with
+func checkEnsembleSynced(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, members int32) error {
+	leader := c.Status.Leader()
+	if leader == nil {
+		return fmt.Errorf("the ensemble has no leader")
+	}
+	p := &v12.Pod{}
+	if err := ctx.Client().Get(context.TODO(), types.NamespacedName{Name: leader.Pod, Namespace: c.Namespace}, p); err != nil {
+		return fmt.Errorf("error getting the leader pod %s: %w", leader.Pod, err)
+	}
+	if !pod.IsReady(p) || p.Status.PodIP == "" {
+		return fmt.Errorf("the leader pod %s is not ready", leader.Pod)
+	}
+	endpoint, err := zk.NewEndpoint(ctx.Client(), c)
+	if err != nil {
+		return err
+	}
+	stat, err := endpoint.Leader(p.Status.PodIP)
+	if err != nil {
+		return err
+	}
+	expected := members
+	if !stat.ObserversReported {
+		// The 3.5 leaders only report their voting followers
+		expected = votingMembers(c, members)
+	}
+	if stat.PendingSyncs > 0 || stat.Synced() < expected {
+		return fmt.Errorf("%d of %d members are synced with the leader", stat.Synced(), expected)
+	}
+	return nil
+}
+
+// votingMembers returns the number of participants among the members, or the members if the ensemble isn't known yet
+func votingMembers(c *v1alpha1.ZookeeperCluster, members int32) int32 {
+	if c.Status.Ensemble == nil || len(c.Status.Ensemble.Participants) == 0 {
+		return members
+	}
+	voters := int32(0)
+	for _, id := range c.Status.Ensemble.Participants {
+		if id <= members {
+			voters++
+		}
+	}
+	return voters
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	v1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func testScalingCluster(size int32, participants []int32) *v1alpha1.ZookeeperCluster {
+	cluster := &v1alpha1.ZookeeperCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "zk", Namespace: "default"},
+		Spec:       v1alpha1.ZookeeperClusterSpec{Size: &size},
+	}
+	cluster.SetSpecDefaults()
+	if participants != nil {
+		cluster.Status.Ensemble = &v1alpha1.EnsembleStatus{Participants: participants}
+	}
+	return cluster
+}
+
+func testScalingStatefulSet(replicas, ready int32) *v1.StatefulSet {
+	return &v1.StatefulSet{
+		Spec: v1.StatefulSetSpec{Replicas: &replicas},
+		Status: v1.StatefulSetStatus{
+			Replicas:        replicas,
+			ReadyReplicas:   ready,
+			UpdatedReplicas: replicas,
+			CurrentRevision: "zk-1",
+			UpdateRevision:  "zk-1",
+		},
+	}
+}
+
+func TestDesiredReplicas(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int32
+		scaleToZero bool
+		ensemble    []int32
+		sts         *v1.StatefulSet
+		expected    int32
+		requeue     bool
+	}{
+		{
+			name:     "scale up at once",
+			size:     5,
+			ensemble: []int32{1, 2, 3},
+			sts:      testScalingStatefulSet(3, 3),
+			expected: 5,
+		},
+		{
+			name:     "scale down waits for the members to be ready",
+			size:     3,
+			ensemble: []int32{1, 2, 3, 4, 5},
+			sts:      testScalingStatefulSet(5, 4),
+			expected: 5,
+			requeue:  true,
+		},
+		{
+			name:     "scale down waits for the ensemble leader",
+			size:     3,
+			ensemble: []int32{1, 2, 3, 4, 5},
+			sts:      testScalingStatefulSet(5, 5),
+			expected: 5,
+			requeue:  true,
+		},
+		{
+			name:     "the member already left the ensemble",
+			size:     3,
+			ensemble: []int32{1, 2, 3, 4},
+			sts:      testScalingStatefulSet(5, 4),
+			expected: 4,
+		},
+		{
+			name:        "scale to zero stops the last member",
+			size:        0,
+			scaleToZero: true,
+			ensemble:    []int32{1},
+			sts:         testScalingStatefulSet(1, 1),
+			expected:    0,
+		},
+		{
+			name:     "scale to zero not allowed keeps the last member",
+			size:     0,
+			ensemble: []int32{1},
+			sts:      testScalingStatefulSet(1, 1),
+			expected: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := testScalingCluster(test.size, test.ensemble)
+			cluster.Spec.AllowScaleToZero = test.scaleToZero
+			ctx := reconcilertest.NewContext(cluster)
+			replicas, err := desiredReplicas(ctx, cluster, test.sts)
+			if _, ok := requeue.Delay(err); ok != test.requeue {
+				t.Errorf("expected requeue=%t, got %v", test.requeue, err)
+			}
+			if !test.requeue && err != nil {
+				t.Fatal(err)
+			}
+			if replicas != test.expected {
+				t.Errorf("expected %d replicas, got %d", test.expected, replicas)
+			}
+		})
+	}
+}
+
+func TestVotingMembers(t *testing.T) {
+	tests := []struct {
+		name     string
+		ensemble []int32
+		members  int32
+		expected int32
+	}{
+		{name: "unknown ensemble", members: 5, expected: 5},
+		{name: "observers", ensemble: []int32{1, 2, 3}, members: 5, expected: 3},
+		{name: "participants being removed", ensemble: []int32{1, 2, 3, 4, 5}, members: 3, expected: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := testScalingCluster(5, test.ensemble)
+			if voters := votingMembers(cluster, test.members); voters != test.expected {
+				t.Errorf("expected %d voting members, got %d", test.expected, voters)
+			}
+		})
+	}
+}
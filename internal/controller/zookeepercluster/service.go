@@ -17,7 +17,6 @@
 package zookeepercluster
 
 import (
-	"context"
 	"github.com/monimesl/operator-helper/k8s/service"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
@@ -27,57 +26,43 @@ import (
 
 // ReconcileServices reconcile the services of the specified cluster
 func ReconcileServices(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) (err error) {
-	if err = reconcileHeadlessService(ctx, cluster); err == nil {
-		err = reconcileClientService(ctx, cluster)
+	if err = reconcileService(ctx, cluster, createHeadlessService(cluster)); err == nil {
+		err = reconcileService(ctx, cluster, createClientService(cluster))
 	}
 	return
 }
 
-func reconcileClientService(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error {
+// reconcileService creates the service, or corrects its drift from the desired state
+func reconcileService(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster, desired *v1.Service) error {
 	svc := &v1.Service{}
 	return ctx.GetResource(types.NamespacedName{
-		Name:      cluster.ClientServiceName(),
-		Namespace: cluster.Namespace,
+		Name:      desired.Name,
+		Namespace: desired.Namespace,
 	}, svc,
-		nil,
-		// Not Found
-		func() (err error) {
-			svc = createClientService(cluster)
-			if err = ctx.SetOwnershipReference(cluster, svc); err == nil {
-				ctx.Logger().Info("Creating the zookeeper client service.",
-					"Service.Name", svc.GetName(),
-					"Service.Namespace", svc.GetNamespace())
-				if err = ctx.Client().Create(context.TODO(), svc); err == nil {
-					ctx.Logger().Info("Service creation success.",
-						"Service.Name", svc.GetName(),
-						"Service.Namespace", svc.GetNamespace())
-				}
+		// Found
+		func() error {
+			changed, err := applyObject(ctx, cluster, desired, svc)
+			if err == nil && changed {
+				ctx.Logger().Info("Zookeeper service updated to its desired state.",
+					"Service.Name", desired.GetName(),
+					"Service.Namespace", desired.GetNamespace())
+				recordEvent(ctx, cluster, v1.EventTypeNormal, eventReasonUpdated, "Updated the service %s", desired.Name)
 			}
-			return
-		})
-}
-
-func reconcileHeadlessService(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error {
-	svc := &v1.Service{}
-	return ctx.GetResource(types.NamespacedName{
-		Name:      cluster.HeadlessServiceName(),
-		Namespace: cluster.Namespace,
-	}, svc,
-		nil,
+			return err
+		},
 		// Not Found
-		func() (err error) {
-			svc = createHeadlessService(cluster)
-			if err = ctx.SetOwnershipReference(cluster, svc); err == nil {
-				ctx.Logger().Info("Creating the zookeeper headless service.",
-					"Service.Name", svc.GetName(),
-					"Service.Namespace", svc.GetNamespace())
-				if err = ctx.Client().Create(context.TODO(), svc); err == nil {
-					ctx.Logger().Info("Service creation success.",
-						"Service.Name", svc.GetName(),
-						"Service.Namespace", svc.GetNamespace())
-				}
+		func() error {
+			ctx.Logger().Info("Creating the zookeeper service.",
+				"Service.Name", desired.GetName(),
+				"Service.Namespace", desired.GetNamespace())
+			if _, err := applyObject(ctx, cluster, desired, nil); err != nil {
+				return err
 			}
-			return
+			ctx.Logger().Info("Service creation success.",
+				"Service.Name", desired.GetName(),
+				"Service.Namespace", desired.GetNamespace())
+			recordEvent(ctx, cluster, v1.EventTypeNormal, eventReasonCreated, "Created the service %s", desired.Name)
+			return nil
 		})
 }
 
@@ -104,7 +89,7 @@ func createService(c *v1alpha1.ZookeeperCluster, name string, hasClusterIP bool,
 	}
 	srv := service.New(c.Namespace, name, labels, v1.ServiceSpec{
 		ClusterIP: clusterIP,
-		Selector:  labels,
+		Selector:  c.SelectorLabels(),
 		Ports:     servicePorts,
 	})
 	srv.Annotations = c.GenerateAnnotations()
@@ -18,6 +18,7 @@ package zookeepercluster
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/monimesl/operator-helper/k8s"
 	"github.com/monimesl/operator-helper/k8s/pod"
@@ -25,16 +26,22 @@ import (
 	"github.com/monimesl/operator-helper/oputil"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
 	v1 "k8s.io/api/apps/v1"
 	v12 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"strings"
 )
 
+// templateHashAnnotation records on the pod template the hash of the rest of the template
+var templateHashAnnotation = fmt.Sprintf("%s/pod-template-hash", internal.Domain)
+
 const (
 	configVolume         = "config"
+	tlsVolume            = "tls"
+	tlsMountPath         = "/tls"
 	PvcDataVolumeName    = "data"
 	PvcDataLogVolumeName = "data-log"
 )
@@ -48,72 +55,90 @@ func ReconcileStatefulSet(ctx reconciler.Context, cluster *v1alpha1.ZookeeperClu
 	}, sts,
 		// Found
 		func() error {
-			if shouldUpdateStatefulSet(ctx, cluster, sts) {
-				if err := updateStatefulset(ctx, sts, cluster); err != nil {
-					return err
+			if !sts.DeletionTimestamp.IsZero() {
+				// Deleted without its pods to be recreated with the expanded volume claim templates
+				return requeue.After(volumeExpansionRequeueDelay, "waiting for the statefulset to be deleted")
+			}
+			if deleted, err := reconcileVolumeExpansion(ctx, cluster, sts); deleted || err != nil {
+				return err
+			}
+			replicas, scalingErr := desiredReplicas(ctx, cluster, sts)
+			if _, ok := requeue.Delay(scalingErr); scalingErr != nil && !ok {
+				return scalingErr
+			}
+			current := *sts.Spec.Replicas
+			desired, changed, err := applyStatefulSet(ctx, cluster, sts, replicas)
+			if err != nil {
+				return err
+			}
+			if changed {
+				if current != replicas {
+					recordEvent(ctx, cluster, v12.EventTypeNormal, eventReasonScaling,
+						"Scaled the members from %d to %d; the target size is %d", current, replicas, *cluster.Spec.Size)
 				}
-				if err := updateStatefulsetPVCs(ctx, sts, cluster); err != nil {
+				if err = updateStatefulsetPVCs(ctx, desired, cluster); err != nil {
 					return err
 				}
 			}
-			return nil
+			if err = stepRollout(ctx, cluster, desired); err != nil {
+				return err
+			}
+			return scalingErr
 		},
 		// Not Found
 		func() error {
-			sts = createStatefulSet(cluster)
-			if err := ctx.SetOwnershipReference(cluster, sts); err != nil {
-				return err
-			}
+			sts = createStatefulSet(cluster, *cluster.Spec.Size)
 			ctx.Logger().Info("Creating the zookeeper statefulset.",
 				"StatefulSet.Name", sts.GetName(),
 				"StatefulSet.Namespace", sts.GetNamespace())
-			if err := ctx.Client().Create(context.TODO(), sts); err != nil {
+			if _, err := applyObject(ctx, cluster, sts, nil); err != nil {
 				return err
 			}
 			ctx.Logger().Info("StatefulSet creation success.",
 				"StatefulSet.Name", sts.GetName(),
 				"StatefulSet.Namespace", sts.GetNamespace())
+			recordEvent(ctx, cluster, v12.EventTypeNormal, eventReasonCreated,
+				"Created the statefulset %s with %d members", sts.Name, *sts.Spec.Replicas)
 			return nil
 		})
 }
 
-func shouldUpdateStatefulSet(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, sts *v1.StatefulSet) bool {
-	if *c.Spec.Size != *sts.Spec.Replicas {
-		ctx.Logger().Info("Zookeeper cluster size changed",
-			"from", *sts.Spec.Replicas, "to", *c.Spec.Size)
-		return true
-	}
-	if c.Spec.ZookeeperVersion != c.Status.Metadata.ZkVersion {
-		ctx.Logger().Info("Zookeeper version changed",
-			"from", c.Status.Metadata.ZkVersion, "to", c.Spec.ZookeeperVersion,
+// applyStatefulSet applies the desired state of the statefulset with the replicas. It returns the applied
+// statefulset and whether it changed. The template changes are rolled out to the members by stepRollout
+func applyStatefulSet(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster,
+	sts *v1.StatefulSet, replicas int32) (*v1.StatefulSet, bool, error) {
+	desired := createStatefulSet(cluster, replicas)
+	// The selector and the volume claim templates can't be changed; the selector of the statefulsets
+	// created by the previous versions includes the spec labels, which the members must keep
+	desired.Spec.Selector = sts.Spec.Selector
+	desired.Spec.Template.Labels = internal.MergeLabels(desired.Spec.Template.Labels, sts.Spec.Selector.MatchLabels)
+	desired.Spec.VolumeClaimTemplates = sts.Spec.VolumeClaimTemplates
+	if hash := desired.Spec.Template.Annotations[templateHashAnnotation]; hash != sts.Spec.Template.Annotations[templateHashAnnotation] {
+		ctx.Logger().Info("Zookeeper pod template changed",
+			"from", sts.Spec.Template.Annotations[templateHashAnnotation], "to", hash,
 		)
-		return true
 	}
-	if c.Spec.ZkConfig != c.Status.Metadata.ZkConfig {
-		ctx.Logger().Info("Zookeeper cluster config changed",
-			"from", c.Status.Metadata.ZkConfig, "to", c.Spec.ZkConfig,
-		)
-		return true
+	changed, err := applyObject(ctx, cluster, desired, sts)
+	if err != nil {
+		return nil, false, err
 	}
-	return false
+	if changed {
+		ctx.Logger().Info("Updated the zookeeper statefulset to its desired state.",
+			"StatefulSet.Name", desired.GetName(),
+			"StatefulSet.Namespace", desired.GetNamespace(),
+			"Replicas", replicas,
+			"Version", cluster.TargetVersion())
+	}
+	return desired, changed, nil
 }
 
-func updateStatefulset(ctx reconciler.Context, sts *v1.StatefulSet, cluster *v1alpha1.ZookeeperCluster) error {
-	sts.Spec.Replicas = cluster.Spec.Size
-	containers := sts.Spec.Template.Spec.Containers
-	for i, container := range containers {
+func zookeeperContainerImage(sts *v1.StatefulSet) string {
+	for _, container := range sts.Spec.Template.Spec.Containers {
 		if container.Name == "zookeeper" {
-			container.Image = cluster.Image().ToString()
-			containers[i] = container
+			return container.Image
 		}
 	}
-	sts.Spec.Template.Spec.Containers = containers
-	ctx.Logger().Info("Updating the zookeeper statefulset.",
-		"StatefulSet.Name", sts.GetName(),
-		"StatefulSet.Namespace", sts.GetNamespace(),
-		"NewReplicas", cluster.Spec.Size,
-		"NewVersion", cluster.Spec.ZookeeperVersion)
-	return ctx.Client().Update(context.TODO(), sts)
+	return ""
 }
 
 func updateStatefulsetPVCs(ctx reconciler.Context, sts *v1.StatefulSet, cluster *v1alpha1.ZookeeperCluster) error {
@@ -121,7 +146,7 @@ func updateStatefulsetPVCs(ctx reconciler.Context, sts *v1.StatefulSet, cluster
 		// Keep the orphan PVC since the reclaimed policy said so
 		return nil
 	}
-	pvcList, err := pvc.ListAllWithMatchingLabels(ctx.Client(), sts.Namespace, sts.Spec.Template.Labels)
+	pvcList, err := pvc.ListAllWithMatchingLabels(ctx.Client(), sts.Namespace, cluster.SelectorLabels())
 	if err != nil {
 		return err
 	}
@@ -141,12 +166,14 @@ func updateStatefulsetPVCs(ctx reconciler.Context, sts *v1.StatefulSet, cluster
 			if err != nil {
 				return fmt.Errorf("error on deleing the pvc (%s): %w", toDel.Name, err)
 			}
+			recordEvent(ctx, cluster, v12.EventTypeNormal, eventReasonPVCDeleted,
+				"Deleted the pvc %s of the removed member", toDel.Name)
 		}
 	}
 	return nil
 }
 
-func createStatefulSet(c *v1alpha1.ZookeeperCluster) *v1.StatefulSet {
+func createStatefulSet(c *v1alpha1.ZookeeperCluster, replicas int32) *v1.StatefulSet {
 	return &v1.StatefulSet{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "StatefulSet",
@@ -155,7 +182,7 @@ func createStatefulSet(c *v1alpha1.ZookeeperCluster) *v1.StatefulSet {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      c.GetName(),
 			Namespace: c.Namespace,
-			Labels: mergeLabels(c.GenerateLabels(), map[string]string{
+			Labels: internal.MergeLabels(c.GenerateLabels(), map[string]string{
 				k8s.LabelAppVersion: c.Spec.ZookeeperVersion,
 				"version":           c.Spec.ZookeeperVersion,
 			}),
@@ -163,30 +190,51 @@ func createStatefulSet(c *v1alpha1.ZookeeperCluster) *v1.StatefulSet {
 		},
 		Spec: v1.StatefulSetSpec{
 			ServiceName: c.HeadlessServiceName(),
-			Replicas:    c.Spec.Size,
+			Replicas:    &replicas,
 			Selector: &metav1.LabelSelector{
-				MatchLabels: c.GenerateLabels(),
+				MatchLabels: c.SelectorLabels(),
 			},
 			UpdateStrategy: v1.StatefulSetUpdateStrategy{
-				Type: v1.RollingUpdateStatefulSetStrategyType,
-			},
-			PodManagementPolicy: v1.OrderedReadyPodManagement,
-			Template: v12.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					GenerateName: c.GetName(),
-					Labels: mergeLabels(
-						c.GenerateLabels(),
-						c.Spec.PodConfig.Labels,
-					),
-					Annotations: c.Spec.PodConfig.Annotations,
-				},
-				Spec: createPodSpec(c),
+				Type: v1.OnDeleteStatefulSetStrategyType,
 			},
+			PodManagementPolicy:  v1.OrderedReadyPodManagement,
+			Template:             createPodTemplate(c),
 			VolumeClaimTemplates: createPersistentVolumeClaims(c),
 		},
 	}
 }
 
+// createPodTemplate creates the pod template of the members, annotated with the hash of its content.
+// A change of the template, e.g. of the pod config, the probes or the labels, rolls the members
+func createPodTemplate(c *v1alpha1.ZookeeperCluster) v12.PodTemplateSpec {
+	template := v12.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: c.GetName(),
+			Labels: internal.MergeLabels(
+				c.GenerateLabels(),
+				c.Spec.PodConfig.Labels,
+			),
+			Annotations: createPodAnnotations(c),
+		},
+		Spec: createPodSpec(c),
+	}
+	data, err := json.Marshal(template)
+	if err == nil {
+		template.Annotations[templateHashAnnotation] = hashData(data)
+	}
+	return template
+}
+
+func createPodAnnotations(c *v1alpha1.ZookeeperCluster) map[string]string {
+	return internal.MergeLabels(c.Spec.PodConfig.Annotations, podTemplateHashAnnotations(c))
+}
+
+// podTemplateHashAnnotations creates the pod template annotations of the hashes of the
+// certificates, credentials and config the members run with; a change restarts the members
+func podTemplateHashAnnotations(c *v1alpha1.ZookeeperCluster) map[string]string {
+	return internal.MergeLabels(tlsPodAnnotations(c), authPodAnnotations(c), configPodAnnotations(c))
+}
+
 func createPodSpec(c *v1alpha1.ZookeeperCluster) v12.PodSpec {
 	containerPorts := []v12.ContainerPort{
 		{Name: v1alpha1.AdminPortName, ContainerPort: c.Spec.Ports.Admin},
@@ -206,39 +254,101 @@ func createPodSpec(c *v1alpha1.ZookeeperCluster) v12.PodSpec {
 	volumeMounts := []v12.VolumeMount{
 		{Name: configVolume, MountPath: "/config"},
 		{Name: PvcDataVolumeName, MountPath: dataDir},
-		{Name: PvcDataLogVolumeName, MountPath: dataDir + "-log"},
+		{Name: PvcDataLogVolumeName, MountPath: c.DataLogDir()},
 	}
-	if c.Spec.Directories.Log != "" {
-		volumeMounts = append(volumeMounts, v12.VolumeMount{Name: "log", MountPath: c.Spec.Directories.Log})
+	env := append([]v12.EnvVar{}, c.Spec.PodConfig.Spec.Env...)
+	if c.IsTLSEnabled() {
+		volumeMounts = append(volumeMounts, v12.VolumeMount{Name: tlsVolume, MountPath: tlsMountPath, ReadOnly: true})
 	}
-	image := c.Image()
+	authVolumes, authMounts := createAuthVolumes(c)
+	volumeMounts = append(volumeMounts, authMounts...)
+	env = append(env, createAuthEnvVars(c)...)
 	container := v12.Container{
 		Name:            "zookeeper",
 		VolumeMounts:    volumeMounts,
 		Ports:           containerPorts,
-		Image:           image.ToString(),
-		ImagePullPolicy: image.PullPolicy,
+		Image:           zookeeperImage(c),
+		ImagePullPolicy: c.Spec.ImagePullPolicy,
 		Resources:       c.Spec.PodConfig.Spec.Resources,
 		StartupProbe:    createStartupProbe(c.Spec.ProbeConfig.Startup),
 		LivenessProbe:   createLivenessProbe(c.Spec.ProbeConfig.Liveness),
 		ReadinessProbe:  createReadinessProbe(c.Spec.ProbeConfig.Readiness),
 		Lifecycle:       &v12.Lifecycle{PreStop: createPreStopHandler()},
-		Env:             pod.DecorateContainerEnvVars(true, c.Spec.PodConfig.Spec.Env...),
+		Env:             pod.DecorateContainerEnvVars(true, env...),
 		Command:         []string{"/scripts/start.sh"},
 	}
 	volumes := []v12.Volume{
 		{
-			Name: configVolume,
+			Name:         configVolume,
+			VolumeSource: createConfigVolumeSource(c),
+		},
+	}
+	volumes = append(volumes, authVolumes...)
+	if c.IsEphemeral() {
+		volumes = append(volumes, createEphemeralVolumes(c.Spec.Persistence.Ephemeral)...)
+	}
+	if c.IsTLSEnabled() {
+		volumes = append(volumes, v12.Volume{
+			Name: tlsVolume,
 			VolumeSource: v12.VolumeSource{
-				ConfigMap: &v12.ConfigMapVolumeSource{
-					LocalObjectReference: v12.LocalObjectReference{
-						Name: c.GetName(),
+				Secret: &v12.SecretVolumeSource{
+					SecretName: c.KeystoreSecretName(),
+				},
+			},
+		})
+	}
+	spec := pod.NewSpec(c.Spec.PodConfig, volumes, nil, []v12.Container{container})
+	spec.ImagePullSecrets = c.ImagePullSecrets()
+	return spec
+}
+
+// createEphemeralVolumes creates the emptyDir volumes of the data and the transaction logs, which
+// are kept across the container restarts but lost with the pod
+func createEphemeralVolumes(ephemeral *v1alpha1.EphemeralStorage) []v12.Volume {
+	volumes := make([]v12.Volume, 0, 2)
+	for _, name := range []string{PvcDataVolumeName, PvcDataLogVolumeName} {
+		volumes = append(volumes, v12.Volume{
+			Name: name,
+			VolumeSource: v12.VolumeSource{
+				EmptyDir: &v12.EmptyDirVolumeSource{
+					Medium:    ephemeral.Medium,
+					SizeLimit: ephemeral.SizeLimit,
+				},
+			},
+		})
+	}
+	return volumes
+}
+
+// createConfigVolumeSource projects the configmap and, when SASL is enabled,
+// the generated JAAS configuration into the config volume
+func createConfigVolumeSource(c *v1alpha1.ZookeeperCluster) v12.VolumeSource {
+	if !c.IsSaslEnabled() {
+		return v12.VolumeSource{
+			ConfigMap: &v12.ConfigMapVolumeSource{
+				LocalObjectReference: v12.LocalObjectReference{
+					Name: c.GetName(),
+				},
+			},
+		}
+	}
+	return v12.VolumeSource{
+		Projected: &v12.ProjectedVolumeSource{
+			Sources: []v12.VolumeProjection{
+				{
+					ConfigMap: &v12.ConfigMapProjection{
+						LocalObjectReference: v12.LocalObjectReference{Name: c.GetName()},
+					},
+				},
+				{
+					Secret: &v12.SecretProjection{
+						LocalObjectReference: v12.LocalObjectReference{Name: c.AuthSecretName()},
+						Items:                []v12.KeyToPath{{Key: jaasConfigKey, Path: jaasConfigKey}},
 					},
 				},
 			},
 		},
 	}
-	return pod.NewSpec(c.Spec.PodConfig, volumes, nil, []v12.Container{container})
 }
 
 func createStartupProbe(probe *pod.Probe) *v12.Probe {
@@ -262,13 +372,17 @@ func createPreStopHandler() *v12.LifecycleHandler {
 	return &v12.LifecycleHandler{Exec: &v12.ExecAction{Command: []string{"/scripts/stop.sh"}}}
 }
 
+// createPersistentVolumeClaims creates the claim templates of the members volumes; the ephemeral storage has none
 func createPersistentVolumeClaims(c *v1alpha1.ZookeeperCluster) []v12.PersistentVolumeClaim {
+	if c.IsEphemeral() {
+		return nil
+	}
 	persistence := c.Spec.Persistence
 	pvcs := []v12.PersistentVolumeClaim{
 		{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: PvcDataVolumeName,
-				Labels: mergeLabels(
+				Labels: internal.MergeLabels(
 					c.GenerateLabels(),
 				),
 				Annotations: c.Spec.Persistence.Annotations,
@@ -278,28 +392,41 @@ func createPersistentVolumeClaims(c *v1alpha1.ZookeeperCluster) []v12.Persistent
 		{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: PvcDataLogVolumeName,
-				Labels: mergeLabels(
+				Labels: internal.MergeLabels(
 					c.GenerateLabels(),
 				),
-				Annotations: c.Spec.Persistence.Annotations,
+				Annotations: dataLogVolumeAnnotations(c),
 			},
-			Spec: createDataLogVolumeClaimSpec(persistence.VolumeClaimSpec, c.Spec.GetDefaultDataLogStorageVolumeSize()),
+			Spec: createDataLogVolumeClaimSpec(persistence.VolumeClaimSpec, c.Spec.DataLogPersistence),
 		},
 	}
 	return pvcs
 }
 
-func createDataLogVolumeClaimSpec(spec v12.PersistentVolumeClaimSpec, size string) v12.PersistentVolumeClaimSpec {
+func dataLogVolumeAnnotations(c *v1alpha1.ZookeeperCluster) map[string]string {
+	if c.Spec.DataLogPersistence.Annotations != nil {
+		return c.Spec.DataLogPersistence.Annotations
+	}
+	return c.Spec.Persistence.Annotations
+}
+
+// createDataLogVolumeClaimSpec creates the claim spec of the transaction logs volume; its size
+// and storage class are set apart from the data volume ones it shares the other settings with
+func createDataLogVolumeClaimSpec(spec v12.PersistentVolumeClaimSpec, dataLog *v1alpha1.DataLogPersistence) v12.PersistentVolumeClaimSpec {
+	storageClassName := spec.StorageClassName
+	if dataLog.StorageClassName != nil {
+		storageClassName = dataLog.StorageClassName
+	}
 	return v12.PersistentVolumeClaimSpec{
 		AccessModes: spec.AccessModes,
 		Selector:    spec.Selector,
 		Resources: v12.ResourceRequirements{
 			Requests: v12.ResourceList{
-				v12.ResourceStorage: resource.MustParse(size),
+				v12.ResourceStorage: dataLog.Size,
 			},
 		},
 		VolumeName:       spec.VolumeName,
-		StorageClassName: spec.StorageClassName,
+		StorageClassName: storageClassName,
 		VolumeMode:       spec.VolumeMode,
 		DataSource:       spec.DataSource,
 		DataSourceRef:    spec.DataSourceRef,
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreatePodTemplate(t *testing.T) {
+	hash := func(mutate func(c *v1alpha1.ZookeeperCluster)) string {
+		return createPodTemplate(testConfigCluster(mutate)).Annotations[templateHashAnnotation]
+	}
+	base := hash(nil)
+	if base == "" || base != hash(nil) {
+		t.Fatalf("expected a stable template hash, got %q", base)
+	}
+	for name, mutate := range map[string]func(c *v1alpha1.ZookeeperCluster){
+		"pod labels": func(c *v1alpha1.ZookeeperCluster) {
+			c.Spec.PodConfig.Labels = map[string]string{"team": "storage"}
+		},
+		"spec labels": func(c *v1alpha1.ZookeeperCluster) {
+			c.Spec.Labels = map[string]string{"team": "storage"}
+		},
+	} {
+		if hash(mutate) == base {
+			t.Errorf("expected the %s to change the template hash", name)
+		}
+	}
+}
+
+func TestApplyStatefulSetKeepsTheSelector(t *testing.T) {
+	c := testConfigCluster(func(c *v1alpha1.ZookeeperCluster) {
+		c.Spec.Labels = map[string]string{"team": "storage"}
+	})
+	ctx := reconcilertest.NewApplyContext(c)
+	// the statefulsets created by the previous versions select the spec labels
+	sts := createStatefulSet(c, 3)
+	sts.Spec.Selector.MatchLabels = c.GenerateLabels()
+	if err := ctx.Client().Create(context.TODO(), sts); err != nil {
+		t.Fatal(err)
+	}
+	if selector := createStatefulSet(c, 3).Spec.Selector.MatchLabels; !reflect.DeepEqual(selector, c.SelectorLabels()) {
+		t.Errorf("expected the new statefulsets to select %v, got %v", c.SelectorLabels(), selector)
+	}
+
+	c.Spec.Labels = map[string]string{"team": "data"}
+	applied, changed, err := applyStatefulSet(ctx, c, sts, 3)
+	if err != nil || !changed {
+		t.Fatalf("expected the statefulset to be changed, got %t, %v", changed, err)
+	}
+	if selector := applied.Spec.Selector.MatchLabels; selector["team"] != "storage" {
+		t.Errorf("expected the selector to be kept, got %v", selector)
+	}
+	if labels := applied.Spec.Template.Labels; labels["team"] != "storage" {
+		t.Errorf("expected the members to keep the selected labels, got %v", labels)
+	}
+}
+
+func TestCreateDataLogVolumeClaim(t *testing.T) {
+	fast := "fast"
+	tests := []struct {
+		name          string
+		dataLog       *v1alpha1.DataLogPersistence
+		size, class   string
+		expectedClass string
+	}{
+		{
+			name:          "defaults",
+			size:          "3Gi",
+			expectedClass: "standard",
+		},
+		{
+			name:          "dedicated size and storage class",
+			dataLog:       &v1alpha1.DataLogPersistence{Size: resource.MustParse("5Gi"), StorageClassName: &fast},
+			size:          "5Gi",
+			expectedClass: fast,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := testVolumesCluster("10Gi", "standard")
+			if test.dataLog != nil {
+				c.Spec.DataLogPersistence = test.dataLog
+			}
+			claims := createPersistentVolumeClaims(c)
+			if class := *claims[0].Spec.StorageClassName; class != "standard" {
+				t.Errorf("expected the data volume class standard, got %s", class)
+			}
+			dataLog := claims[1]
+			if dataLog.Name != PvcDataLogVolumeName {
+				t.Fatalf("unexpected claim template: %s", dataLog.Name)
+			}
+			if size := dataLog.Spec.Resources.Requests[v12.ResourceStorage]; size.String() != test.size {
+				t.Errorf("expected the data log size %s, got %s", test.size, size.String())
+			}
+			if class := *dataLog.Spec.StorageClassName; class != test.expectedClass {
+				t.Errorf("expected the data log class %s, got %s", test.expectedClass, class)
+			}
+		})
+	}
+}
+
+func TestDataLogDirMount(t *testing.T) {
+	for _, log := range []string{"", "/var/lib/zookeeper-txn"} {
+		c := testConfigCluster(func(c *v1alpha1.ZookeeperCluster) {
+			c.Spec.Directories = &v1alpha1.Directories{Log: log}
+		})
+		dir := c.DataLogDir()
+		if log != "" && dir != log {
+			t.Errorf("expected the data log dir %s, got %s", log, dir)
+		}
+		mounted := false
+		for _, mount := range createPodSpec(c).Containers[0].VolumeMounts {
+			mounted = mounted || (mount.Name == PvcDataLogVolumeName && mount.MountPath == dir)
+		}
+		if !mounted {
+			t.Errorf("expected the data log volume to be mounted at %s", dir)
+		}
+		if cfg := parseZkConfig(t, createZkConfig(c)); cfg["dataLogDir"] != dir {
+			t.Errorf("expected the zoo.cfg dataLogDir %s, got %s", dir, cfg["dataLogDir"])
+		}
+	}
+}
+
+func TestCreateEphemeralStorage(t *testing.T) {
+	limit := resource.MustParse("1Gi")
+	c := testVolumesCluster("10Gi", "standard")
+	c.Spec.Persistence.Ephemeral = &v1alpha1.EphemeralStorage{Medium: v12.StorageMediumMemory, SizeLimit: &limit}
+	if claims := createPersistentVolumeClaims(c); len(claims) != 0 {
+		t.Errorf("expected no claim template, got %d", len(claims))
+	}
+	emptyDirs := map[string]bool{}
+	for _, volume := range createPodSpec(c).Volumes {
+		if emptyDir := volume.EmptyDir; emptyDir != nil && emptyDir.Medium == v12.StorageMediumMemory && emptyDir.SizeLimit.Equal(limit) {
+			emptyDirs[volume.Name] = true
+		}
+	}
+	if !emptyDirs[PvcDataVolumeName] || !emptyDirs[PvcDataLogVolumeName] {
+		t.Errorf("expected the data and data log emptyDir volumes, got %v", emptyDirs)
+	}
+	if env := createBootEnvScript(c); !strings.Contains(env, "EPHEMERAL_STORAGE=true\n") {
+		t.Errorf("expected the ephemeral storage in the boot env, got:\n%s", env)
+	}
+}
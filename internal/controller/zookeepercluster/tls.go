@@ -0,0 +1,410 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"github.com/monimesl/operator-helper/k8s/secret"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal"
+	"github.com/monimesl/zookeeper-operator/internal/keystore"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+	v12 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	keystoreKey         = "keystore.p12"
+	truststoreKey       = "truststore.p12"
+	keystorePasswordKey = "password"
+	keystorePasswordLen = 32
+	tlsRequeueDelay     = 10 * time.Second
+)
+
+var (
+	// tlsSourceHashAnnotation records the hash of the certificates the key stores were generated from
+	tlsSourceHashAnnotation = fmt.Sprintf("%s/tls-source-hash", internal.Domain)
+	// keystoreFormatAnnotation records the encoding of the key stores
+	keystoreFormatAnnotation = fmt.Sprintf("%s/keystore-format", internal.Domain)
+	// tlsHashAnnotation records on the pod template the hash of the certificates the members run with
+	tlsHashAnnotation = fmt.Sprintf("%s/tls-hash", internal.Domain)
+)
+
+var certificateGVK = schema.GroupVersionKind{
+	Group:   "cert-manager.io",
+	Version: "v1",
+	Kind:    "Certificate",
+}
+
+// ReconcileTLS reconcile the TLS certificate and key stores of the specified cluster
+func ReconcileTLS(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error {
+	if !cluster.IsTLSEnabled() || !cluster.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	if cluster.Spec.TLS.CertManager != nil {
+		if err := reconcileCertificate(ctx, cluster); err != nil {
+			return err
+		}
+	}
+	source := &v1.Secret{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      cluster.TLSSecretName(),
+		Namespace: cluster.Namespace,
+	}, source)
+	if errors.IsNotFound(err) {
+		if cluster.Spec.TLS.CertManager != nil {
+			return requeue.After(tlsRequeueDelay, "waiting for cert-manager to issue the certificate")
+		}
+		return fmt.Errorf("the TLS secret (%s) of the cluster (%s) is not found",
+			cluster.TLSSecretName(), cluster.Name)
+	} else if err != nil {
+		return err
+	}
+	if err = watchSecret(ctx, source); err != nil {
+		return err
+	}
+	sourceHash := hashData(source.Data[v1alpha1.TLSCertKey], source.Data[v1alpha1.TLSPrivateKeyKey], source.Data[v1alpha1.TLSCAKey])
+	if err = reconcileKeystoreSecret(ctx, cluster, source, sourceHash); err != nil {
+		return err
+	}
+	if err = updateTLSStatus(ctx, cluster, source, sourceHash); err != nil {
+		return err
+	}
+	return checkTLSReload(ctx, cluster, source)
+}
+
+// updateTLSStatus records the certificates in use and starts a rotation when they changed
+func updateTLSStatus(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster, source *v1.Secret, sourceHash string) error {
+	status := cluster.Status.TLS
+	if status != nil && status.CertificateHash == sourceHash {
+		return nil
+	}
+	if status == nil {
+		status = &v1alpha1.TLSStatus{}
+	}
+	if notAfter, err := keystore.NotAfter(source.Data[v1alpha1.TLSCertKey]); err == nil {
+		status.NotAfter = &metav1.Time{Time: notAfter}
+	}
+	if status.CertificateHash != "" {
+		ctx.Logger().Info("The cluster certificates changed; rotating them",
+			"cluster", cluster.Name, "strategy", cluster.Spec.TLS.RotationStrategy)
+		recordEvent(ctx, cluster, v1.EventTypeNormal, eventReasonCertificatesRotated,
+			"The cluster certificates changed; rotating them with the %s strategy", cluster.Spec.TLS.RotationStrategy)
+		now := metav1.Now()
+		status.RotationPhase = v1alpha1.TLSRotationPhaseRotating
+		status.RotationStartTime = &now
+	}
+	status.CertificateHash = sourceHash
+	cluster.Status.TLS = status
+	return ctx.Client().Status().Update(context.TODO(), cluster)
+}
+
+// checkTLSReload marks the Reload rotation as completed once every member serves the renewed certificate.
+// The members reload the key stores after the kubelet syncs the mounted secret, which takes up to a minute.
+// Past the rollout progress deadline, the members are restarted with the renewed certificates instead
+func checkTLSReload(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, source *v1.Secret) error {
+	status := c.Status.TLS
+	if c.Spec.TLS.RotationStrategy != v1alpha1.TLSRotationReload || status.RotationPhase != v1alpha1.TLSRotationPhaseRotating ||
+		status.RestartHash == status.CertificateHash {
+		return nil
+	}
+	pending, err := pendingTLSReload(ctx, c, source)
+	if err != nil {
+		return err
+	}
+	if pending == "" {
+		ctx.Logger().Info("The members reloaded the renewed certificates", "cluster", c.Name)
+		now := metav1.Now()
+		status.RotationPhase = v1alpha1.TLSRotationPhaseCompleted
+		status.LastRotationTime = &now
+		return ctx.Client().Status().Update(context.TODO(), c)
+	}
+	deadline := c.RolloutProgressDeadline()
+	if status.RotationStartTime == nil || time.Since(status.RotationStartTime.Time) < deadline {
+		return requeue.After(tlsRequeueDelay, pending)
+	}
+	ctx.Logger().Info("The members didn't reload the renewed certificates in time; restarting them",
+		"cluster", c.Name, "deadline", deadline, "reason", pending)
+	recordEvent(ctx, c, v1.EventTypeWarning, eventReasonCertificatesReloadTimedOut,
+		"The members didn't reload the renewed certificates within %s (%s); restarting them one at a time", deadline, pending)
+	status.RestartHash = status.CertificateHash
+	return ctx.Client().Status().Update(context.TODO(), c)
+}
+
+// pendingTLSReload returns why the members don't serve the renewed certificate yet, or empty once they all do
+func pendingTLSReload(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, source *v1.Secret) (string, error) {
+	expected, err := keystore.Leaf(source.Data[v1alpha1.TLSCertKey])
+	if err != nil {
+		return "", fmt.Errorf("error parsing the certificate of the TLS secret (%s): %w", source.Name, err)
+	}
+	// The election port is open on every member, unlike the quorum one only the leader listens on
+	port := c.Spec.Ports.Leader
+	if c.IsClientTLSEnabled() {
+		port = c.Spec.Ports.SecureClient
+	}
+	pods, err := pod.ListAllWithMatchingLabels(ctx.Client(), c.Namespace, c.SelectorLabels())
+	if err != nil {
+		return "", err
+	}
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		if !pod.IsReady(p) || p.Status.PodIP == "" {
+			return fmt.Sprintf("waiting for the member pod %s to be ready", p.Name), nil
+		}
+		served, err := zk.ServedCertificate(net.JoinHostPort(p.Status.PodIP, strconv.Itoa(int(port))))
+		if err != nil {
+			return err.Error(), nil
+		}
+		if !bytes.Equal(served, expected) {
+			return fmt.Sprintf("waiting for the member %s to reload the certificate", p.Name), nil
+		}
+	}
+	return "", nil
+}
+
+// completeTLSRotation marks the rotation as completed once every member restarted with the new certificates
+func completeTLSRotation(c *v1alpha1.ZookeeperCluster, sts *v12.StatefulSet) {
+	status := c.Status.TLS
+	if status == nil || status.RotationPhase != v1alpha1.TLSRotationPhaseRotating || sts == nil {
+		return
+	}
+	if sts.Spec.Template.Annotations[tlsHashAnnotation] != status.CertificateHash || isRollingOut(sts) ||
+		sts.Status.ReadyReplicas != *c.Spec.Size {
+		return
+	}
+	now := metav1.Now()
+	status.RotationPhase = v1alpha1.TLSRotationPhaseCompleted
+	status.LastRotationTime = &now
+}
+
+// tlsPodAnnotations returns the pod annotations which restart the members when the certificates change.
+// With the Reload strategy, they only restart the members which didn't reload the certificates in time
+func tlsPodAnnotations(c *v1alpha1.ZookeeperCluster) map[string]string {
+	if !c.IsTLSEnabled() || c.Status.TLS == nil {
+		return nil
+	}
+	if c.Spec.TLS.RotationStrategy == v1alpha1.TLSRotationReload {
+		if c.Status.TLS.RestartHash == "" {
+			return nil
+		}
+		return map[string]string{tlsHashAnnotation: c.Status.TLS.RestartHash}
+	}
+	return map[string]string{tlsHashAnnotation: c.Status.TLS.CertificateHash}
+}
+
+func reconcileCertificate(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error {
+	cert := &unstructured.Unstructured{}
+	cert.SetGroupVersionKind(certificateGVK)
+	err := ctx.GetResource(types.NamespacedName{
+		Name:      cluster.TLSSecretName(),
+		Namespace: cluster.Namespace,
+	}, cert,
+		// Found
+		func() error {
+			spec, _, _ := unstructured.NestedMap(cert.Object, "spec")
+			desired := createCertificateSpec(cluster)
+			if containsAll(spec, desired) {
+				return nil
+			}
+			for key, value := range desired {
+				spec[key] = value
+			}
+			cert.Object["spec"] = spec
+			ctx.Logger().Info("Updating the zookeeper certificate.",
+				"Certificate.Name", cert.GetName(),
+				"Certificate.Namespace", cert.GetNamespace())
+			return ctx.Client().Update(context.TODO(), cert)
+		},
+		// Not Found
+		func() error {
+			cert = createCertificate(cluster)
+			if err := ctx.SetOwnershipReference(cluster, cert); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the zookeeper certificate.",
+				"Certificate.Name", cert.GetName(),
+				"Certificate.Namespace", cert.GetNamespace())
+			return ctx.Client().Create(context.TODO(), cert)
+		})
+	if meta.IsNoMatchError(err) {
+		return fmt.Errorf("the cert-manager Certificate resource is not installed: %w", err)
+	}
+	return err
+}
+
+func createCertificate(c *v1alpha1.ZookeeperCluster) *unstructured.Unstructured {
+	cert := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": createCertificateSpec(c),
+	}}
+	cert.SetGroupVersionKind(certificateGVK)
+	cert.SetName(c.TLSSecretName())
+	cert.SetNamespace(c.Namespace)
+	cert.SetLabels(c.GenerateLabels())
+	return cert
+}
+
+func createCertificateSpec(c *v1alpha1.ZookeeperCluster) map[string]interface{} {
+	certManager := c.Spec.TLS.CertManager
+	headless := fmt.Sprintf("%s.%s.svc", c.HeadlessServiceName(), c.Namespace)
+	client := fmt.Sprintf("%s.%s.svc", c.ClientServiceName(), c.Namespace)
+	issuerRef := map[string]interface{}{
+		"name": certManager.IssuerRef.Name,
+	}
+	if certManager.IssuerRef.Kind != "" {
+		issuerRef["kind"] = certManager.IssuerRef.Kind
+	}
+	if certManager.IssuerRef.Group != "" {
+		issuerRef["group"] = certManager.IssuerRef.Group
+	}
+	spec := map[string]interface{}{
+		"secretName": c.TLSSecretName(),
+		"issuerRef":  issuerRef,
+		"usages":     []interface{}{"server auth", "client auth"},
+		"dnsNames": []interface{}{
+			c.ClientServiceName(),
+			client,
+			fmt.Sprintf("%s.%s", client, c.Spec.ClusterDomain),
+			headless,
+			fmt.Sprintf("*.%s", headless),
+			fmt.Sprintf("%s.%s", headless, c.Spec.ClusterDomain),
+			fmt.Sprintf("*.%s.%s", headless, c.Spec.ClusterDomain),
+		},
+	}
+	if certManager.Duration != nil {
+		spec["duration"] = certManager.Duration.Duration.String()
+	}
+	if certManager.RenewBefore != nil {
+		spec["renewBefore"] = certManager.RenewBefore.Duration.String()
+	}
+	return spec
+}
+
+func containsAll(actual, expected map[string]interface{}) bool {
+	for key, value := range expected {
+		if !equality.Semantic.DeepEqual(actual[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func reconcileKeystoreSecret(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster, source *v1.Secret, sourceHash string) error {
+	ks := &v1.Secret{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      cluster.KeystoreSecretName(),
+		Namespace: cluster.Namespace,
+	}, ks,
+		// Found
+		func() error {
+			if ks.Annotations[tlsSourceHashAnnotation] == sourceHash && ks.Annotations[keystoreFormatAnnotation] == keystore.Format &&
+				ks.Labels[internal.WatchedSecretLabel] != "" {
+				return nil
+			}
+			data, err := createKeystoreData(source, string(ks.Data[keystorePasswordKey]))
+			if err != nil {
+				return err
+			}
+			ks.Data = data
+			ks.Labels = internal.MergeLabels(ks.Labels, internal.WatchedSecretLabels())
+			ks.Annotations = internal.MergeLabels(ks.Annotations, map[string]string{
+				tlsSourceHashAnnotation:  sourceHash,
+				keystoreFormatAnnotation: keystore.Format,
+			})
+			ctx.Logger().Info("Updating the zookeeper keystore secret.",
+				"Secret.Name", ks.GetName(),
+				"Secret.Namespace", ks.GetNamespace())
+			return ctx.Client().Update(context.TODO(), ks)
+		},
+		// Not Found
+		func() error {
+			password, err := secret.NewPassword(keystorePasswordLen)
+			if err != nil {
+				return err
+			}
+			data, err := createKeystoreData(source, password)
+			if err != nil {
+				return err
+			}
+			ks = secret.New(cluster.Namespace, cluster.KeystoreSecretName(), data)
+			ks.Labels = internal.MergeLabels(cluster.GenerateLabels(), internal.WatchedSecretLabels())
+			ks.Annotations = map[string]string{tlsSourceHashAnnotation: sourceHash, keystoreFormatAnnotation: keystore.Format}
+			if err = ctx.SetOwnershipReference(cluster, ks); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the zookeeper keystore secret.",
+				"Secret.Name", ks.GetName(),
+				"Secret.Namespace", ks.GetNamespace())
+			return ctx.Client().Create(context.TODO(), ks)
+		})
+}
+
+func createKeystoreData(source *v1.Secret, password string) (map[string][]byte, error) {
+	keyStore, trustStore, err := keystore.NewPKCS12(
+		source.Data[v1alpha1.TLSCertKey], source.Data[v1alpha1.TLSPrivateKeyKey], source.Data[v1alpha1.TLSCAKey], password)
+	if err != nil {
+		return nil, fmt.Errorf("error on converting the TLS secret (%s) to PKCS12: %w", source.Name, err)
+	}
+	return map[string][]byte{
+		keystoreKey:         keyStore,
+		truststoreKey:       trustStore,
+		keystorePasswordKey: []byte(password),
+	}, nil
+}
+
+// createTLSConfig creates the zoo.cfg entries enabling the cluster TLS
+func createTLSConfig(c *v1alpha1.ZookeeperCluster) map[string]string {
+	cfg := map[string]string{}
+	if !c.IsTLSEnabled() {
+		return cfg
+	}
+	keyStore := fmt.Sprintf("%s/%s", tlsMountPath, keystoreKey)
+	trustStore := fmt.Sprintf("%s/%s", tlsMountPath, truststoreKey)
+	cfg["serverCnxnFactory"] = "org.apache.zookeeper.server.NettyServerCnxnFactory"
+	if c.IsClientTLSEnabled() {
+		cfg["ssl.keyStore.location"] = keyStore
+		cfg["ssl.keyStore.type"] = "PKCS12"
+		cfg["ssl.trustStore.location"] = trustStore
+		cfg["ssl.trustStore.type"] = "PKCS12"
+		cfg["ssl.clientAuth"] = c.Spec.TLS.ClientAuth
+	}
+	if c.IsQuorumTLSEnabled() {
+		cfg["sslQuorum"] = "true"
+		cfg["ssl.quorum.keyStore.location"] = keyStore
+		cfg["ssl.quorum.keyStore.type"] = "PKCS12"
+		cfg["ssl.quorum.trustStore.location"] = trustStore
+		cfg["ssl.quorum.trustStore.type"] = "PKCS12"
+	}
+	for _, key := range c.TLSReloadConfigKeys() {
+		cfg[key] = "true"
+	}
+	return cfg
+}
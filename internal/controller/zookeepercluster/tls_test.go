@@ -0,0 +1,246 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"math/big"
+	"net"
+	"software.sslmate.com/src/go-pkcs12"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTLSSecret returns a TLS secret holding a self-signed certificate, its key and itself as the CA
+func testTLSSecret(t *testing.T) *v1.Secret {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "zk"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "zk-tls", Namespace: "default"},
+		Data: map[string][]byte{
+			v1alpha1.TLSCertKey:       certPEM,
+			v1alpha1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+			v1alpha1.TLSCAKey:         certPEM,
+		},
+	}
+}
+
+func TestCreateKeystoreData(t *testing.T) {
+	source := testTLSSecret(t)
+	data, err := createKeystoreData(source, "changeit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password := string(data[keystorePasswordKey]); password != "changeit" {
+		t.Errorf("expected the password to be kept, got %q", password)
+	}
+	_, cert, err := pkcs12.Decode(data[keystoreKey], "changeit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "zk" {
+		t.Errorf("unexpected key store certificate %s", cert.Subject)
+	}
+	if _, err = pkcs12.DecodeTrustStore(data[truststoreKey], "changeit"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateKeystoreDataMissingKeys(t *testing.T) {
+	tests := map[string]string{
+		v1alpha1.TLSCertKey:       "invalid certificate",
+		v1alpha1.TLSPrivateKeyKey: "invalid private key",
+		v1alpha1.TLSCAKey:         "invalid CA certificate",
+	}
+	for key, expected := range tests {
+		t.Run(key, func(t *testing.T) {
+			source := testTLSSecret(t)
+			delete(source.Data, key)
+			_, err := createKeystoreData(source, "changeit")
+			if err == nil || !strings.Contains(err.Error(), "error on converting the TLS secret (zk-tls) to PKCS12: "+expected) {
+				t.Errorf("expected the error to tell the %s is %s, got %v", key, expected, err)
+			}
+		})
+	}
+}
+
+func TestCreateTLSConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		tls      *v1alpha1.TLS
+		included []string
+		excluded []string
+	}{
+		{
+			name:     "disabled",
+			excluded: []string{"serverCnxnFactory", "ssl.keyStore.location", "sslQuorum"},
+		},
+		{
+			name:     "client",
+			tls:      &v1alpha1.TLS{Client: true, SecretName: "zk-tls"},
+			included: []string{"serverCnxnFactory", "ssl.keyStore.location", "ssl.trustStore.location", "ssl.clientAuth"},
+			excluded: []string{"sslQuorum", "ssl.quorum.keyStore.location"},
+		},
+		{
+			name:     "quorum",
+			tls:      &v1alpha1.TLS{Quorum: true, SecretName: "zk-tls"},
+			included: []string{"serverCnxnFactory", "sslQuorum", "ssl.quorum.keyStore.location", "ssl.quorum.trustStore.type"},
+			excluded: []string{"ssl.keyStore.location", "ssl.clientAuth"},
+		},
+		{
+			name:     "reload",
+			tls:      &v1alpha1.TLS{Client: true, Quorum: true, SecretName: "zk-tls", RotationStrategy: v1alpha1.TLSRotationReload},
+			included: []string{"client.certReload", "sslQuorumReloadCertFiles"},
+		},
+		{
+			name:     "restart",
+			tls:      &v1alpha1.TLS{Client: true, Quorum: true, SecretName: "zk-tls", RotationStrategy: v1alpha1.TLSRotationRollingRestart},
+			excluded: []string{"client.certReload", "sslQuorumReloadCertFiles"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &v1alpha1.ZookeeperCluster{ObjectMeta: metav1.ObjectMeta{Name: "zk", Namespace: "default"}}
+			c.Spec.TLS = tt.tls
+			c.SetSpecDefaults()
+			cfg := createTLSConfig(c)
+			for _, key := range tt.included {
+				if _, ok := cfg[key]; !ok {
+					t.Errorf("expected the key %s in %v", key, cfg)
+				}
+			}
+			for _, key := range tt.excluded {
+				if _, ok := cfg[key]; ok {
+					t.Errorf("unexpected key %s in %v", key, cfg)
+				}
+			}
+		})
+	}
+}
+
+// serveTLS serves the certificate of the TLS secret on a local port until the test ends
+func serveTLS(t *testing.T, source *v1.Secret) int32 {
+	t.Helper()
+	cert, err := tls.X509KeyPair(source.Data[v1alpha1.TLSCertKey], source.Data[v1alpha1.TLSPrivateKeyKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+	return int32(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestCheckTLSReload(t *testing.T) {
+	tests := []struct {
+		name      string
+		reloaded  bool
+		started   time.Duration
+		completed bool
+		restarted bool
+	}{
+		{name: "reloaded", reloaded: true, started: time.Minute, completed: true},
+		{name: "serving the previous certificate", started: time.Minute},
+		{name: "serving the previous certificate past the deadline", started: time.Hour, restarted: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, served := testTLSSecret(t), testTLSSecret(t)
+			if test.reloaded {
+				served = source
+			}
+			c := testConfigCluster(func(c *v1alpha1.ZookeeperCluster) {
+				c.Spec.TLS = &v1alpha1.TLS{Client: true, SecretName: "zk-tls", RotationStrategy: v1alpha1.TLSRotationReload}
+			})
+			c.Spec.Ports.SecureClient = serveTLS(t, served)
+			started := metav1.NewTime(time.Now().Add(-test.started))
+			c.Status.TLS = &v1alpha1.TLSStatus{
+				CertificateHash:   "renewed",
+				RotationPhase:     v1alpha1.TLSRotationPhaseRotating,
+				RotationStartTime: &started,
+			}
+			member := testPod(c.MemberPodName(1), true)
+			member.Labels = c.SelectorLabels()
+			member.Status.PodIP = "127.0.0.1"
+			ctx := reconcilertest.NewContext(c, member)
+			err := checkTLSReload(ctx, c, source)
+			if test.completed || test.restarted {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if _, ok := requeue.Delay(err); !ok {
+				t.Fatalf("expected a requeue, got %v", err)
+			}
+			if completed := c.Status.TLS.RotationPhase == v1alpha1.TLSRotationPhaseCompleted; completed != test.completed {
+				t.Errorf("expected the rotation completed=%t, got %+v", test.completed, c.Status.TLS)
+			}
+			annotations := tlsPodAnnotations(c)
+			if test.restarted {
+				expectEvent(t, ctx, v1.EventTypeWarning, eventReasonCertificatesReloadTimedOut)
+				if annotations[tlsHashAnnotation] != "renewed" {
+					t.Errorf("expected the members to be restarted with the renewed certificates, got %v", annotations)
+				}
+			} else if len(annotations) > 0 {
+				t.Errorf("expected the members not to be restarted, got %v", annotations)
+			}
+		})
+	}
+}
@@ -16,12 +16,16 @@
 
 package zookeepercluster
 
-func mergeLabels(ms ...map[string]string) map[string]string {
-	res := make(map[string]string)
-	for _, m := range ms {
-		for key, value := range m {
-			res[key] = value
-		}
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// hashData returns the hex encoded sha256 hash of the data
+func hashData(data ...[]byte) string {
+	hash := sha256.New()
+	for _, d := range data {
+		hash.Write(d)
 	}
-	return res
+	return hex.EncodeToString(hash.Sum(nil))
 }
@@ -0,0 +1,231 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/pvc"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	v13 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	volumeExpansionRequeueDelay = 15 * time.Second
+)
+
+// reconcileVolumeExpansion expands the members volumes whose claim template size grew. The claims are
+// patched when their storage class allows it, then the statefulset, whose claim templates are immutable,
+// is deleted without its pods to be recreated with the new templates. The progress of each claim is
+// reported under status.volumes. It returns whether the statefulset was deleted
+func reconcileVolumeExpansion(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, sts *v1.StatefulSet) (bool, error) {
+	grown := grownClaimTemplates(c, sts)
+	if len(grown) == 0 && !isVolumeExpansionInProgress(c) {
+		return false, nil
+	}
+	sizes := map[string]resource.Quantity{}
+	for _, template := range createPersistentVolumeClaims(c) {
+		sizes[template.Name] = template.Spec.Resources.Requests[v12.ResourceStorage]
+	}
+	claims, err := pvc.ListAllWithMatchingLabels(ctx.Client(), c.Namespace, c.SelectorLabels())
+	if err != nil {
+		return false, err
+	}
+	base := c.DeepCopy()
+	classes := map[string]bool{}
+	var volumes []v1alpha1.VolumeStatus
+	for i := range claims.Items {
+		claim := &claims.Items[i]
+		size, ok := sizes[claimTemplateName(sts, claim.Name)]
+		if !ok {
+			continue
+		}
+		if current := claim.Spec.Resources.Requests[v12.ResourceStorage]; current.Cmp(size) < 0 {
+			allowed, err := isVolumeExpansionAllowed(ctx, classes, claim.Spec.StorageClassName)
+			if err != nil {
+				return false, err
+			}
+			if !allowed {
+				volumes = append(volumes, v1alpha1.VolumeStatus{
+					Name: claim.Name, Size: size, Phase: v1alpha1.VolumeExpansionPhaseUnsupported,
+				})
+				continue
+			}
+			if err = expandClaim(ctx, c, claim, size); err != nil {
+				return false, err
+			}
+		}
+		volumes = append(volumes, createVolumeStatus(claim, size))
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	c.Status.Volumes = volumes
+	if !equality.Semantic.DeepEqual(base.Status.Volumes, c.Status.Volumes) {
+		ctx.Logger().Info("Updating the cluster volumes status",
+			"cluster", c.GetName(), "volumes", c.Status.Volumes)
+		// Only the volumes are patched since the status phase updates the rest of the status at the end
+		if err = ctx.Client().Status().Patch(context.TODO(), c, client.MergeFrom(base)); err != nil {
+			return false, err
+		}
+	}
+	if len(grown) == 0 {
+		return false, nil
+	}
+	for _, volume := range volumes {
+		if volume.Phase == v1alpha1.VolumeExpansionPhaseUnsupported {
+			if !isVolumeExpansionUnsupported(base.Status.Volumes, volume.Name) {
+				recordEvent(ctx, c, v12.EventTypeWarning, eventReasonVolumeExpansionUnsupported,
+					"The storage class of the pvc %s doesn't allow expanding its volume", volume.Name)
+			}
+			// The statefulset keeps the claim templates its volumes were created with
+			return false, nil
+		}
+	}
+	ctx.Logger().Info("Recreating the statefulset with the expanded volume claim templates",
+		"cluster", c.GetName(), "templates", grown)
+	orphan := metav1.DeletePropagationOrphan
+	if err = ctx.Client().Delete(context.TODO(), sts, &client.DeleteOptions{PropagationPolicy: &orphan}); err != nil {
+		return false, fmt.Errorf("error deleting the statefulset (%s) to update its volume claim templates: %w", sts.Name, err)
+	}
+	recordEvent(ctx, c, v12.EventTypeNormal, eventReasonVolumeExpansion,
+		"Recreating the statefulset %s with the expanded volume claim templates; the members keep running", sts.Name)
+	return true, requeue.After(volumeExpansionRequeueDelay, "waiting for the statefulset to be recreated")
+}
+
+// grownClaimTemplates returns the names of the claim templates whose size grew
+func grownClaimTemplates(c *v1alpha1.ZookeeperCluster, sts *v1.StatefulSet) []string {
+	current := map[string]resource.Quantity{}
+	for _, template := range sts.Spec.VolumeClaimTemplates {
+		current[template.Name] = template.Spec.Resources.Requests[v12.ResourceStorage]
+	}
+	var grown []string
+	for _, template := range createPersistentVolumeClaims(c) {
+		size, ok := current[template.Name]
+		if ok && size.Cmp(template.Spec.Resources.Requests[v12.ResourceStorage]) < 0 {
+			grown = append(grown, template.Name)
+		}
+	}
+	return grown
+}
+
+// claimTemplateName returns the name of the claim template of the statefulset claim, or empty if it isn't one.
+// The claims are named <template>-<statefulset>-<ordinal>; the longest matching template name is returned
+func claimTemplateName(sts *v1.StatefulSet, claimName string) string {
+	name := ""
+	for _, template := range sts.Spec.VolumeClaimTemplates {
+		ordinal, found := strings.CutPrefix(claimName, fmt.Sprintf("%s-%s-", template.Name, sts.Name))
+		if _, err := strconv.Atoi(ordinal); found && err == nil && len(template.Name) > len(name) {
+			name = template.Name
+		}
+	}
+	return name
+}
+
+func isVolumeExpansionInProgress(c *v1alpha1.ZookeeperCluster) bool {
+	for _, volume := range c.Status.Volumes {
+		if volume.Phase != v1alpha1.VolumeExpansionPhaseCompleted && volume.Phase != v1alpha1.VolumeExpansionPhaseUnsupported {
+			return true
+		}
+	}
+	return false
+}
+
+func isVolumeExpansionUnsupported(volumes []v1alpha1.VolumeStatus, name string) bool {
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return volume.Phase == v1alpha1.VolumeExpansionPhaseUnsupported
+		}
+	}
+	return false
+}
+
+// isVolumeExpansionAllowed tells whether the storage class allows expanding its volumes; the results are cached in the map
+func isVolumeExpansionAllowed(ctx reconciler.Context, classes map[string]bool, className *string) (bool, error) {
+	if className == nil || *className == "" {
+		return false, nil
+	}
+	if allowed, ok := classes[*className]; ok {
+		return allowed, nil
+	}
+	class := &v13.StorageClass{}
+	if err := ctx.Client().Get(context.TODO(), types.NamespacedName{Name: *className}, class); err != nil {
+		return false, fmt.Errorf("error getting the storage class (%s): %w", *className, err)
+	}
+	allowed := class.AllowVolumeExpansion != nil && *class.AllowVolumeExpansion
+	classes[*className] = allowed
+	return allowed, nil
+}
+
+// expandClaim requests the larger size on the claim; its provisioner expands the volume online
+func expandClaim(ctx reconciler.Context, c *v1alpha1.ZookeeperCluster, claim *v12.PersistentVolumeClaim, size resource.Quantity) error {
+	current := claim.Spec.Resources.Requests[v12.ResourceStorage]
+	ctx.Logger().Info("Expanding the member volume",
+		"cluster", c.GetName(), "PVC.Name", claim.Name, "from", current.String(), "to", size.String())
+	patch := client.MergeFrom(claim.DeepCopy())
+	claim.Spec.Resources.Requests = mergeResourceList(claim.Spec.Resources.Requests, v12.ResourceList{v12.ResourceStorage: size})
+	if err := ctx.Client().Patch(context.TODO(), claim, patch); err != nil {
+		return fmt.Errorf("error expanding the pvc (%s): %w", claim.Name, err)
+	}
+	recordEvent(ctx, c, v12.EventTypeNormal, eventReasonVolumeExpansion,
+		"Expanding the volume of the pvc %s from %s to %s", claim.Name, current.String(), size.String())
+	return nil
+}
+
+func createVolumeStatus(claim *v12.PersistentVolumeClaim, size resource.Quantity) v1alpha1.VolumeStatus {
+	status := v1alpha1.VolumeStatus{Name: claim.Name, Size: size, Phase: v1alpha1.VolumeExpansionPhaseRequested}
+	if capacity, ok := claim.Status.Capacity[v12.ResourceStorage]; ok {
+		status.Capacity = &capacity
+		if capacity.Cmp(size) >= 0 {
+			status.Phase = v1alpha1.VolumeExpansionPhaseCompleted
+			return status
+		}
+	}
+	for _, condition := range claim.Status.Conditions {
+		if condition.Status != v12.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case v12.PersistentVolumeClaimResizing:
+			status.Phase = v1alpha1.VolumeExpansionPhaseResizing
+		case v12.PersistentVolumeClaimFileSystemResizePending:
+			status.Phase = v1alpha1.VolumeExpansionPhaseFileSystemResizePending
+		}
+	}
+	return status
+}
+
+func mergeResourceList(lists ...v12.ResourceList) v12.ResourceList {
+	res := v12.ResourceList{}
+	for _, list := range lists {
+		for name, quantity := range list {
+			res[name] = quantity
+		}
+	}
+	return res
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeepercluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	v13 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"testing"
+)
+
+// testVolumesCluster returns a cluster of 3 members whose data volumes are of the size in the storage class
+func testVolumesCluster(size, storageClass string) *v1alpha1.ZookeeperCluster {
+	return testConfigCluster(func(c *v1alpha1.ZookeeperCluster) {
+		members := int32(3)
+		c.Spec.Size = &members
+		c.Spec.Persistence = &v1alpha1.Persistence{
+			VolumeClaimSpec: v12.PersistentVolumeClaimSpec{
+				StorageClassName: &storageClass,
+				Resources: v12.ResourceRequirements{
+					Requests: v12.ResourceList{v12.ResourceStorage: resource.MustParse(size)},
+				},
+			},
+		}
+	})
+}
+
+// testVolumeClaims returns the data claims of the statefulset members
+func testVolumeClaims(c *v1alpha1.ZookeeperCluster, sts *v1.StatefulSet) []client.Object {
+	var claims []client.Object
+	for i := int32(0); i < *sts.Spec.Replicas; i++ {
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			claim := &v12.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("%s-%s-%d", template.Name, sts.Name, i),
+					Namespace: c.Namespace,
+					Labels:    c.SelectorLabels(),
+				},
+				Spec: template.Spec,
+			}
+			claim.Status.Capacity = template.Spec.Resources.Requests
+			claims = append(claims, claim)
+		}
+	}
+	return claims
+}
+
+func TestClaimTemplateName(t *testing.T) {
+	tests := []struct {
+		sts, claim string
+		expected   string
+	}{
+		{"zk", "data-zk-0", PvcDataVolumeName},
+		{"zk", "data-log-zk-2", PvcDataLogVolumeName},
+		{"log-zk", "data-log-zk-1", PvcDataVolumeName},
+		{"log-zk", "data-log-log-zk-1", PvcDataLogVolumeName},
+		{"zk", "data-zk-a", ""},
+		{"zk", "data-zk-", ""},
+		{"zk", "data-other-0", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.claim, func(t *testing.T) {
+			sts := createStatefulSet(testVolumesCluster("10Gi", "standard"), 3)
+			sts.Name = test.sts
+			if name := claimTemplateName(sts, test.claim); name != test.expected {
+				t.Errorf("expected the template %q of the claim %s, got %q", test.expected, test.claim, name)
+			}
+		})
+	}
+}
+
+func TestGrownClaimTemplates(t *testing.T) {
+	sts := createStatefulSet(testVolumesCluster("10Gi", "standard"), 3)
+	tests := map[string][]string{
+		"10Gi": nil,
+		"5Gi":  nil,
+		"20Gi": {PvcDataVolumeName},
+	}
+	for size, expected := range tests {
+		if grown := grownClaimTemplates(testVolumesCluster(size, "standard"), sts); !reflect.DeepEqual(grown, expected) {
+			t.Errorf("expected the templates %v to grow to %s, got %v", expected, size, grown)
+		}
+	}
+}
+
+func TestCreateVolumeStatus(t *testing.T) {
+	condition := func(conditionType v12.PersistentVolumeClaimConditionType) []v12.PersistentVolumeClaimCondition {
+		return []v12.PersistentVolumeClaimCondition{{Type: conditionType, Status: v12.ConditionTrue}}
+	}
+	tests := []struct {
+		name     string
+		status   v12.PersistentVolumeClaimStatus
+		expected v1alpha1.VolumeExpansionPhase
+	}{
+		{
+			name:     "requested",
+			expected: v1alpha1.VolumeExpansionPhaseRequested,
+		},
+		{
+			name: "resizing",
+			status: v12.PersistentVolumeClaimStatus{
+				Capacity:   v12.ResourceList{v12.ResourceStorage: resource.MustParse("10Gi")},
+				Conditions: condition(v12.PersistentVolumeClaimResizing),
+			},
+			expected: v1alpha1.VolumeExpansionPhaseResizing,
+		},
+		{
+			name: "file system resize pending",
+			status: v12.PersistentVolumeClaimStatus{
+				Capacity:   v12.ResourceList{v12.ResourceStorage: resource.MustParse("10Gi")},
+				Conditions: condition(v12.PersistentVolumeClaimFileSystemResizePending),
+			},
+			expected: v1alpha1.VolumeExpansionPhaseFileSystemResizePending,
+		},
+		{
+			name: "completed",
+			status: v12.PersistentVolumeClaimStatus{
+				Capacity:   v12.ResourceList{v12.ResourceStorage: resource.MustParse("20Gi")},
+				Conditions: condition(v12.PersistentVolumeClaimFileSystemResizePending),
+			},
+			expected: v1alpha1.VolumeExpansionPhaseCompleted,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claim := &v12.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-zk-0"}, Status: test.status}
+			status := createVolumeStatus(claim, resource.MustParse("20Gi"))
+			if status.Phase != test.expected {
+				t.Errorf("expected the phase %s, got %s", test.expected, status.Phase)
+			}
+			if capacity, ok := test.status.Capacity[v12.ResourceStorage]; ok && (status.Capacity == nil || !status.Capacity.Equal(capacity)) {
+				t.Errorf("expected the capacity %s, got %v", capacity.String(), status.Capacity)
+			}
+		})
+	}
+}
+
+// expectVolumePhases checks the expansion phase of the data volumes; the data log ones are left as is
+func expectVolumePhases(t *testing.T, c *v1alpha1.ZookeeperCluster, phase v1alpha1.VolumeExpansionPhase) {
+	t.Helper()
+	if len(c.Status.Volumes) != 6 {
+		t.Fatalf("expected the status of the 6 volumes, got %v", c.Status.Volumes)
+	}
+	for _, volume := range c.Status.Volumes {
+		expected := phase
+		if strings.HasPrefix(volume.Name, PvcDataLogVolumeName+"-") {
+			expected = v1alpha1.VolumeExpansionPhaseCompleted
+		}
+		if volume.Phase != expected {
+			t.Errorf("expected the volume %s to be %s, got %s", volume.Name, expected, volume.Phase)
+		}
+	}
+}
+
+func TestReconcileVolumeExpansion(t *testing.T) {
+	allowed := true
+	c := testVolumesCluster("20Gi", "expandable")
+	sts := createStatefulSet(testVolumesCluster("10Gi", "expandable"), 3)
+	objects := append(testVolumeClaims(c, sts), c, sts,
+		&v13.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "expandable"}, AllowVolumeExpansion: &allowed})
+	ctx := reconcilertest.NewContext(objects...)
+	// the status changed since the cluster was read
+	stored := c.DeepCopy()
+	stored.Status.AuthenticationHash = "updated"
+	if err := ctx.Client().Status().Update(context.TODO(), stored); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := reconcileVolumeExpansion(ctx, c, sts)
+	if _, ok := requeue.Delay(err); !ok || !deleted {
+		t.Fatalf("expected the statefulset to be deleted, got %t, %v", deleted, err)
+	}
+	if err = ctx.Client().Get(context.TODO(), client.ObjectKeyFromObject(c), stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status.AuthenticationHash != "updated" || len(stored.Status.Volumes) != len(c.Status.Volumes) {
+		t.Errorf("expected only the volumes status to be patched, got %+v", stored.Status)
+	}
+	claim := &v12.PersistentVolumeClaim{}
+	if err = ctx.Client().Get(context.TODO(), types.NamespacedName{Name: "data-zk-1", Namespace: "default"}, claim); err != nil {
+		t.Fatal(err)
+	}
+	if size := claim.Spec.Resources.Requests[v12.ResourceStorage]; size.String() != "20Gi" {
+		t.Errorf("expected the claim to request 20Gi, got %s", size.String())
+	}
+	expectVolumePhases(t, c, v1alpha1.VolumeExpansionPhaseRequested)
+	if err = ctx.Client().Get(context.TODO(), client.ObjectKeyFromObject(sts), &v1.StatefulSet{}); err == nil {
+		t.Error("expected the statefulset to be deleted")
+	}
+	expectEvent(t, ctx, v12.EventTypeNormal, eventReasonVolumeExpansion)
+}
+
+func TestReconcileVolumeExpansionUnsupported(t *testing.T) {
+	c := testVolumesCluster("20Gi", "fixed")
+	sts := createStatefulSet(testVolumesCluster("10Gi", "fixed"), 3)
+	objects := append(testVolumeClaims(c, sts), c, sts, &v13.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fixed"}})
+	ctx := reconcilertest.NewContext(objects...)
+	deleted, err := reconcileVolumeExpansion(ctx, c, sts)
+	if err != nil || deleted {
+		t.Fatalf("expected the statefulset to be kept, got %t, %v", deleted, err)
+	}
+	expectVolumePhases(t, c, v1alpha1.VolumeExpansionPhaseUnsupported)
+	expectEvent(t, ctx, v12.EventTypeWarning, eventReasonVolumeExpansionUnsupported)
+
+	// the warning is recorded once
+	if _, err = reconcileVolumeExpansion(ctx, c, sts); err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx.Recorder.Events) > 0 {
+		t.Errorf("expected no event, got %q", <-ctx.Recorder.Events)
+	}
+}
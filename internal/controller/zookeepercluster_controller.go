@@ -21,31 +21,48 @@ import (
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
 	zookeepercluster2 "github.com/monimesl/zookeeper-operator/internal/controller/zookeepercluster"
+	"github.com/monimesl/zookeeper-operator/internal/metrics"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
 	v12 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	v13 "k8s.io/api/policy/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
 )
 
 var (
-	_              reconciler.Context    = &ZookeeperClusterReconciler{}
-	_              reconciler.Reconciler = &ZookeeperClusterReconciler{}
-	reconcileFuncs                       = []func(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error{
-		zookeepercluster2.ReconcileFinalizer,
-		zookeepercluster2.ReconcilePodDisruptionBudget,
-		zookeepercluster2.ReconcileConfigMap,
-		zookeepercluster2.ReconcileServices,
-		zookeepercluster2.ReconcileStatefulSet,
-		zookeepercluster2.ReconcileClusterStatus,
+	_              reconciler.Context               = &ZookeeperClusterReconciler{}
+	_              reconciler.Reconciler            = &ZookeeperClusterReconciler{}
+	_              zookeepercluster2.EventRecording = &ZookeeperClusterReconciler{}
+	reconcileFuncs                                  = []struct {
+		phase string
+		fun   func(ctx reconciler.Context, cluster *v1alpha1.ZookeeperCluster) error
+	}{
+		{"finalizer", zookeepercluster2.ReconcileFinalizer},
+		{"pdb", zookeepercluster2.ReconcilePodDisruptionBudget},
+		{"tls", zookeepercluster2.ReconcileTLS},
+		{"authentication", zookeepercluster2.ReconcileAuthentication},
+		{"configmap", zookeepercluster2.ReconcileConfigMap},
+		{"services", zookeepercluster2.ReconcileServices},
+		{"monitoring", zookeepercluster2.ReconcileMonitoring},
+		{"statefulset", zookeepercluster2.ReconcileStatefulSet},
+		{"membership", zookeepercluster2.ReconcileMembership},
+		{"status", zookeepercluster2.ReconcileClusterStatus},
 	}
 )
 
 // ZookeeperClusterReconciler defines the reconciler to reconcile ZookeeperCluster resources
 type ZookeeperClusterReconciler struct {
 	reconciler.Context
+	// Recorder records the events of the reconciled clusters
+	Recorder record.EventRecorder
 }
 
-// Configure configures the above ZookeeperClusterReconciler
+// Configure configures the above ZookeeperClusterReconciler. The secrets are only cached
+// with the watched label, which the operator sets on the ones it creates or the clusters reference
 func (r *ZookeeperClusterReconciler) Configure(ctx reconciler.Context) error {
 	r.Context = ctx
 	return ctx.NewControllerBuilder().
@@ -54,18 +71,83 @@ func (r *ZookeeperClusterReconciler) Configure(ctx reconciler.Context) error {
 		Owns(&v12.StatefulSet{}).
 		Owns(&v1.ConfigMap{}).
 		Owns(&v1.Service{}).
+		Owns(&v1.Secret{}).
+		Watches(&v1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.clustersReferencingSecret)).
 		Complete(r)
 }
 
+// EventRecorder returns the recorder of the cluster events
+func (r *ZookeeperClusterReconciler) EventRecorder() record.EventRecorder {
+	return r.Recorder
+}
+
+// clustersReferencingSecret maps a certificate or credentials secret to the clusters using it
+// so renewed certificates and changed credentials are rolled out without waiting for a resync
+func (r *ZookeeperClusterReconciler) clustersReferencingSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	clusters := &v1alpha1.ZookeeperClusterList{}
+	if err := r.Client().List(ctx, clusters, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Logger().Info("Error listing the clusters referencing the secret",
+			"secret", obj.GetName(), "error", err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range clusters.Items {
+		cluster := &clusters.Items[i]
+		if referencesSecret(cluster, obj.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(cluster),
+			})
+		}
+	}
+	return requests
+}
+
+func referencesSecret(cluster *v1alpha1.ZookeeperCluster, name string) bool {
+	if cluster.IsTLSEnabled() && cluster.TLSSecretName() == name {
+		return true
+	}
+	auth := cluster.Spec.Authentication
+	if auth == nil {
+		return false
+	}
+	if auth.OperatorCredentialsSecret == name || (auth.Quorum != nil && auth.Quorum.CredentialsSecret == name) {
+		return true
+	}
+	return auth.Client != nil && (auth.Client.UsersSecret == name ||
+		(auth.Client.Kerberos != nil && auth.Client.Kerberos.KeytabSecret == name))
+}
+
 // Reconcile handles reconciliation request for ZookeeperCluster instances
 func (r *ZookeeperClusterReconciler) Reconcile(_ context.Context, request reconcile.Request) (reconcile.Result, error) {
 	cluster := &v1alpha1.ZookeeperCluster{}
-	return r.Run(request, cluster, func(_ bool) (err error) {
-		for _, fun := range reconcileFuncs {
-			if err = fun(r, cluster); err != nil {
+	var requeueAfter time.Duration
+	result, err := r.Run(request, cluster, func(_ bool) (err error) {
+		for _, f := range reconcileFuncs {
+			start := time.Now()
+			err = f.fun(r, cluster)
+			after, requeued := requeue.Delay(err)
+			if requeued {
+				metrics.ObserveReconcilePhase(f.phase, start, nil)
+			} else {
+				metrics.ObserveReconcilePhase(f.phase, start, err)
+			}
+			if err != nil {
+				if requeued {
+					// Not a failure; run the remaining functions and come back later
+					if requeueAfter == 0 || after < requeueAfter {
+						requeueAfter = after
+					}
+					err = nil
+					continue
+				}
+				zookeepercluster2.RecordReconcileFailure(r, cluster, err)
 				break
 			}
 		}
 		return
 	})
+	if err == nil && requeueAfter > 0 {
+		result.RequeueAfter = requeueAfter
+	}
+	return result, err
 }
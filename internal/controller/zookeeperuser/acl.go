@@ -0,0 +1,116 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeperuser
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// ReconcileACLs applies the declared ACLs of the specified user on its cluster
+// and revokes the ones removed from the spec
+func ReconcileACLs(ctx reconciler.Context, user *v1alpha1.ZookeeperUser) error {
+	if !user.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	oldStatus := user.Status.DeepCopy()
+	err := applyACLs(ctx, user)
+	user.Status.ObservedGeneration = user.Generation
+	if !equality.Semantic.DeepEqual(oldStatus, &user.Status) {
+		if updateErr := ctx.Client().Status().Update(context.TODO(), user); updateErr != nil && err == nil {
+			err = updateErr
+		}
+	}
+	return err
+}
+
+func applyACLs(ctx reconciler.Context, user *v1alpha1.ZookeeperUser) error {
+	cluster, err := getCluster(ctx, user)
+	if err != nil {
+		return err
+	}
+	if cluster == nil {
+		setCondition(user, v1alpha1.ConditionACLsApplied, false, reasonClusterNotFound,
+			fmt.Sprintf("the cluster %s is not found", user.Spec.ClusterRef))
+		return requeue.After(clusterRequeueDelay, "waiting for the cluster to be created")
+	}
+	if !cluster.IsAvailable() {
+		setCondition(user, v1alpha1.ConditionACLsApplied, false, reasonClusterUnavailable,
+			fmt.Sprintf("the cluster %s is not available", cluster.Name))
+		return requeue.After(clusterRequeueDelay, "waiting for the cluster to be available")
+	}
+	digest, err := getDigest(ctx, user)
+	if err != nil {
+		return err
+	}
+	cl, err := zk.NewZkClient(ctx.Client(), cluster)
+	if err != nil {
+		setCondition(user, v1alpha1.ConditionACLsApplied, false, reasonApplyFailed, err.Error())
+		return err
+	}
+	defer cl.Close()
+	if err = revokeACLs(cl, user, removedACLs(user)); err != nil {
+		setCondition(user, v1alpha1.ConditionACLsApplied, false, reasonApplyFailed, err.Error())
+		return err
+	}
+	for _, acl := range user.Spec.ACLs {
+		ctx.Logger().Info("Applying the zookeeper user ACL",
+			"user", user.Name, "path", acl.Path, "permissions", acl.Permissions)
+		if err = cl.GrantDigestACL(acl.Path, digest, acl.Permissions); err != nil {
+			err = fmt.Errorf("error applying the ACL on %s: %w", acl.Path, err)
+			setCondition(user, v1alpha1.ConditionACLsApplied, false, reasonApplyFailed, err.Error())
+			return err
+		}
+	}
+	user.Status.Username = user.Username()
+	user.Status.ACLs = user.Spec.ACLs
+	setCondition(user, v1alpha1.ConditionACLsApplied, true, reasonApplied,
+		fmt.Sprintf("%d ACLs applied", len(user.Spec.ACLs)))
+	return nil
+}
+
+// removedACLs returns the applied ACLs which are no longer applicable; all of them when the username changed
+func removedACLs(user *v1alpha1.ZookeeperUser) []v1alpha1.ACL {
+	if user.Status.Username != user.Username() {
+		return user.Status.ACLs
+	}
+	declared := map[string]bool{}
+	for _, acl := range user.Spec.ACLs {
+		declared[acl.Path] = true
+	}
+	var removed []v1alpha1.ACL
+	for _, acl := range user.Status.ACLs {
+		if !declared[acl.Path] {
+			removed = append(removed, acl)
+		}
+	}
+	return removed
+}
+
+func revokeACLs(cl *zk.Client, user *v1alpha1.ZookeeperUser, acls []v1alpha1.ACL) error {
+	for _, acl := range acls {
+		if err := cl.RevokeDigestACL(acl.Path, user.Status.Username); err != nil {
+			return fmt.Errorf("error revoking the ACL on %s: %w", acl.Path, err)
+		}
+	}
+	return nil
+}
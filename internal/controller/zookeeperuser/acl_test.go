@@ -0,0 +1,116 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeperuser
+
+import (
+	"context"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestReconcileACLsWaitsForTheCluster(t *testing.T) {
+	tests := []struct {
+		name     string
+		objects  []client.Object
+		expected string
+	}{
+		{
+			name:     "cluster not found",
+			expected: reasonClusterNotFound,
+		},
+		{
+			name: "cluster unavailable",
+			objects: []client.Object{&v1alpha1.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "zk", Namespace: "default"},
+			}},
+			expected: reasonClusterUnavailable,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user := testUser(nil)
+			ctx := reconcilertest.NewContext(append(test.objects, user)...)
+			err := ReconcileACLs(ctx, user)
+			if after, ok := requeue.Delay(err); !ok || after != clusterRequeueDelay {
+				t.Errorf("expected a requeue after %s, got %v", clusterRequeueDelay, err)
+			}
+			condition := meta.FindStatusCondition(user.Status.Conditions, v1alpha1.ConditionACLsApplied)
+			if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != test.expected {
+				t.Errorf("expected the %s condition to be false with the reason %s, got %v",
+					v1alpha1.ConditionACLsApplied, test.expected, condition)
+			}
+			stored := &v1alpha1.ZookeeperUser{}
+			if err = ctx.Client().Get(context.TODO(), client.ObjectKeyFromObject(user), stored); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(stored.Status.Conditions, user.Status.Conditions) {
+				t.Errorf("expected the status to be saved, got %v", stored.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestRemovedACLs(t *testing.T) {
+	read := []v1alpha1.ACLPermission{v1alpha1.ACLPermissionRead}
+	applied := []v1alpha1.ACL{{Path: "/app", Permissions: read}, {Path: "/legacy", Permissions: read}}
+	tests := []struct {
+		name     string
+		mutate   func(user *v1alpha1.ZookeeperUser)
+		expected []v1alpha1.ACL
+	}{
+		{
+			name: "nothing applied yet",
+		},
+		{
+			name: "path removed from the spec",
+			mutate: func(user *v1alpha1.ZookeeperUser) {
+				user.Status.Username = "app"
+				user.Status.ACLs = applied
+			},
+			expected: applied[1:],
+		},
+		{
+			name: "permissions changed on a declared path",
+			mutate: func(user *v1alpha1.ZookeeperUser) {
+				user.Status.Username = "app"
+				user.Status.ACLs = []v1alpha1.ACL{{Path: "/app", Permissions: []v1alpha1.ACLPermission{v1alpha1.ACLPermissionAdmin}}}
+			},
+		},
+		{
+			name: "username changed",
+			mutate: func(user *v1alpha1.ZookeeperUser) {
+				user.Spec.Username = "renamed"
+				user.Status.Username = "app"
+				user.Status.ACLs = applied
+			},
+			expected: applied,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if removed := removedACLs(testUser(test.mutate)); !reflect.DeepEqual(removed, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, removed)
+			}
+		})
+	}
+}
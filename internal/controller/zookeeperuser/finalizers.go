@@ -0,0 +1,77 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeperuser
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/oputil"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+)
+
+const (
+	finalizerName = "zookeeperuser.monime.sl-finalizer"
+)
+
+// ReconcileFinalizer reconcile the finalizer of the specified user
+func ReconcileFinalizer(ctx reconciler.Context, user *v1alpha1.ZookeeperUser) error {
+	if user.DeletionTimestamp.IsZero() {
+		if !oputil.Contains(user.Finalizers, finalizerName) {
+			ctx.Logger().Info("Adding the finalizer to the user",
+				"user", user.Name, "finalizer", finalizerName)
+			user.Finalizers = append(user.Finalizers, finalizerName)
+			return ctx.Client().Update(context.TODO(), user)
+		}
+		return nil
+	}
+	if !oputil.Contains(user.Finalizers, finalizerName) {
+		return nil
+	}
+	if err := cleanUpACLs(ctx, user); err != nil {
+		return fmt.Errorf("ZookeeperUser object (%s) ACLs cleanup error: %w", user.Name, err)
+	}
+	user.Finalizers = oputil.Remove(finalizerName, user.Finalizers)
+	ctx.Logger().Info("Saving updated user finalizers",
+		"user", user.Name, "finalizers", user.Finalizers)
+	if err := ctx.Client().Update(context.TODO(), user); err != nil {
+		return fmt.Errorf("ZookeeperUser object (%s) update error: %w", user.Name, err)
+	}
+	return nil
+}
+
+// cleanUpACLs revokes the applied ACLs; there's nothing to clean when the cluster is gone
+func cleanUpACLs(ctx reconciler.Context, user *v1alpha1.ZookeeperUser) error {
+	if len(user.Status.ACLs) == 0 {
+		return nil
+	}
+	cluster, err := getCluster(ctx, user)
+	if err != nil {
+		return err
+	}
+	if cluster == nil || !cluster.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	ctx.Logger().Info("Revoking the user ACLs", "user", user.Name, "cluster", cluster.Name)
+	cl, err := zk.NewZkClient(ctx.Client(), cluster)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	return revokeACLs(cl, user, user.Status.ACLs)
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeperuser
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/oputil"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestReconcileFinalizer(t *testing.T) {
+	user := testUser(nil)
+	ctx := reconcilertest.NewContext(user)
+	if err := ReconcileFinalizer(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if !oputil.Contains(user.Finalizers, finalizerName) {
+		t.Fatalf("expected the finalizer to be added, got %v", user.Finalizers)
+	}
+
+	// the ACLs can't be revoked once the cluster is gone, the finalizer is removed right away
+	now := metav1.Now()
+	deleted := testUser(func(user *v1alpha1.ZookeeperUser) {
+		user.Finalizers = []string{finalizerName}
+		user.DeletionTimestamp = &now
+		user.Status.Username = "app"
+		user.Status.ACLs = user.Spec.ACLs
+	})
+	ctx = reconcilertest.NewContext(deleted)
+	if err := ReconcileFinalizer(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+	err := ctx.Client().Get(context.TODO(), client.ObjectKeyFromObject(deleted), &v1alpha1.ZookeeperUser{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected the user to be deleted once its finalizer is removed, got %v", err)
+	}
+}
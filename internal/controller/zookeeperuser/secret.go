@@ -0,0 +1,120 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeperuser
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/k8s/secret"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const passwordLen = 24
+
+// ReconcileSecret reconcile the secret holding the credentials of the specified user
+func ReconcileSecret(ctx reconciler.Context, user *v1alpha1.ZookeeperUser) error {
+	if !user.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	s := &v1.Secret{}
+	err := ctx.GetResource(types.NamespacedName{
+		Name:      user.SecretName(),
+		Namespace: user.Namespace,
+	}, s,
+		// Found
+		func() error {
+			password := string(s.Data[v1alpha1.CredentialsPasswordKey])
+			if password == "" {
+				return updateSecret(ctx, s, user)
+			}
+			data := createSecretData(user.Username(), password)
+			if string(s.Data[v1alpha1.CredentialsUsernameKey]) == user.Username() &&
+				string(s.Data[v1alpha1.CredentialsDigestKey]) == string(data[v1alpha1.CredentialsDigestKey]) &&
+				s.Labels[internal.WatchedSecretLabel] != "" {
+				return nil
+			}
+			s.Data = data
+			s.Labels = internal.MergeLabels(s.Labels, internal.WatchedSecretLabels())
+			ctx.Logger().Info("Updating the zookeeper user secret.",
+				"Secret.Name", s.GetName(),
+				"Secret.Namespace", s.GetNamespace())
+			return ctx.Client().Update(context.TODO(), s)
+		},
+		// Not Found
+		func() error {
+			password, err := secret.NewPassword(passwordLen)
+			if err != nil {
+				return err
+			}
+			s = secret.New(user.Namespace, user.SecretName(), createSecretData(user.Username(), password))
+			s.Labels = internal.WatchedSecretLabels()
+			if err = ctx.SetOwnershipReference(user, s); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the zookeeper user secret.",
+				"Secret.Name", s.GetName(),
+				"Secret.Namespace", s.GetNamespace())
+			return ctx.Client().Create(context.TODO(), s)
+		})
+	if err != nil {
+		return err
+	}
+	if user.Status.SecretName != user.SecretName() {
+		user.Status.SecretName = user.SecretName()
+		return ctx.Client().Status().Update(context.TODO(), user)
+	}
+	return nil
+}
+
+// updateSecret generates a new password when the existing secret has none
+func updateSecret(ctx reconciler.Context, s *v1.Secret, user *v1alpha1.ZookeeperUser) error {
+	password, err := secret.NewPassword(passwordLen)
+	if err != nil {
+		return err
+	}
+	s.Data = createSecretData(user.Username(), password)
+	s.Labels = internal.MergeLabels(s.Labels, internal.WatchedSecretLabels())
+	ctx.Logger().Info("Generating the password of the zookeeper user secret.",
+		"Secret.Name", s.GetName(),
+		"Secret.Namespace", s.GetNamespace())
+	return ctx.Client().Update(context.TODO(), s)
+}
+
+// getDigest returns the digest scheme id of the user from its secret
+func getDigest(ctx reconciler.Context, user *v1alpha1.ZookeeperUser) (string, error) {
+	s := &v1.Secret{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      user.SecretName(),
+		Namespace: user.Namespace,
+	}, s)
+	if err != nil {
+		return "", err
+	}
+	return string(s.Data[v1alpha1.CredentialsDigestKey]), nil
+}
+
+func createSecretData(username, password string) map[string][]byte {
+	return map[string][]byte{
+		v1alpha1.CredentialsUsernameKey: []byte(username),
+		v1alpha1.CredentialsPasswordKey: []byte(password),
+		v1alpha1.CredentialsDigestKey:   []byte(zk.Digest(username, password)),
+	}
+}
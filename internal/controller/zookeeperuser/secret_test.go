@@ -0,0 +1,123 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeperuser
+
+import (
+	"context"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func testUser(mutate func(user *v1alpha1.ZookeeperUser)) *v1alpha1.ZookeeperUser {
+	user := &v1alpha1.ZookeeperUser{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", UID: "app-uid"},
+		Spec: v1alpha1.ZookeeperUserSpec{
+			ClusterRef: "zk",
+			ACLs: []v1alpha1.ACL{
+				{Path: "/app", Permissions: []v1alpha1.ACLPermission{v1alpha1.ACLPermissionRead}},
+			},
+		},
+	}
+	if mutate != nil {
+		mutate(user)
+	}
+	return user
+}
+
+func getSecret(t *testing.T, c client.Client, name string) *v1.Secret {
+	t.Helper()
+	s := &v1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "default"}, s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestReconcileSecret(t *testing.T) {
+	user := testUser(nil)
+	ctx := reconcilertest.NewContext(user)
+	if err := ReconcileSecret(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	s := getSecret(t, ctx.Client(), "app-zookeeper-user")
+	password := string(s.Data[v1alpha1.CredentialsPasswordKey])
+	if len(password) != passwordLen {
+		t.Errorf("expected a generated password of %d characters, got %q", passwordLen, password)
+	}
+	if string(s.Data[v1alpha1.CredentialsUsernameKey]) != "app" ||
+		string(s.Data[v1alpha1.CredentialsDigestKey]) != zk.Digest("app", password) {
+		t.Errorf("unexpected secret data: %v", s.Data)
+	}
+	if len(s.OwnerReferences) != 1 || s.OwnerReferences[0].Name != "app" {
+		t.Errorf("expected the secret to be owned by the user, got %v", s.OwnerReferences)
+	}
+	if user.Status.SecretName != "app-zookeeper-user" {
+		t.Errorf("expected the secret name in the status, got %q", user.Status.SecretName)
+	}
+
+	// the password is kept and the digest follows the username
+	user.Spec.Username = "renamed"
+	if err := ReconcileSecret(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	s = getSecret(t, ctx.Client(), "app-zookeeper-user")
+	if string(s.Data[v1alpha1.CredentialsPasswordKey]) != password {
+		t.Error("expected the password to be kept")
+	}
+	if string(s.Data[v1alpha1.CredentialsUsernameKey]) != "renamed" ||
+		string(s.Data[v1alpha1.CredentialsDigestKey]) != zk.Digest("renamed", password) {
+		t.Errorf("unexpected secret data: %v", s.Data)
+	}
+}
+
+func TestReconcileSecretExisting(t *testing.T) {
+	user := testUser(func(user *v1alpha1.ZookeeperUser) {
+		user.Spec.SecretName = "app-credentials"
+	})
+	provided := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-credentials", Namespace: "default", Labels: map[string]string{"team": "app"}},
+		Data:       map[string][]byte{v1alpha1.CredentialsPasswordKey: []byte("provided")},
+	}
+	empty := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "empty-credentials", Namespace: "default"}}
+	ctx := reconcilertest.NewContext(user, provided, empty)
+	if err := ReconcileSecret(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	s := getSecret(t, ctx.Client(), "app-credentials")
+	if string(s.Data[v1alpha1.CredentialsDigestKey]) != zk.Digest("app", "provided") {
+		t.Errorf("expected the digest of the provided password, got %v", s.Data)
+	}
+	if s.Labels["team"] != "app" || s.Labels[internal.WatchedSecretLabel] != "true" {
+		t.Errorf("expected the watched label to be added to the secret labels, got %v", s.Labels)
+	}
+
+	user.Spec.SecretName = "empty-credentials"
+	if err := ReconcileSecret(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	s = getSecret(t, ctx.Client(), "empty-credentials")
+	if len(s.Data[v1alpha1.CredentialsPasswordKey]) != passwordLen {
+		t.Errorf("expected a password to be generated, got %v", s.Data)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/controller/zookeeperuser"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
+)
+
+var (
+	_                  reconciler.Context    = &ZookeeperUserReconciler{}
+	_                  reconciler.Reconciler = &ZookeeperUserReconciler{}
+	userReconcileFuncs                       = []func(ctx reconciler.Context, user *v1alpha1.ZookeeperUser) error{
+		zookeeperuser.ReconcileFinalizer,
+		zookeeperuser.ReconcileSecret,
+		zookeeperuser.ReconcileACLs,
+	}
+)
+
+// ZookeeperUserReconciler defines the reconciler to reconcile ZookeeperUser resources
+type ZookeeperUserReconciler struct {
+	reconciler.Context
+}
+
+// Configure configures the above ZookeeperUserReconciler
+func (r *ZookeeperUserReconciler) Configure(ctx reconciler.Context) error {
+	r.Context = ctx
+	return ctx.NewControllerBuilder().
+		For(&v1alpha1.ZookeeperUser{}).
+		Owns(&v1.Secret{}).
+		Watches(&v1alpha1.ZookeeperCluster{}, handler.EnqueueRequestsFromMapFunc(r.usersOfCluster)).
+		Complete(r)
+}
+
+// usersOfCluster maps a cluster to its users so the ACLs are applied once the cluster is available
+func (r *ZookeeperUserReconciler) usersOfCluster(ctx context.Context, obj client.Object) []reconcile.Request {
+	users := &v1alpha1.ZookeeperUserList{}
+	if err := r.Client().List(ctx, users, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Logger().Info("Error listing the users of the cluster",
+			"cluster", obj.GetName(), "error", err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range users.Items {
+		if users.Items[i].Spec.ClusterRef == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&users.Items[i]),
+			})
+		}
+	}
+	return requests
+}
+
+// Reconcile handles reconciliation request for ZookeeperUser instances
+func (r *ZookeeperUserReconciler) Reconcile(_ context.Context, request reconcile.Request) (reconcile.Result, error) {
+	user := &v1alpha1.ZookeeperUser{}
+	var requeueAfter time.Duration
+	result, err := r.Run(request, user, func(_ bool) (err error) {
+		for _, fun := range userReconcileFuncs {
+			if err = fun(r, user); err != nil {
+				if after, ok := requeue.Delay(err); ok {
+					requeueAfter = after
+					err = nil
+					continue
+				}
+				break
+			}
+		}
+		return
+	})
+	if err == nil && requeueAfter > 0 {
+		result.RequeueAfter = requeueAfter
+	}
+	return result, err
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeperznode
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"time"
+)
+
+const (
+	clusterRequeueDelay = 30 * time.Second
+	// usageRefreshDelay is how often the usage of a znode with a quota is refreshed
+	usageRefreshDelay = time.Minute
+
+	reasonClusterNotFound    = "ClusterNotFound"
+	reasonClusterUnavailable = "ClusterUnavailable"
+	reasonInvalidPath        = "InvalidPath"
+	reasonSyncFailed         = "SyncFailed"
+	reasonSynced             = "Synced"
+)
+
+// getCluster returns the cluster the znode belongs to or nil when it doesn't exist
+func getCluster(ctx reconciler.Context, znode *v1alpha1.ZookeeperZNode) (*v1alpha1.ZookeeperCluster, error) {
+	cluster := &v1alpha1.ZookeeperCluster{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      znode.Spec.ClusterRef,
+		Namespace: znode.Namespace,
+	}, cluster)
+	if errors.IsNotFound(err) {
+		return nil, nil //nolint:nilnil
+	} else if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func setCondition(z *v1alpha1.ZookeeperZNode, conditionType string, status bool, reason, message string) {
+	conditionStatus := metav1.ConditionFalse
+	if status {
+		conditionStatus = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&z.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: z.Generation,
+	})
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeperznode
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/oputil"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+)
+
+const (
+	finalizerName = "zookeeperznode.monime.sl-finalizer"
+)
+
+// ReconcileFinalizer reconcile the finalizer of the specified znode
+func ReconcileFinalizer(ctx reconciler.Context, znode *v1alpha1.ZookeeperZNode) error {
+	if znode.DeletionTimestamp.IsZero() {
+		if !oputil.Contains(znode.Finalizers, finalizerName) {
+			ctx.Logger().Info("Adding the finalizer to the znode",
+				"znode", znode.Name, "finalizer", finalizerName)
+			znode.Finalizers = append(znode.Finalizers, finalizerName)
+			return ctx.Client().Update(context.TODO(), znode)
+		}
+		return nil
+	}
+	if !oputil.Contains(znode.Finalizers, finalizerName) {
+		return nil
+	}
+	if znode.ShouldDeleteSubtree() {
+		if err := cleanUpZNode(ctx, znode); err != nil {
+			return fmt.Errorf("ZookeeperZNode object (%s) subtree cleanup error: %w", znode.Name, err)
+		}
+	}
+	znode.Finalizers = oputil.Remove(finalizerName, znode.Finalizers)
+	ctx.Logger().Info("Saving updated znode finalizers",
+		"znode", znode.Name, "finalizers", znode.Finalizers)
+	if err := ctx.Client().Update(context.TODO(), znode); err != nil {
+		return fmt.Errorf("ZookeeperZNode object (%s) update error: %w", znode.Name, err)
+	}
+	return nil
+}
+
+// cleanUpZNode deletes the znode subtree and its quota; there's nothing to clean when the cluster is gone
+func cleanUpZNode(ctx reconciler.Context, znode *v1alpha1.ZookeeperZNode) error {
+	path := znode.Status.Path
+	if path == "" {
+		return nil
+	}
+	cluster, err := getCluster(ctx, znode)
+	if err != nil {
+		return err
+	}
+	if cluster == nil || !cluster.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	ctx.Logger().Info("Deleting the znode subtree", "znode", znode.Name, "path", path)
+	cl, err := zk.NewZkClient(ctx.Client(), cluster)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	if err = cl.DeleteNode(path); err != nil {
+		return err
+	}
+	if znode.Status.Quota != nil {
+		return cl.DeleteQuota(path)
+	}
+	return nil
+}
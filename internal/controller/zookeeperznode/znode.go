@@ -0,0 +1,134 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeperznode
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	"github.com/monimesl/zookeeper-operator/internal/zk"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"strings"
+)
+
+// ReconcileZNode creates the znode of the specified object and keeps its ACLs and quota
+// in the declared state; the subtree usage is reported in the status
+func ReconcileZNode(ctx reconciler.Context, znode *v1alpha1.ZookeeperZNode) error {
+	if !znode.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	oldStatus := znode.Status.DeepCopy()
+	err := syncZNode(ctx, znode)
+	znode.Status.ObservedGeneration = znode.Generation
+	if !equality.Semantic.DeepEqual(oldStatus, &znode.Status) {
+		if updateErr := ctx.Client().Status().Update(context.TODO(), znode); updateErr != nil && err == nil {
+			err = updateErr
+		}
+	}
+	if err == nil && znode.Status.Quota != nil {
+		return requeue.After(usageRefreshDelay, "refreshing the znode usage")
+	}
+	return err
+}
+
+func syncZNode(ctx reconciler.Context, znode *v1alpha1.ZookeeperZNode) error {
+	path := znode.Spec.Path
+	if err := validatePath(znode); err != nil {
+		setCondition(znode, v1alpha1.ConditionZNodeReady, false, reasonInvalidPath, err.Error())
+		return err
+	}
+	cluster, err := getCluster(ctx, znode)
+	if err != nil {
+		return err
+	}
+	if cluster == nil {
+		setCondition(znode, v1alpha1.ConditionZNodeReady, false, reasonClusterNotFound,
+			fmt.Sprintf("the cluster %s is not found", znode.Spec.ClusterRef))
+		return requeue.After(clusterRequeueDelay, "waiting for the cluster to be created")
+	}
+	if !cluster.IsAvailable() {
+		setCondition(znode, v1alpha1.ConditionZNodeReady, false, reasonClusterUnavailable,
+			fmt.Sprintf("the cluster %s is not available", cluster.Name))
+		return requeue.After(clusterRequeueDelay, "waiting for the cluster to be available")
+	}
+	cl, err := zk.NewZkClient(ctx.Client(), cluster)
+	if err != nil {
+		setCondition(znode, v1alpha1.ConditionZNodeReady, false, reasonSyncFailed, err.Error())
+		return err
+	}
+	defer cl.Close()
+	if err = applyZNode(ctx, cl, znode); err != nil {
+		err = fmt.Errorf("error syncing the znode %s: %w", path, err)
+		setCondition(znode, v1alpha1.ConditionZNodeReady, false, reasonSyncFailed, err.Error())
+		return err
+	}
+	setCondition(znode, v1alpha1.ConditionZNodeReady, true, reasonSynced, "the znode is in the declared state")
+	return nil
+}
+
+func applyZNode(ctx reconciler.Context, cl *zk.Client, znode *v1alpha1.ZookeeperZNode) error {
+	path := znode.Spec.Path
+	created, err := cl.EnsureNode(path, []byte(znode.Spec.Data), znode.Spec.ACLs)
+	if err != nil {
+		return err
+	}
+	if created {
+		ctx.Logger().Info("Created the znode", "znode", znode.Name, "path", path)
+	} else if err = cl.SetNodeACL(path, znode.Spec.ACLs, znode.Status.ACLs); err != nil {
+		return err
+	}
+	znode.Status.Path = path
+	znode.Status.ACLs = znode.Spec.ACLs
+	if znode.Spec.Quota == nil {
+		if znode.Status.Quota != nil {
+			ctx.Logger().Info("Removing the znode quota", "znode", znode.Name, "path", path)
+			if err = cl.DeleteQuota(path); err != nil {
+				return err
+			}
+		}
+		znode.Status.Quota = nil
+		znode.Status.Usage = nil
+		return nil
+	}
+	if err = cl.SetQuota(path, znode.Spec.Quota.Count, znode.Spec.Quota.Bytes); err != nil {
+		return err
+	}
+	znode.Status.Quota = znode.Spec.Quota.DeepCopy()
+	count, bytes, err := cl.GetQuotaUsage(path)
+	if err != nil {
+		return err
+	}
+	znode.Status.Usage = &v1alpha1.ZNodeUsage{Count: count, Bytes: bytes}
+	return nil
+}
+
+// validatePath rejects the zookeeper reserved znodes and path changes once the znode is created
+func validatePath(znode *v1alpha1.ZookeeperZNode) error {
+	path := znode.Spec.Path
+	if path == "/" || strings.HasSuffix(path, "/") || strings.Contains(path, "//") {
+		return fmt.Errorf("the path %q is not a valid znode path", path)
+	}
+	if path == "/zookeeper" || strings.HasPrefix(path, "/zookeeper/") {
+		return fmt.Errorf("the path %q is reserved by zookeeper", path)
+	}
+	if znode.Status.Path != "" && znode.Status.Path != path {
+		return fmt.Errorf("the path can't be changed from %q to %q", znode.Status.Path, path)
+	}
+	return nil
+}
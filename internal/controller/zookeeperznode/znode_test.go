@@ -0,0 +1,113 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeperznode
+
+import (
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/reconcilertest"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func testZNode(mutate func(znode *v1alpha1.ZookeeperZNode)) *v1alpha1.ZookeeperZNode {
+	znode := &v1alpha1.ZookeeperZNode{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec:       v1alpha1.ZookeeperZNodeSpec{ClusterRef: "zk", Path: "/app"},
+	}
+	if mutate != nil {
+		mutate(znode)
+	}
+	return znode
+}
+
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		path, applied string
+		valid         bool
+	}{
+		{path: "/app", valid: true},
+		{path: "/app/config", valid: true},
+		{path: "/app", applied: "/app", valid: true},
+		{path: "/"},
+		{path: "/app/"},
+		{path: "/app//config"},
+		{path: "/zookeeper"},
+		{path: "/zookeeper/quota/app"},
+		{path: "/other", applied: "/app"},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			err := validatePath(testZNode(func(znode *v1alpha1.ZookeeperZNode) {
+				znode.Spec.Path = test.path
+				znode.Status.Path = test.applied
+			}))
+			if (err == nil) != test.valid {
+				t.Errorf("expected the path %q (applied %q) to be valid=%t, got %v", test.path, test.applied, test.valid, err)
+			}
+		})
+	}
+}
+
+func TestReconcileZNodeInvalidPath(t *testing.T) {
+	znode := testZNode(func(znode *v1alpha1.ZookeeperZNode) {
+		znode.Spec.Path = "/zookeeper/config"
+	})
+	err := ReconcileZNode(reconcilertest.NewContext(znode), znode)
+	if err == nil {
+		t.Fatal("expected the reserved path to be rejected")
+	}
+	condition := meta.FindStatusCondition(znode.Status.Conditions, v1alpha1.ConditionZNodeReady)
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != reasonInvalidPath {
+		t.Errorf("unexpected condition: %v", condition)
+	}
+}
+
+func TestReconcileZNodeWaitsForTheCluster(t *testing.T) {
+	tests := []struct {
+		name     string
+		objects  []client.Object
+		expected string
+	}{
+		{
+			name:     "cluster not found",
+			expected: reasonClusterNotFound,
+		},
+		{
+			name: "cluster unavailable",
+			objects: []client.Object{&v1alpha1.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "zk", Namespace: "default"},
+			}},
+			expected: reasonClusterUnavailable,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			znode := testZNode(nil)
+			err := ReconcileZNode(reconcilertest.NewContext(append(test.objects, znode)...), znode)
+			if after, ok := requeue.Delay(err); !ok || after != clusterRequeueDelay {
+				t.Errorf("expected a requeue after %s, got %v", clusterRequeueDelay, err)
+			}
+			condition := meta.FindStatusCondition(znode.Status.Conditions, v1alpha1.ConditionZNodeReady)
+			if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != test.expected {
+				t.Errorf("expected the reason %s, got %v", test.expected, condition)
+			}
+		})
+	}
+}
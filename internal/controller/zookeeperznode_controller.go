@@ -0,0 +1,94 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/controller/zookeeperznode"
+	"github.com/monimesl/zookeeper-operator/internal/requeue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
+)
+
+var (
+	_                   reconciler.Context    = &ZookeeperZNodeReconciler{}
+	_                   reconciler.Reconciler = &ZookeeperZNodeReconciler{}
+	znodeReconcileFuncs                       = []func(ctx reconciler.Context, znode *v1alpha1.ZookeeperZNode) error{
+		zookeeperznode.ReconcileFinalizer,
+		zookeeperznode.ReconcileZNode,
+	}
+)
+
+// ZookeeperZNodeReconciler defines the reconciler to reconcile ZookeeperZNode resources
+type ZookeeperZNodeReconciler struct {
+	reconciler.Context
+}
+
+// Configure configures the above ZookeeperZNodeReconciler
+func (r *ZookeeperZNodeReconciler) Configure(ctx reconciler.Context) error {
+	r.Context = ctx
+	return ctx.NewControllerBuilder().
+		For(&v1alpha1.ZookeeperZNode{}).
+		Watches(&v1alpha1.ZookeeperCluster{}, handler.EnqueueRequestsFromMapFunc(r.znodesOfCluster)).
+		Complete(r)
+}
+
+// znodesOfCluster maps a cluster to its znodes so they're created once the cluster is available
+func (r *ZookeeperZNodeReconciler) znodesOfCluster(ctx context.Context, obj client.Object) []reconcile.Request {
+	znodes := &v1alpha1.ZookeeperZNodeList{}
+	if err := r.Client().List(ctx, znodes, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Logger().Info("Error listing the znodes of the cluster",
+			"cluster", obj.GetName(), "error", err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range znodes.Items {
+		if znodes.Items[i].Spec.ClusterRef == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&znodes.Items[i]),
+			})
+		}
+	}
+	return requests
+}
+
+// Reconcile handles reconciliation request for ZookeeperZNode instances
+func (r *ZookeeperZNodeReconciler) Reconcile(_ context.Context, request reconcile.Request) (reconcile.Result, error) {
+	znode := &v1alpha1.ZookeeperZNode{}
+	var requeueAfter time.Duration
+	result, err := r.Run(request, znode, func(_ bool) (err error) {
+		for _, fun := range znodeReconcileFuncs {
+			if err = fun(r, znode); err != nil {
+				if after, ok := requeue.Delay(err); ok {
+					requeueAfter = after
+					err = nil
+					continue
+				}
+				break
+			}
+		}
+		return
+	})
+	if err == nil && requeueAfter > 0 {
+		result.RequeueAfter = requeueAfter
+	}
+	return result, err
+}
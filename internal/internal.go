@@ -21,3 +21,22 @@ const OperatorName = "zookeeper-operator"
 
 // Domain defines the domain of the operator
 const Domain = "zookeeper.monime.sl"
+
+// WatchedSecretLabel marks the secrets the operator creates or the clusters reference; only those are cached and watched
+const WatchedSecretLabel = Domain + "/watched"
+
+// WatchedSecretLabels returns the labels of the cached and watched secrets
+func WatchedSecretLabels() map[string]string {
+	return map[string]string{WatchedSecretLabel: "true"}
+}
+
+// MergeLabels merges the labels or annotations maps into a new one; the later maps take precedence
+func MergeLabels(ms ...map[string]string) map[string]string {
+	res := make(map[string]string)
+	for _, m := range ms {
+		for key, value := range m {
+			res[key] = value
+		}
+	}
+	return res
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package keystore converts PEM encoded certificates into the PKCS12 key and
+// trust stores the zookeeper JVM reads.
+package keystore
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"software.sslmate.com/src/go-pkcs12"
+	"time"
+)
+
+// Format names the encoding of the PKCS12 stores; the stores of another format are regenerated
+const Format = "pkcs12-legacy-des"
+
+// NewPKCS12 creates the PKCS12 keystore from the PEM certificate chain and private key
+// and the PKCS12 truststore from the PEM CA bundle; both protected with the password.
+// They're encrypted with 3DES and SHA-1 since the JDK 8 builds before 8u301, which run
+// the zookeeper 3.5 and 3.6 images, can't read the AES and PBKDF2 encrypted ones
+func NewPKCS12(certPEM, keyPEM, caPEM []byte, password string) (keyStore, trustStore []byte, err error) {
+	certs, err := parseCertificates(certPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	cas, err := parseCertificates(caPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CA certificate: %w", err)
+	}
+	if keyStore, err = pkcs12.LegacyDES.Encode(key, certs[0], certs[1:], password); err != nil {
+		return nil, nil, err
+	}
+	if trustStore, err = pkcs12.LegacyDES.EncodeTrustStore(cas, password); err != nil {
+		return nil, nil, err
+	}
+	return keyStore, trustStore, nil
+}
+
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+func parsePrivateKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
+
+// NotAfter returns the expiry time of the first certificate of the PEM chain
+func NotAfter(certPEM []byte) (time.Time, error) {
+	certs, err := parseCertificates(certPEM)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return certs[0].NotAfter, nil
+}
+
+// Leaf returns the DER encoding of the first certificate of the PEM chain
+func Leaf(certPEM []byte) ([]byte, error) {
+	certs, err := parseCertificates(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0].Raw, nil
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package keystore
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"software.sslmate.com/src/go-pkcs12"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPassword = "changeit"
+
+// newCertificate creates a certificate of the key signed by the parent, or self-signed when the parent is nil
+func newCertificate(t *testing.T, name string, key *ecdsa.PrivateKey, parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encodeCertificates(certs ...*x509.Certificate) []byte {
+	var data []byte
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return data
+}
+
+func TestNewPKCS12(t *testing.T) {
+	caKey, leafKey := newKey(t), newKey(t)
+	ca := newCertificate(t, "ca", caKey, nil, nil)
+	leaf := newCertificate(t, "zk", leafKey, ca, caKey)
+	ecDER, err := x509.MarshalECPrivateKey(leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string][]byte{
+		"EC PRIVATE KEY": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}),
+		"PRIVATE KEY":    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER}),
+	}
+	for keyType, keyPEM := range keys {
+		t.Run(keyType, func(t *testing.T) {
+			// The chain carries the CA after the leaf, as cert-manager writes it
+			keyStore, trustStore, err := NewPKCS12(encodeCertificates(leaf, ca), keyPEM, encodeCertificates(ca), testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, cert, chain, err := pkcs12.DecodeChain(keyStore, testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cert.Equal(leaf) {
+				t.Errorf("expected the key store certificate %s, got %s", leaf.Subject, cert.Subject)
+			}
+			if len(chain) != 1 || !chain[0].Equal(ca) {
+				t.Errorf("expected the key store chain to hold the CA, got %d certificates", len(chain))
+			}
+			if decoded, ok := key.(*ecdsa.PrivateKey); !ok || !decoded.Equal(leafKey) {
+				t.Errorf("unexpected key store private key %T", key)
+			}
+			cas, err := pkcs12.DecodeTrustStore(trustStore, testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cas) != 1 || !cas[0].Equal(ca) {
+				t.Errorf("expected the trust store to hold the CA, got %d certificates", len(cas))
+			}
+			if _, _, _, err = pkcs12.DecodeChain(keyStore, "wrong"); err == nil {
+				t.Error("expected the key store to be protected with the password")
+			}
+		})
+	}
+}
+
+func TestNewPKCS12RSA(t *testing.T) {
+	caKey := newKey(t)
+	ca := newCertificate(t, "ca", caKey, nil, nil)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "zk"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &rsaKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyStore, _, err := NewPKCS12(certPEM, keyPEM, encodeCertificates(ca), testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, err := pkcs12.Decode(keyStore, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, ok := key.(*rsa.PrivateKey); !ok || !decoded.Equal(rsaKey) {
+		t.Errorf("unexpected key store private key %T", key)
+	}
+}
+
+func TestNewPKCS12Errors(t *testing.T) {
+	caKey, leafKey := newKey(t), newKey(t)
+	ca := newCertificate(t, "ca", caKey, nil, nil)
+	leaf := newCertificate(t, "zk", leafKey, ca, caKey)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	tests := []struct {
+		name                string
+		certPEM, key, caPEM []byte
+		err                 string
+	}{
+		{"missing certificate", nil, keyPEM, encodeCertificates(ca), "invalid certificate: no PEM certificate found"},
+		{"key as certificate", keyPEM, keyPEM, encodeCertificates(ca), "invalid certificate: no PEM certificate found"},
+		{"missing private key", encodeCertificates(leaf), nil, encodeCertificates(ca), "invalid private key: no PEM private key found"},
+		{"corrupted private key", encodeCertificates(leaf),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("corrupted")}), encodeCertificates(ca),
+			"invalid private key"},
+		{"missing CA", encodeCertificates(leaf), keyPEM, nil, "invalid CA certificate: no PEM certificate found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := NewPKCS12(tt.certPEM, tt.key, tt.caPEM, testPassword)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("expected the error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestNotAfter(t *testing.T) {
+	caKey, leafKey := newKey(t), newKey(t)
+	ca := newCertificate(t, "ca", caKey, nil, nil)
+	leaf := newCertificate(t, "zk", leafKey, ca, caKey)
+	notAfter, err := NotAfter(encodeCertificates(leaf, ca))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !notAfter.Equal(leaf.NotAfter) {
+		t.Errorf("expected the leaf expiry %s, got %s", leaf.NotAfter, notAfter)
+	}
+	if _, err = NotAfter(nil); err == nil {
+		t.Error("expected an error without a certificate")
+	}
+}
+
+func TestLeaf(t *testing.T) {
+	caKey, leafKey := newKey(t), newKey(t)
+	ca := newCertificate(t, "ca", caKey, nil, nil)
+	leaf := newCertificate(t, "zk", leafKey, ca, caKey)
+	der, err := Leaf(encodeCertificates(leaf, ca))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(der, leaf.Raw) {
+		t.Error("expected the DER encoding of the leaf certificate")
+	}
+	if _, err = Leaf([]byte("invalid")); err == nil {
+		t.Error("expected an error without a certificate")
+	}
+}
+
+func TestNewPKCS12LegacyEncryption(t *testing.T) {
+	// the DER encoded object identifiers of the PKCS12 3DES and of the PKCS5 PBES2 encryptions
+	tripleDES := []byte{0x06, 0x0a, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x0c, 0x01, 0x03}
+	pbes2 := []byte{0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x05, 0x0d}
+	caKey, leafKey := newKey(t), newKey(t)
+	ca := newCertificate(t, "ca", caKey, nil, nil)
+	leaf := newCertificate(t, "zk", leafKey, ca, caKey)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyStore, _, err := NewPKCS12(encodeCertificates(leaf, ca),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), encodeCertificates(ca), testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(keyStore, tripleDES) || bytes.Contains(keyStore, pbes2) {
+		t.Error("expected the key store to be encrypted with 3DES, which the JDK 8 reads")
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package metrics defines the operator metrics, served with the controller-runtime ones
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"time"
+)
+
+const namespace = "zookeeper_operator"
+
+var (
+	reconcilePhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_phase_duration_seconds",
+		Help:      "Duration of the phases of the ZookeeperCluster reconciliation",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"phase"})
+	reconcilePhaseErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_phase_errors_total",
+		Help:      "Number of the failed phases of the ZookeeperCluster reconciliation",
+	}, []string{"phase"})
+	zkOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "zk_operation_duration_seconds",
+		Help:      "Duration of the operations the operator runs against the ensembles",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"operation"})
+	zkOperationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "zk_operation_failures_total",
+		Help:      "Number of the failed operations the operator ran against the ensembles",
+	}, []string{"operation"})
+	clusterDesiredMembers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_desired_members",
+		Help:      "Size of the cluster spec",
+	}, []string{"namespace", "cluster"})
+	clusterReadyMembers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_ready_members",
+		Help:      "Number of the ready members of the cluster",
+	}, []string{"namespace", "cluster"})
+	clusterQuorumHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_quorum_healthy",
+		Help:      "Whether a majority of the voting members of the cluster are ready and following a leader",
+	}, []string{"namespace", "cluster"})
+	clusterLeaderChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cluster_leader_changes_total",
+		Help:      "Number of the leader changes observed by the operator",
+	}, []string{"namespace", "cluster"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		reconcilePhaseDuration,
+		reconcilePhaseErrors,
+		zkOperationDuration,
+		zkOperationFailures,
+		clusterDesiredMembers,
+		clusterReadyMembers,
+		clusterQuorumHealthy,
+		clusterLeaderChanges,
+	)
+}
+
+// ObserveReconcilePhase records the duration of the reconcile phase started at start, and its failure if any
+func ObserveReconcilePhase(phase string, start time.Time, err error) {
+	reconcilePhaseDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+	if err != nil {
+		reconcilePhaseErrors.WithLabelValues(phase).Inc()
+	}
+}
+
+// ObserveZkOperation records the duration of the zookeeper operation started at start, and its failure if any.
+// It's deferred with the address of the operation error
+func ObserveZkOperation(operation string, start time.Time, err *error) {
+	zkOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil && *err != nil {
+		zkOperationFailures.WithLabelValues(operation).Inc()
+	}
+}
+
+// SetClusterMembers records the desired and ready members of the cluster and its quorum health
+func SetClusterMembers(namespace, name string, desired, ready int32, quorumHealthy bool) {
+	clusterDesiredMembers.WithLabelValues(namespace, name).Set(float64(desired))
+	clusterReadyMembers.WithLabelValues(namespace, name).Set(float64(ready))
+	healthy := 0.0
+	if quorumHealthy {
+		healthy = 1
+	}
+	clusterQuorumHealthy.WithLabelValues(namespace, name).Set(healthy)
+}
+
+// RecordLeaderChange counts a leader change of the cluster
+func RecordLeaderChange(namespace, name string) {
+	clusterLeaderChanges.WithLabelValues(namespace, name).Inc()
+}
+
+// DeleteCluster removes the series of the deleted cluster
+func DeleteCluster(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "cluster": name}
+	clusterDesiredMembers.Delete(labels)
+	clusterReadyMembers.Delete(labels)
+	clusterQuorumHealthy.Delete(labels)
+	clusterLeaderChanges.Delete(labels)
+}
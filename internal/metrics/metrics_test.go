@@ -0,0 +1,53 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+	"time"
+)
+
+func TestObserveZkOperation(t *testing.T) {
+	observe := func(err error) {
+		defer ObserveZkOperation("test", time.Now(), &err)
+	}
+	observe(nil)
+	observe(errors.New("connection refused"))
+	if count := testutil.CollectAndCount(zkOperationDuration, namespace+"_zk_operation_duration_seconds"); count != 1 {
+		t.Errorf("expected a single operation series, got %d", count)
+	}
+	if failures := testutil.ToFloat64(zkOperationFailures.WithLabelValues("test")); failures != 1 {
+		t.Errorf("expected a single failure, got %v", failures)
+	}
+}
+
+func TestClusterMembers(t *testing.T) {
+	SetClusterMembers("default", "zk", 3, 2, true)
+	RecordLeaderChange("default", "zk")
+	if ready := testutil.ToFloat64(clusterReadyMembers.WithLabelValues("default", "zk")); ready != 2 {
+		t.Errorf("expected 2 ready members, got %v", ready)
+	}
+	if healthy := testutil.ToFloat64(clusterQuorumHealthy.WithLabelValues("default", "zk")); healthy != 1 {
+		t.Errorf("expected the quorum to be healthy, got %v", healthy)
+	}
+	DeleteCluster("default", "zk")
+	if series := testutil.CollectAndCount(clusterReadyMembers) + testutil.CollectAndCount(clusterLeaderChanges); series != 0 {
+		t.Errorf("expected the series of the deleted cluster to be removed, got %d", series)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconcilertest
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	"k8s.io/apimachinery/pkg/util/managedfields/managedfieldstest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// defaultFieldManager is the field manager the api server derives from the user agent of the operator binary
+const defaultFieldManager = "manager"
+
+// NewApplyContext creates a test context whose client also serves the server-side apply patches, which the
+// fake client doesn't support. Like the api server, it tracks the fields set by each field manager in the
+// managed fields of the objects it creates, updates and applies
+func NewApplyContext(objects ...client.Object) *Context {
+	f := &fieldManagers{managers: map[schema.GroupVersionKind]*managedfields.FieldManager{}}
+	return newContext(interceptor.Funcs{Create: f.create, Update: f.update, Patch: f.patch}, objects)
+}
+
+// fieldManagers keeps the field manager of each kind
+type fieldManagers struct {
+	managers map[schema.GroupVersionKind]*managedfields.FieldManager
+}
+
+func (f *fieldManagers) get(gvk schema.GroupVersionKind) *managedfields.FieldManager {
+	manager, ok := f.managers[gvk]
+	if !ok {
+		manager = managedfieldstest.NewFakeFieldManager(managedfields.NewDeducedTypeConverter(), gvk)
+		f.managers[gvk] = manager
+	}
+	return manager
+}
+
+func (f *fieldManagers) create(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+	options := (&client.CreateOptions{}).ApplyOptions(opts)
+	if err := f.track(c, nil, obj, options.FieldManager); err != nil {
+		return err
+	}
+	return c.Create(ctx, obj, opts...)
+}
+
+func (f *fieldManagers) update(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+	options := (&client.UpdateOptions{}).ApplyOptions(opts)
+	live := obj.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		return err
+	}
+	if err := f.track(c, live, obj, options.FieldManager); err != nil {
+		return err
+	}
+	return c.Update(ctx, obj, opts...)
+}
+
+// track sets the managed fields of the object created, if live is nil, or updated by the manager
+func (f *fieldManagers) track(c client.Client, live, obj client.Object, manager string) error {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	if manager == "" {
+		manager = defaultFieldManager
+	}
+	liveObj := emptyObject(gvk)
+	if live != nil {
+		if liveObj, err = toUnstructured(live, gvk); err != nil {
+			return err
+		}
+	}
+	newObj, err := toUnstructured(obj, gvk)
+	if err != nil {
+		return err
+	}
+	tracked, err := f.get(gvk).Update(liveObj, newObj, manager)
+	if err != nil {
+		return err
+	}
+	obj.SetManagedFields(tracked.(*unstructured.Unstructured).GetManagedFields())
+	return nil
+}
+
+// patch merges the apply patches into the live object, creating it if not found; the other patches are left to the fake client
+func (f *fieldManagers) patch(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+	options := (&client.PatchOptions{}).ApplyOptions(opts)
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	applied, err := toUnstructured(obj, gvk)
+	if err != nil {
+		return err
+	}
+	key := client.ObjectKeyFromObject(obj)
+	live := obj.DeepCopyObject().(client.Object)
+	liveObj := emptyObject(gvk)
+	err = c.Get(ctx, key, live)
+	found := err == nil
+	if found {
+		if liveObj, err = toUnstructured(live, gvk); err != nil {
+			return err
+		}
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	force := options.Force != nil && *options.Force
+	result, err := f.get(gvk).Apply(liveObj, applied, options.FieldManager, force)
+	if err != nil {
+		return err
+	}
+	merged := result.(*unstructured.Unstructured)
+	if found && equalIgnoringManagedFields(liveObj, merged) {
+		// the api server doesn't write the objects an apply leaves unchanged
+		return c.Get(ctx, key, obj)
+	}
+	desired, err := c.Scheme().New(gvk)
+	if err != nil {
+		return err
+	}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(merged.Object, desired); err != nil {
+		return err
+	}
+	if found {
+		err = c.Update(ctx, desired.(client.Object))
+	} else {
+		err = c.Create(ctx, desired.(client.Object))
+	}
+	if err != nil {
+		return err
+	}
+	return c.Get(ctx, key, obj)
+}
+
+func emptyObject(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	return obj
+}
+
+func toUnstructured(obj client.Object, gvk schema.GroupVersionKind) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	return u, nil
+}
+
+func equalIgnoringManagedFields(a, b *unstructured.Unstructured) bool {
+	a, b = a.DeepCopy(), b.DeepCopy()
+	a.SetManagedFields(nil)
+	b.SetManagedFields(nil)
+	return equality.Semantic.DeepEqual(a.Object, b.Object)
+}
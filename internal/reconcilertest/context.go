@@ -0,0 +1,112 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package reconcilertest provides a reconciler context backed by a fake client
+// for the tests of the reconcile functions
+package reconcilertest
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ reconciler.Context = &Context{}
+
+// Context is the reconciler context of the tests; its client is a fake client
+// and the recorded events are kept by its Recorder
+type Context struct {
+	Recorder *record.FakeRecorder
+	client   client.Client
+	scheme   *runtime.Scheme
+}
+
+// NewContext creates a test context whose client serves the specified objects
+func NewContext(objects ...client.Object) *Context {
+	return newContext(interceptor.Funcs{}, objects)
+}
+
+func newContext(funcs interceptor.Funcs, objects []client.Object) *Context {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	kubeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&v1alpha1.ZookeeperCluster{}, &v1alpha1.ZookeeperUser{}, &v1alpha1.ZookeeperZNode{}).
+		WithInterceptorFuncs(funcs).
+		Build()
+	return &Context{Recorder: record.NewFakeRecorder(100), client: kubeClient, scheme: scheme}
+}
+
+func (c *Context) NewControllerBuilder() *builder.Builder {
+	return nil
+}
+
+func (c *Context) Client() client.Client {
+	return c.client
+}
+
+func (c *Context) Scheme() *runtime.Scheme {
+	return c.scheme
+}
+
+// EventRecorder returns the recorder of the events
+func (c *Context) EventRecorder() record.EventRecorder {
+	return c.Recorder
+}
+
+func (c *Context) Logger() logr.Logger {
+	return logr.Discard()
+}
+
+func (c *Context) Run(reconcile.Request, reconciler.KubeRuntimeObject, func(deleted bool) error) (reconcile.Result, error) {
+	panic("the test context does not run reconciles")
+}
+
+func (c *Context) SetOwnershipReference(owner metav1.Object, controlled metav1.Object) error {
+	return controllerutil.SetControllerReference(owner, controlled, c.scheme)
+}
+
+func (c *Context) GetResource(key client.ObjectKey, object client.Object, foundCallback func() (err error), notFoundCallback func() (err error)) error {
+	err := c.client.Get(context.TODO(), key, object)
+	if err == nil {
+		if foundCallback == nil {
+			return nil
+		}
+		return foundCallback()
+	}
+	if errors.IsNotFound(err) {
+		if notFoundCallback == nil {
+			return nil
+		}
+		return notFoundCallback()
+	}
+	return err
+}
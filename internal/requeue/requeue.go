@@ -0,0 +1,50 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package requeue lets the reconcile functions ask for another run after a delay
+package requeue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Error is returned by a reconcile function that needs the object to be
+// reconciled again after a delay. It's not a failure; the reconciler continues
+// with the remaining functions and schedules the next run.
+type Error struct {
+	After  time.Duration
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("requeue after %s: %s", e.After, e.Reason)
+}
+
+// After returns the Error asking for a reconciliation after the delay
+func After(after time.Duration, reason string) error {
+	return &Error{After: after, Reason: reason}
+}
+
+// Delay returns the delay of the Error wrapped by err
+func Delay(err error) (time.Duration, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.After, true
+	}
+	return 0, false
+}
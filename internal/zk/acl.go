@@ -0,0 +1,169 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zk
+
+import (
+	"crypto/sha1" //nolint:gosec // zookeeper's digest scheme is sha1 based
+	"encoding/base64"
+	"errors"
+	"github.com/go-zookeeper/zk"
+	"github.com/monimesl/operator-helper/config"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/metrics"
+	"strings"
+	"time"
+)
+
+var permissions = map[v1alpha1.ACLPermission]int32{
+	v1alpha1.ACLPermissionRead:   zk.PermRead,
+	v1alpha1.ACLPermissionWrite:  zk.PermWrite,
+	v1alpha1.ACLPermissionCreate: zk.PermCreate,
+	v1alpha1.ACLPermissionDelete: zk.PermDelete,
+	v1alpha1.ACLPermissionAdmin:  zk.PermAdmin,
+}
+
+// Digest computes the digest scheme id of the credentials: username:base64(sha1(username:password))
+func Digest(username, password string) string {
+	sum := sha1.Sum([]byte(username + ":" + password)) //nolint:gosec
+	return username + ":" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// GrantDigestACL grants the permissions to the digest id on the znode. The previous entries of
+// the same username are replaced and the other entries are kept. A missing znode is created
+// with the granted ACL
+func (c *Client) GrantDigestACL(path, digest string, perms []v1alpha1.ACLPermission) (err error) {
+	defer metrics.ObserveZkOperation("grant_acl", time.Now(), &err)
+	entry := zk.ACL{Scheme: digestScheme, ID: digest, Perms: toZkPerms(perms)}
+	username, _, _ := strings.Cut(digest, ":")
+	acl, stat, err := c.conn.GetACL(path)
+	if errors.Is(err, zk.ErrNoNode) {
+		config.RequireRootLogger().Info("Creating the znode of the granted ACL", "path", path)
+		return c.createNodeWithACL(path, nil, append([]zk.ACL{entry}, c.operatorACL()...))
+	} else if err != nil {
+		return err
+	}
+	updated := append(withoutUser(acl, username), entry)
+	if equalACL(acl, updated) {
+		return nil
+	}
+	_, err = c.conn.SetACL(path, updated, stat.Aversion)
+	return err
+}
+
+// RevokeDigestACL removes the permissions of the digest username from the znode. Since a znode
+// ACL can't be empty, the operator keeps the access when no other entry is left
+func (c *Client) RevokeDigestACL(path, username string) (err error) {
+	defer metrics.ObserveZkOperation("revoke_acl", time.Now(), &err)
+	acl, stat, err := c.conn.GetACL(path)
+	if errors.Is(err, zk.ErrNoNode) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	updated := withoutUser(acl, username)
+	if len(updated) == len(acl) {
+		return nil
+	}
+	if len(updated) == 0 {
+		if updated = c.operatorACL(); len(updated) == 0 {
+			updated = zk.WorldACL(zk.PermAll)
+		}
+	}
+	_, err = c.conn.SetACL(path, updated, stat.Aversion)
+	return err
+}
+
+// operatorACL returns the ACL granting all the permissions to the operator credentials, if any
+func (c *Client) operatorACL() []zk.ACL {
+	if c.endpoint == nil || c.endpoint.credentials == nil {
+		return nil
+	}
+	username, password, _ := strings.Cut(string(c.endpoint.credentials), ":")
+	return []zk.ACL{{Scheme: digestScheme, ID: Digest(username, password), Perms: zk.PermAll}}
+}
+
+// createNodeWithACL creates the znode with the ACL; the missing parents are created with the world ACL
+func (c *Client) createNodeWithACL(path string, data []byte, acl []zk.ACL) error {
+	if parent := path[:strings.LastIndex(path, "/")]; parent != "" {
+		if err := c.createNode(parent, nil); err != nil {
+			return err
+		}
+	}
+	_, err := c.conn.Create(path, data, 0, acl)
+	if errors.Is(err, zk.ErrNodeExists) {
+		return nil
+	}
+	return err
+}
+
+func toZkPerms(perms []v1alpha1.ACLPermission) int32 {
+	var res int32
+	for _, p := range perms {
+		res |= permissions[p]
+	}
+	return res
+}
+
+func withoutUser(acl []zk.ACL, username string) []zk.ACL {
+	res := make([]zk.ACL, 0, len(acl))
+	for _, entry := range acl {
+		if entry.Scheme != digestScheme || !strings.HasPrefix(entry.ID, username+":") {
+			res = append(res, entry)
+		}
+	}
+	return res
+}
+
+// mergeACL replaces the applied entries of the ACL with the declared ones; the entries are identified
+// by their scheme and id
+func mergeACL(acl, declared, applied []zk.ACL) []zk.ACL {
+	res := make([]zk.ACL, 0, len(acl)+len(declared))
+	for _, entry := range acl {
+		if !containsACLID(declared, entry) && !containsACLID(applied, entry) {
+			res = append(res, entry)
+		}
+	}
+	return append(res, declared...)
+}
+
+func containsACLID(acl []zk.ACL, entry zk.ACL) bool {
+	for _, other := range acl {
+		if other.Scheme == entry.Scheme && other.ID == entry.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func equalACL(a, b []zk.ACL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, entry := range b {
+		found := false
+		for _, other := range a {
+			if entry == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zk
+
+import (
+	"github.com/go-zookeeper/zk"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"reflect"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	// the digest zookeeper's DigestAuthenticationProvider generates for super:admin
+	if d := Digest("super", "admin"); d != "super:xQJmxLMiHGwaqBvst5y6rkB6HQs=" {
+		t.Errorf("unexpected digest: %s", d)
+	}
+}
+
+func TestToZkPerms(t *testing.T) {
+	perms := toZkPerms([]v1alpha1.ACLPermission{v1alpha1.ACLPermissionRead, v1alpha1.ACLPermissionWrite})
+	if perms != zk.PermRead|zk.PermWrite {
+		t.Errorf("unexpected permissions: %d", perms)
+	}
+	all := toZkPerms([]v1alpha1.ACLPermission{
+		v1alpha1.ACLPermissionRead, v1alpha1.ACLPermissionWrite, v1alpha1.ACLPermissionCreate,
+		v1alpha1.ACLPermissionDelete, v1alpha1.ACLPermissionAdmin,
+	})
+	if all != zk.PermAll {
+		t.Errorf("unexpected permissions: %d", all)
+	}
+}
+
+func TestWithoutUser(t *testing.T) {
+	acl := []zk.ACL{
+		{Scheme: digestScheme, ID: Digest("bob", "secret"), Perms: zk.PermRead},
+		{Scheme: digestScheme, ID: Digest("bobby", "secret"), Perms: zk.PermRead},
+		{Scheme: "world", ID: "anyone", Perms: zk.PermRead},
+		{Scheme: "sasl", ID: "bob", Perms: zk.PermAll},
+	}
+	expected := acl[1:]
+	if res := withoutUser(acl, "bob"); !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+}
+
+func TestMergeACL(t *testing.T) {
+	world := zk.ACL{Scheme: "world", ID: "anyone", Perms: zk.PermAll}
+	bob := zk.ACL{Scheme: digestScheme, ID: Digest("bob", "secret"), Perms: zk.PermRead}
+	alice := zk.ACL{Scheme: digestScheme, ID: Digest("alice", "secret"), Perms: zk.PermAll}
+	admin := zk.ACL{Scheme: "ip", ID: "10.0.0.1", Perms: zk.PermAdmin}
+	tests := []struct {
+		name                   string
+		acl, declared, applied []zk.ACL
+		expected               []zk.ACL
+	}{
+		{
+			name:     "the granted entries are kept",
+			acl:      []zk.ACL{world, bob},
+			declared: []zk.ACL{alice},
+			applied:  []zk.ACL{world},
+			expected: []zk.ACL{bob, alice},
+		},
+		{
+			name:     "the entries no longer declared are removed",
+			acl:      []zk.ACL{alice, admin, bob},
+			declared: []zk.ACL{alice},
+			applied:  []zk.ACL{alice, admin},
+			expected: []zk.ACL{bob, alice},
+		},
+		{
+			name:     "the declared permissions are applied",
+			acl:      []zk.ACL{{Scheme: digestScheme, ID: alice.ID, Perms: zk.PermRead}, bob},
+			declared: []zk.ACL{alice},
+			applied:  []zk.ACL{alice},
+			expected: []zk.ACL{bob, alice},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if res := mergeACL(test.acl, test.declared, test.applied); !reflect.DeepEqual(res, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestEqualACL(t *testing.T) {
+	bob := zk.ACL{Scheme: digestScheme, ID: Digest("bob", "secret"), Perms: zk.PermRead}
+	alice := zk.ACL{Scheme: digestScheme, ID: Digest("alice", "secret"), Perms: zk.PermAll}
+	if !equalACL([]zk.ACL{bob, alice}, []zk.ACL{alice, bob}) {
+		t.Error("expected the ACLs to be equal regardless of the order")
+	}
+	if equalACL([]zk.ACL{bob}, []zk.ACL{bob, alice}) {
+		t.Error("expected the ACLs of different sizes to differ")
+	}
+	writer := bob
+	writer.Perms = zk.PermWrite
+	if equalACL([]zk.ACL{bob, alice}, []zk.ACL{writer, alice}) {
+		t.Error("expected the ACLs with different permissions to differ")
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zk
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"net"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+const digestScheme = "digest"
+
+// Endpoint defines how the operator reaches the servers of a cluster
+type Endpoint struct {
+	// Port is the client port the operator connects to
+	Port int32
+	// tlsConfig is set when only the secure client port is open
+	tlsConfig *tls.Config
+	// credentials are the digest `username:password` the operator authenticates with
+	credentials []byte
+}
+
+// NewEndpoint creates the endpoint of the specified cluster. The plain client port is preferred;
+// on secure-only clusters the TLS material is loaded from the cluster TLS secret. The operator
+// credentials are loaded from the secret referenced by the cluster authentication settings
+func NewEndpoint(kubeClient client.Client, cluster *v1alpha1.ZookeeperCluster) (*Endpoint, error) {
+	endpoint := &Endpoint{Port: cluster.Spec.Ports.SecureClient}
+	if cluster.Spec.Ports.Client > 0 {
+		endpoint.Port = cluster.Spec.Ports.Client
+	} else if cluster.IsClientTLSEnabled() {
+		tlsConfig, err := loadTLSConfig(kubeClient, cluster)
+		if err != nil {
+			return nil, err
+		}
+		endpoint.tlsConfig = tlsConfig
+	}
+	if secretName := cluster.OperatorCredentialsSecretName(); secretName != "" {
+		credentials, err := loadCredentials(kubeClient, cluster.Namespace, secretName)
+		if err != nil {
+			return nil, err
+		}
+		endpoint.credentials = credentials
+	}
+	return endpoint, nil
+}
+
+// IsSecure returns whether the endpoint connects over TLS
+func (e *Endpoint) IsSecure() bool {
+	return e.tlsConfig != nil
+}
+
+// Address returns the address of the endpoint port on the specified host
+func (e *Endpoint) Address(host string) string {
+	return net.JoinHostPort(host, fmt.Sprintf("%d", e.Port))
+}
+
+// dial implements the zookeeper client dialer; it wraps the connection in TLS when required
+func (e *Endpoint) dial(network, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if e.tlsConfig == nil {
+		return dialer.Dial(network, address)
+	}
+	return tls.DialWithDialer(dialer, network, address, e.tlsConfig)
+}
+
+// ServedCertificate returns the DER encoding of the certificate the TLS server at the address presents.
+// The handshake may then fail since the members can require a client certificate the operator doesn't send
+func ServedCertificate(address string) ([]byte, error) {
+	var served []byte
+	dialer := &net.Dialer{Timeout: fourLetterWordTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Only the presented certificate is read; it's compared with the expected one
+		InsecureSkipVerify: true, //nolint:gosec
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) > 0 {
+				served = rawCerts[0]
+			}
+			return nil
+		},
+	})
+	if conn != nil {
+		_ = conn.Close()
+	}
+	if served == nil {
+		if err == nil {
+			err = errors.New("no certificate presented")
+		}
+		return nil, fmt.Errorf("error reading the certificate served at %s: %w", address, err)
+	}
+	return served, nil
+}
+
+// loadTLSConfig builds the client TLS configuration from the cluster TLS secret. The members share
+// the same certificate, so the server name is pinned to the client service for pod IP connections too
+func loadTLSConfig(kubeClient client.Client, cluster *v1alpha1.ZookeeperCluster) (*tls.Config, error) {
+	secret, err := getSecret(kubeClient, cluster.Namespace, cluster.TLSSecretName())
+	if err != nil {
+		return nil, fmt.Errorf("error loading the TLS secret: %w", err)
+	}
+	certificate, err := tls.X509KeyPair(secret.Data[v1alpha1.TLSCertKey], secret.Data[v1alpha1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS certificate in secret %s: %w", secret.Name, err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(secret.Data[v1alpha1.TLSCAKey]) {
+		return nil, fmt.Errorf("no CA certificate found in secret %s", secret.Name)
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		ServerName:   cluster.ClientServiceFQDN(),
+		RootCAs:      rootCAs,
+		Certificates: []tls.Certificate{certificate},
+	}, nil
+}
+
+func loadCredentials(kubeClient client.Client, namespace, name string) ([]byte, error) {
+	secret, err := getSecret(kubeClient, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the operator credentials: %w", err)
+	}
+	username := secret.Data[v1alpha1.CredentialsUsernameKey]
+	password := secret.Data[v1alpha1.CredentialsPasswordKey]
+	if len(username) == 0 || len(password) == 0 {
+		return nil, errors.New("the operator credentials secret " + name + " requires a username and a password")
+	}
+	return []byte(fmt.Sprintf("%s:%s", username, password)), nil
+}
+
+func getSecret(kubeClient client.Client, namespace, name string) (*v1.Secret, error) {
+	secret := &v1.Secret{}
+	err := kubeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, secret)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zk
+
+import (
+	"bytes"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+)
+
+func testEndpointCluster(mutate func(cluster *v1alpha1.ZookeeperCluster)) *v1alpha1.ZookeeperCluster {
+	cluster := &v1alpha1.ZookeeperCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "zk", Namespace: "default"},
+	}
+	if mutate != nil {
+		mutate(cluster)
+	}
+	cluster.SetSpecDefaults()
+	return cluster
+}
+
+func TestNewEndpoint(t *testing.T) {
+	credentials := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "zk-operator", Namespace: "default"},
+		Data: map[string][]byte{
+			v1alpha1.CredentialsUsernameKey: []byte("operator"),
+			v1alpha1.CredentialsPasswordKey: []byte("secret"),
+		},
+	}
+	kubeClient := fake.NewClientBuilder().WithObjects(credentials).Build()
+
+	cluster := testEndpointCluster(nil)
+	endpoint, err := NewEndpoint(kubeClient, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.Port != cluster.Spec.Ports.Client || endpoint.IsSecure() || endpoint.credentials != nil {
+		t.Errorf("expected the plain client port without credentials, got %+v", endpoint)
+	}
+	if address := endpoint.Address("10.0.0.1"); address != "10.0.0.1:2181" {
+		t.Errorf("expected the address 10.0.0.1:2181, got %s", address)
+	}
+
+	cluster = testEndpointCluster(func(cluster *v1alpha1.ZookeeperCluster) {
+		cluster.Spec.Authentication = &v1alpha1.Authentication{OperatorCredentialsSecret: "zk-operator"}
+	})
+	endpoint, err = NewEndpoint(kubeClient, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(endpoint.credentials) != "operator:secret" {
+		t.Errorf("expected the credentials operator:secret, got %q", endpoint.credentials)
+	}
+}
+
+func TestNewEndpointErrors(t *testing.T) {
+	incomplete := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "incomplete", Namespace: "default"},
+		Data:       map[string][]byte{v1alpha1.CredentialsUsernameKey: []byte("operator")},
+	}
+	tests := []struct {
+		name     string
+		objects  []client.Object
+		mutate   func(cluster *v1alpha1.ZookeeperCluster)
+		expected string
+	}{
+		{
+			name: "missing credentials secret",
+			mutate: func(cluster *v1alpha1.ZookeeperCluster) {
+				cluster.Spec.Authentication = &v1alpha1.Authentication{OperatorCredentialsSecret: "missing"}
+			},
+			expected: "error loading the operator credentials",
+		},
+		{
+			name:    "credentials without password",
+			objects: []client.Object{incomplete},
+			mutate: func(cluster *v1alpha1.ZookeeperCluster) {
+				cluster.Spec.Authentication = &v1alpha1.Authentication{OperatorCredentialsSecret: "incomplete"}
+			},
+			expected: "the operator credentials secret incomplete requires a username and a password",
+		},
+		{
+			name: "secure only without the TLS secret",
+			mutate: func(cluster *v1alpha1.ZookeeperCluster) {
+				cluster.Spec.Ports = &v1alpha1.Ports{Client: -1, SecureClient: 2281}
+				cluster.Spec.TLS = &v1alpha1.TLS{Client: true, SecretName: "zk-tls"}
+			},
+			expected: "error loading the TLS secret",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := fake.NewClientBuilder().WithObjects(test.objects...).Build()
+			_, err := NewEndpoint(kubeClient, testEndpointCluster(test.mutate))
+			if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+				t.Errorf("expected the error %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestServedCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	served, err := ServedCertificate(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(served, server.Certificate().Raw) {
+		t.Error("expected the certificate the server presents")
+	}
+	address := server.Listener.Addr().String()
+	server.Close()
+	if _, err = ServedCertificate(address); err == nil {
+		t.Error("expected an error without a server")
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zk
+
+import (
+	"fmt"
+	"github.com/monimesl/operator-helper/config"
+	"github.com/monimesl/zookeeper-operator/internal/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// configZNode holds the dynamic configuration of the ensemble
+	configZNode = "/zookeeper/config"
+	// RoleParticipant is the role of the voting members in the dynamic configuration
+	RoleParticipant = "participant"
+	// RoleObserver is the role of the non-voting members in the dynamic configuration
+	RoleObserver = "observer"
+)
+
+// Ensemble defines the dynamic configuration of the ensemble
+type Ensemble struct {
+	// Version is the version of the configuration
+	Version int64
+	// Members are the configured members by their server id
+	Members map[int32]EnsembleMember
+}
+
+// EnsembleMember defines a server of the dynamic configuration
+type EnsembleMember struct {
+	ID   int32
+	Role string
+}
+
+// IsParticipant tells whether the server with the id is a voting member of the ensemble
+func (e *Ensemble) IsParticipant(id int32) bool {
+	member, ok := e.Members[id]
+	return ok && member.Role == RoleParticipant
+}
+
+// GetEnsemble reads the latest dynamic configuration of the ensemble
+func (c *Client) GetEnsemble() (_ *Ensemble, err error) {
+	defer metrics.ObserveZkOperation("get_ensemble", time.Now(), &err)
+	if _, err := c.conn.Sync(configZNode); err != nil {
+		return nil, err
+	}
+	data, _, err := c.conn.Get(configZNode)
+	if err != nil {
+		return nil, err
+	}
+	return parseEnsemble(string(data))
+}
+
+// AddParticipant adds the server, or promotes the observer, as a voting member of the ensemble.
+// The server is in the dynamic configuration format: server.<id>=<host>:<quorum>:<leader>:participant;<client>
+func (c *Client) AddParticipant(server string, version int64) (err error) {
+	defer metrics.ObserveZkOperation("add_participant", time.Now(), &err)
+	config.RequireRootLogger().Info("Adding the participant to the ensemble", "server", server)
+	_, err = c.conn.IncrementalReconfig([]string{server}, nil, version)
+	return err
+}
+
+// RemoveMember removes the server with the id from the ensemble
+func (c *Client) RemoveMember(id int32, version int64) (err error) {
+	defer metrics.ObserveZkOperation("remove_member", time.Now(), &err)
+	config.RequireRootLogger().Info("Removing the member from the ensemble", "id", id)
+	_, err = c.conn.IncrementalReconfig(nil, []string{strconv.Itoa(int(id))}, version)
+	return err
+}
+
+// parseEnsemble parses the dynamic configuration lines:
+// server.<id>=<host>:<quorum>:<leader>[:<role>][;<client>] and version=<hex>
+func parseEnsemble(data string) (*Ensemble, error) {
+	ensemble := &Ensemble{Members: map[int32]EnsembleMember{}}
+	for key, value := range parseLines([]byte(data), "=") {
+		if key == "version" {
+			version, err := strconv.ParseInt(value, 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ensemble config version %q: %w", value, err)
+			}
+			ensemble.Version = version
+			continue
+		}
+		idStr, found := strings.CutPrefix(key, "server.")
+		if !found {
+			continue
+		}
+		id, err := strconv.ParseInt(idStr, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ensemble server %q: %w", key, err)
+		}
+		addresses, _, _ := strings.Cut(value, ";")
+		role := RoleParticipant
+		if parts := strings.Split(addresses, ":"); len(parts) > 3 {
+			role = parts[3]
+		}
+		ensemble.Members[int32(id)] = EnsembleMember{ID: int32(id), Role: role}
+	}
+	return ensemble, nil
+}
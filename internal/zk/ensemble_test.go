@@ -0,0 +1,77 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEnsemble(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		ensemble Ensemble
+	}{
+		{
+			// The 3.5 members omit the default participant role
+			fixture: "config-3.5.7.txt",
+			ensemble: Ensemble{Version: 0x100000000, Members: map[int32]EnsembleMember{
+				1: {ID: 1, Role: RoleParticipant},
+				2: {ID: 2, Role: RoleParticipant},
+				3: {ID: 3, Role: RoleParticipant},
+			}},
+		},
+		{
+			fixture: "config-3.8.4.txt",
+			ensemble: Ensemble{Version: 0x30000000a, Members: map[int32]EnsembleMember{
+				1: {ID: 1, Role: RoleParticipant},
+				2: {ID: 2, Role: RoleParticipant},
+				3: {ID: 3, Role: RoleParticipant},
+				4: {ID: 4, Role: RoleObserver},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			ensemble, err := parseEnsemble(string(readFixture(t, tt.fixture)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*ensemble, tt.ensemble) {
+				t.Errorf("expected %+v, got %+v", tt.ensemble, *ensemble)
+			}
+			if !ensemble.IsParticipant(1) || ensemble.IsParticipant(4) || ensemble.IsParticipant(5) {
+				t.Errorf("unexpected participants in %+v", *ensemble)
+			}
+		})
+	}
+}
+
+func TestParseEnsembleErrors(t *testing.T) {
+	for _, data := range []string{
+		"server.1=zk-0:2888:3888:participant;2181\nversion=nothex\n",
+		"server.one=zk-0:2888:3888:participant;2181\nversion=100000000\n",
+	} {
+		if _, err := parseEnsemble(data); err == nil {
+			t.Errorf("expected an error for %q", data)
+		}
+	}
+	ensemble, err := parseEnsemble("")
+	if err != nil || ensemble.Version != 0 || len(ensemble.Members) != 0 {
+		t.Errorf("expected an empty ensemble, got %+v, %v", ensemble, err)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zk
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/monimesl/zookeeper-operator/internal/metrics"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	fourLetterWordTimeout = 3 * time.Second
+)
+
+// ServerStat defines the state of a single zookeeper server as reported by the `srvr` command
+type ServerStat struct {
+	// Mode is the server role: leader, follower, observer or standalone
+	Mode string
+	// Zxid is the last transaction id processed by the server
+	Zxid int64
+	// Outstanding is the number of queued requests
+	Outstanding int64
+	// NodeCount is the number of znodes in the server's data tree
+	NodeCount int64
+}
+
+// Srvr runs the `srvr` four-letter-word command against the server on the host
+func (e *Endpoint) Srvr(host string) (*ServerStat, error) {
+	address := e.Address(host)
+	data, err := e.fourLetterWord(address, "srvr")
+	if err != nil {
+		return nil, err
+	}
+	stat, err := parseSrvr(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", address, err)
+	}
+	return stat, nil
+}
+
+// Mntr runs the `mntr` four-letter-word command against the server on the host
+// and returns the reported key/value pairs
+func (e *Endpoint) Mntr(host string) (map[string]string, error) {
+	data, err := e.fourLetterWord(e.Address(host), "mntr")
+	if err != nil {
+		return nil, err
+	}
+	return parseLines(data, "\t"), nil
+}
+
+// LeaderStat defines the sync state of the ensemble as reported by the leader `mntr`
+type LeaderStat struct {
+	// SyncedFollowers is the number of voting followers in sync with the leader
+	SyncedFollowers int64
+	// SyncedObservers is the number of observers and non-voting followers in sync with the leader
+	SyncedObservers int64
+	// ObserversReported tells whether the leader reports its synced observers; the 3.5 leaders don't
+	ObserversReported bool
+	// PendingSyncs is the number of members the leader is still syncing
+	PendingSyncs int64
+}
+
+// Leader runs the `mntr` command against the server on the host and returns the
+// ensemble sync state; it fails when the server isn't the leader
+func (e *Endpoint) Leader(host string) (*LeaderStat, error) {
+	values, err := e.Mntr(host)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := parseLeaderStat(values)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", host, err)
+	}
+	return stat, nil
+}
+
+// Synced returns the number of members, the leader included, in sync with the leader. Only
+// the voting ones are counted when the observers aren't reported
+func (in *LeaderStat) Synced() int32 {
+	return int32(in.SyncedFollowers+in.SyncedObservers) + 1
+}
+
+// parseSrvr parses the `srvr` response; the server state is required
+func parseSrvr(data []byte) (*ServerStat, error) {
+	stat := &ServerStat{}
+	for key, value := range parseLines(data, ":") {
+		switch key {
+		case "Mode":
+			stat.Mode = value
+		case "Zxid":
+			zxid, err := parseInt64(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid zxid (%s): %w", value, err)
+			}
+			stat.Zxid = zxid
+		case "Outstanding":
+			stat.Outstanding, _ = parseInt64(value)
+		case "Node count":
+			stat.NodeCount, _ = parseInt64(value)
+		}
+	}
+	if stat.Mode == "" {
+		return nil, fmt.Errorf("unexpected srvr response: %q", string(data))
+	}
+	return stat, nil
+}
+
+// parseLeaderStat reads the sync state of the ensemble from the `mntr` values; the server must be the leader
+func parseLeaderStat(values map[string]string) (*LeaderStat, error) {
+	if state := values["zk_server_state"]; state != "leader" {
+		return nil, fmt.Errorf("the server is not the leader: %q", state)
+	}
+	stat := &LeaderStat{}
+	stat.SyncedFollowers, _ = parseInt64(values["zk_synced_followers"])
+	stat.PendingSyncs, _ = parseInt64(values["zk_pending_syncs"])
+	for _, key := range []string{"zk_synced_observers", "zk_synced_non_voting_followers"} {
+		if count, err := parseInt64(values[key]); err == nil {
+			stat.SyncedObservers += count
+			stat.ObserversReported = true
+		}
+	}
+	return stat, nil
+}
+
+func parseLines(data []byte, separator string) map[string]string {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), separator)
+		if found {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+// parseInt64 parses decimal and hex values; zxids are reported as unsigned hex
+func parseInt64(s string) (int64, error) {
+	if strings.HasPrefix(s, "0x") {
+		i, err := strconv.ParseUint(s, 0, 64)
+		return int64(i), err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func (e *Endpoint) fourLetterWord(address, command string) (_ []byte, err error) {
+	defer metrics.ObserveZkOperation(command, time.Now(), &err)
+	conn, err := e.dial("tcp", address, fourLetterWordTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(fourLetterWordTimeout)); err != nil {
+		return nil, err
+	}
+	if _, err = conn.Write([]byte(command)); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(conn)
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zk
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readFixture reads the response captured from a server in the testdata directory
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseLines(t *testing.T) {
+	data := readFixture(t, "srvr-3.5.7-leader.txt")
+	values := parseLines(data, ":")
+	expected := map[string]string{
+		"Zookeeper version":           "3.5.7-f0fdd52973d373ffd9c86b81d99842dc2c7f660e, built on 02/10/2020 11:30 GMT",
+		"Latency min/avg/max":         "0/0/12",
+		"Received":                    "152",
+		"Sent":                        "151",
+		"Connections":                 "1",
+		"Outstanding":                 "0",
+		"Zxid":                        "0x100000005",
+		"Mode":                        "leader",
+		"Node count":                  "5",
+		"Proposal sizes last/min/max": "36/36/84",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+	values = parseLines(readFixture(t, "mntr-3.8.4-leader.txt"), "\t")
+	if version := values["zk_version"]; version != "3.8.4-9316c2a7a97e1666d8f4593f34dd6fc36ecc436c, built on 2024-02-12 22:16 UTC" {
+		t.Errorf("unexpected zk_version %q", version)
+	}
+	if state := values["zk_peer_state"]; state != "leading - broadcast" {
+		t.Errorf("unexpected zk_peer_state %q", state)
+	}
+	if values := parseLines([]byte("\nno separator\n"), "\t"); len(values) != 0 {
+		t.Errorf("expected no values, got %v", values)
+	}
+}
+
+func TestParseSrvr(t *testing.T) {
+	tests := []struct {
+		fixture string
+		stat    ServerStat
+	}{
+		{"srvr-3.5.7-leader.txt", ServerStat{Mode: "leader", Zxid: 0x100000005, NodeCount: 5}},
+		{"srvr-3.6.3-follower.txt", ServerStat{Mode: "follower", Zxid: 0x200000002, Outstanding: 2, NodeCount: 6}},
+		{"srvr-3.8.4-observer.txt", ServerStat{Mode: "observer", Zxid: 0x30000000c, NodeCount: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			stat, err := parseSrvr(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *stat != tt.stat {
+				t.Errorf("expected %+v, got %+v", tt.stat, *stat)
+			}
+		})
+	}
+}
+
+func TestParseSrvrErrors(t *testing.T) {
+	tests := map[string]string{
+		"This ZooKeeper instance is not currently serving requests\n": "unexpected srvr response",
+		"srvr is not executed because it is not in the whitelist.\n":  "unexpected srvr response",
+		"Zxid: 0xnothex\nMode: leader\n":                              "invalid zxid (0xnothex)",
+	}
+	for response, expected := range tests {
+		if _, err := parseSrvr([]byte(response)); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected an error containing %q for %q, got %v", expected, response, err)
+		}
+	}
+}
+
+func TestParseLeaderStat(t *testing.T) {
+	tests := []struct {
+		fixture string
+		stat    LeaderStat
+		synced  int32
+	}{
+		{
+			fixture: "mntr-3.5.7-leader.txt",
+			stat:    LeaderStat{SyncedFollowers: 2},
+			synced:  3,
+		},
+		{
+			fixture: "mntr-3.6.3-leader.txt",
+			stat:    LeaderStat{SyncedFollowers: 2, SyncedObservers: 1, ObserversReported: true, PendingSyncs: 1},
+			synced:  4,
+		},
+		{
+			fixture: "mntr-3.8.4-leader.txt",
+			stat:    LeaderStat{SyncedFollowers: 2, SyncedObservers: 1, ObserversReported: true},
+			synced:  4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			stat, err := parseLeaderStat(parseLines(readFixture(t, tt.fixture), "\t"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *stat != tt.stat {
+				t.Errorf("expected %+v, got %+v", tt.stat, *stat)
+			}
+			if synced := stat.Synced(); synced != tt.synced {
+				t.Errorf("expected %d synced members, got %d", tt.synced, synced)
+			}
+		})
+	}
+	if _, err := parseLeaderStat(parseLines(readFixture(t, "mntr-3.8.4-follower.txt"), "\t")); err == nil ||
+		!strings.Contains(err.Error(), `the server is not the leader: "follower"`) {
+		t.Errorf("expected the follower to be rejected, got %v", err)
+	}
+}
+
+func TestParseInt64(t *testing.T) {
+	tests := map[string]int64{
+		"42":                 42,
+		"-1":                 -1,
+		"0x100000005":        0x100000005,
+		"0xffffffffffffffff": -1,
+	}
+	for value, expected := range tests {
+		if i, err := parseInt64(value); err != nil || i != expected {
+			t.Errorf("expected %s to parse to %d, got %d, %v", value, expected, i, err)
+		}
+	}
+	for _, value := range []string{"", "0x", "0x1g", "1.5", "ff"} {
+		if _, err := parseInt64(value); err == nil {
+			t.Errorf("expected %q to be invalid", value)
+		}
+	}
+}
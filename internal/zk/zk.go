@@ -22,6 +22,8 @@ import (
 	"github.com/go-zookeeper/zk"
 	"github.com/monimesl/operator-helper/config"
 	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strings"
 	"time"
 )
@@ -29,27 +31,17 @@ import (
 const (
 	// ClusterMetadataParentZNode defines the znode to store metadata for the ZookeeperCluster objects
 	ClusterMetadataParentZNode = "/zookeeper/operator-cluster-metadata"
-	updateTimeNode             = "updatedat"
-	sizeNode                   = "size"
 )
 
 type Client struct {
-	conn *zk.Conn
-}
-
-// UpdateMetadata update the metadata of the specified cluster
-func UpdateMetadata(cluster *v1alpha1.ZookeeperCluster) error {
-	if cl, err := NewZkClient(cluster); err != nil {
-		return err
-	} else {
-		defer cl.Close()
-		return cl.updateClusterSizeMeta(cluster)
-	}
+	conn     *zk.Conn
+	endpoint *Endpoint
 }
 
 // DeleteMetadata deletes all zNodes created by the zookeeper cluster
-func DeleteMetadata(cluster *v1alpha1.ZookeeperCluster) error {
-	if cl, err := NewZkClient(cluster); err != nil {
+func DeleteMetadata(kubeClient client.Client, cluster *v1alpha1.ZookeeperCluster) (err error) {
+	defer metrics.ObserveZkOperation("delete_metadata", time.Now(), &err)
+	if cl, err := NewZkClient(kubeClient, cluster); err != nil {
 		return err
 	} else {
 		defer cl.Close()
@@ -58,32 +50,27 @@ func DeleteMetadata(cluster *v1alpha1.ZookeeperCluster) error {
 	}
 }
 
-// NewZkClient creates a new zookeeper client connected to the specified cluster
-func NewZkClient(cluster *v1alpha1.ZookeeperCluster) (*Client, error) {
-	port := cluster.Spec.Ports.SecureClient
-	if cluster.Spec.Ports.Client > 0 {
-		port = cluster.Spec.Ports.Client
-	}
-	address := fmt.Sprintf("%s:%d", cluster.ClientServiceFQDN(), port)
-	c, _, err := zk.Connect([]string{address}, 10*time.Second)
+// NewZkClient creates a new zookeeper client connected to the specified cluster.
+// The connection goes over TLS on secure-only clusters and is authenticated
+// when the cluster defines the operator credentials
+func NewZkClient(kubeClient client.Client, cluster *v1alpha1.ZookeeperCluster) (_ *Client, err error) {
+	defer metrics.ObserveZkOperation("connect", time.Now(), &err)
+	endpoint, err := NewEndpoint(kubeClient, cluster)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{conn: c}, nil
-}
-
-func (c *Client) updateClusterSizeMeta(cluster *v1alpha1.ZookeeperCluster) error {
-	config.RequireRootLogger().Info("Updating the ZookeeperCluster"+
-		" metadata in zookeeper", "cluster", cluster.GetName())
-	sizeZNode := clusterSizeNode()
-	updateTimeZNode := clusterUpdateTimeNode()
-	var size = int(*cluster.Spec.Size)
-	err := c.setNodeData(sizeZNode, []byte(fmt.Sprintf("%d", size)))
+	address := endpoint.Address(cluster.ClientServiceFQDN())
+	c, _, err := zk.Connect([]string{address}, 10*time.Second, zk.WithDialer(endpoint.dial))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if endpoint.credentials != nil {
+		if err = c.AddAuth(digestScheme, endpoint.credentials); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("error authenticating with the cluster %s: %w", cluster.GetName(), err)
+		}
 	}
-	now := time.Now().UnixNano() / int64(time.Millisecond)
-	return c.setNodeData(updateTimeZNode, []byte(fmt.Sprintf("%d", now)))
+	return &Client{conn: c, endpoint: endpoint}, nil
 }
 
 // Close closes the zookeeper connection
@@ -96,30 +83,6 @@ func clusterNode() string {
 	return ClusterMetadataParentZNode
 }
 
-func clusterSizeNode() string {
-	return fmt.Sprintf("%s/%s", clusterNode(), sizeNode)
-}
-
-func clusterUpdateTimeNode() string {
-	return fmt.Sprintf("%s/%s", clusterNode(), updateTimeNode)
-}
-
-func (c *Client) setNodeData(path string, data []byte) (err error) {
-	config.RequireRootLogger().
-		Info("Creating the operator metadata node",
-			"path", path, "data", string(data))
-	_, stats, err := c.getNode(path)
-	if errors.Is(err, zk.ErrNoNode) {
-		return c.createNode(path, data)
-	} else if err != nil {
-		return err
-	}
-	if _, err = c.conn.Set(path, data, stats.Version); errors.Is(err, zk.ErrNodeExists) {
-		return nil
-	}
-	return
-}
-
 func (c *Client) getNode(clusterNode string) ([]byte, *zk.Stat, error) {
 	data, sts, err := c.conn.Get(clusterNode)
 	if err != nil {
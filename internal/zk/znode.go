@@ -0,0 +1,149 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zk
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-zookeeper/zk"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"github.com/monimesl/zookeeper-operator/internal/metrics"
+	"strings"
+	"time"
+)
+
+const (
+	// quotaZNode is the parent of the quota nodes; zookeeper tracks the subtree of
+	// <quotaZNode><path> when its limits node exists
+	quotaZNode     = "/zookeeper/quota"
+	quotaLimitNode = "zookeeper_limits"
+	quotaStatsNode = "zookeeper_stats"
+	// unlimited is the quota value of a limit which isn't set
+	unlimited int64 = -1
+)
+
+// EnsureNode creates the znode with the data and ACLs if it doesn't exist. It returns whether it was created
+func (c *Client) EnsureNode(path string, data []byte, acls []v1alpha1.ZNodeACL) (_ bool, err error) {
+	defer metrics.ObserveZkOperation("ensure_node", time.Now(), &err)
+	exists, _, err := c.conn.Exists(path)
+	if err != nil || exists {
+		return false, err
+	}
+	return true, c.createNodeWithACL(path, data, toZkACL(acls))
+}
+
+// SetNodeACL merges the declared ACL entries into the ACL of the znode. The applied entries no longer
+// declared are removed, while the ones set by the others, like the ZookeeperUser grants, are kept
+func (c *Client) SetNodeACL(path string, acls, applied []v1alpha1.ZNodeACL) (err error) {
+	defer metrics.ObserveZkOperation("set_node_acl", time.Now(), &err)
+	acl, stat, err := c.conn.GetACL(path)
+	if err != nil {
+		return err
+	}
+	desired := mergeACL(acl, toZkACL(acls), toZkACL(applied))
+	if equalACL(acl, desired) {
+		return nil
+	}
+	_, err = c.conn.SetACL(path, desired, stat.Aversion)
+	return err
+}
+
+// DeleteNode deletes the znode and its subtree
+func (c *Client) DeleteNode(path string) (err error) {
+	defer metrics.ObserveZkOperation("delete_node", time.Now(), &err)
+	return c.deleteNodes(path)
+}
+
+// SetQuota sets the count and bytes limits of the znode subtree; a nil limit is unlimited
+func (c *Client) SetQuota(path string, count, bytes *int64) (err error) {
+	defer metrics.ObserveZkOperation("set_quota", time.Now(), &err)
+	limits := []byte(formatQuota(valueOr(count, unlimited), valueOr(bytes, unlimited)))
+	limitPath := fmt.Sprintf("%s%s/%s", quotaZNode, path, quotaLimitNode)
+	current, stat, err := c.conn.Get(limitPath)
+	if err == nil {
+		if string(current) == string(limits) {
+			return nil
+		}
+		_, err = c.conn.Set(limitPath, limits, stat.Version)
+		return err
+	} else if !errors.Is(err, zk.ErrNoNode) {
+		return err
+	}
+	// Like the CLI setquota; the stats node must exist before the limits node so zookeeper
+	// computes the subtree usage when the limits are created
+	statsPath := fmt.Sprintf("%s%s/%s", quotaZNode, path, quotaStatsNode)
+	if err = c.createNode(statsPath, []byte(formatQuota(0, 0))); err != nil {
+		return err
+	}
+	_, err = c.conn.Create(limitPath, limits, 0, zk.WorldACL(zk.PermAll))
+	return err
+}
+
+// DeleteQuota removes the quota of the znode subtree
+func (c *Client) DeleteQuota(path string) (err error) {
+	defer metrics.ObserveZkOperation("delete_quota", time.Now(), &err)
+	return c.deleteNodes(quotaZNode + path)
+}
+
+// GetQuotaUsage returns the znodes count and data bytes of the subtree as tracked by its quota
+func (c *Client) GetQuotaUsage(path string) (count, bytes int64, err error) {
+	defer metrics.ObserveZkOperation("get_quota_usage", time.Now(), &err)
+	data, _, err := c.conn.Get(fmt.Sprintf("%s%s/%s", quotaZNode, path, quotaStatsNode))
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseQuota(string(data))
+}
+
+// formatQuota formats the limits or stats like zookeeper's StatsTrack: count=<count>,bytes=<bytes>
+func formatQuota(count, bytes int64) string {
+	return fmt.Sprintf("count=%d,bytes=%d", count, bytes)
+}
+
+func parseQuota(data string) (count, bytes int64, err error) {
+	for _, pair := range strings.Split(data, ",") {
+		key, value, _ := strings.Cut(pair, "=")
+		switch key {
+		case "count":
+			count, err = parseInt64(value)
+		case "bytes":
+			bytes, err = parseInt64(value)
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid quota stats %q: %w", data, err)
+		}
+	}
+	return count, bytes, nil
+}
+
+func toZkACL(acls []v1alpha1.ZNodeACL) []zk.ACL {
+	if len(acls) == 0 {
+		return zk.WorldACL(zk.PermAll)
+	}
+	res := make([]zk.ACL, 0, len(acls))
+	for _, acl := range acls {
+		res = append(res, zk.ACL{Scheme: acl.Scheme, ID: acl.ID, Perms: toZkPerms(acl.Permissions)})
+	}
+	return res
+}
+
+func valueOr(value *int64, def int64) int64 {
+	if value == nil {
+		return def
+	}
+	return *value
+}
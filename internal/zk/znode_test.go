@@ -0,0 +1,71 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zk
+
+import (
+	"github.com/go-zookeeper/zk"
+	"github.com/monimesl/zookeeper-operator/api/v1alpha1"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuota(t *testing.T) {
+	tests := []struct {
+		data         string
+		count, bytes int64
+	}{
+		{"count=12,bytes=340", 12, 340},
+		// The unset limits, as written by the 3.5 and 3.6 zkCli
+		{"count=-1,bytes=-1", -1, -1},
+		// The 3.7+ stats carry the hard limits too
+		{"count=10,bytes=-1,countHardLimit=10,byteHardLimit=-1", 10, -1},
+		{formatQuota(5, 1024), 5, 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			count, bytes, err := parseQuota(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.count || bytes != tt.bytes {
+				t.Errorf("expected %d, %d, got %d, %d", tt.count, tt.bytes, count, bytes)
+			}
+		})
+	}
+	for _, data := range []string{"count=many,bytes=1", "count=1,bytes=1.5"} {
+		if _, _, err := parseQuota(data); err == nil {
+			t.Errorf("expected an error for %q", data)
+		}
+	}
+}
+
+func TestToZkACL(t *testing.T) {
+	if acl := toZkACL(nil); !reflect.DeepEqual(acl, zk.WorldACL(zk.PermAll)) {
+		t.Errorf("expected the world ACL when none is declared, got %v", acl)
+	}
+	acl := toZkACL([]v1alpha1.ZNodeACL{
+		{Scheme: "world", ID: "anyone", Permissions: []v1alpha1.ACLPermission{v1alpha1.ACLPermissionRead}},
+		{Scheme: "sasl", ID: "app", Permissions: []v1alpha1.ACLPermission{v1alpha1.ACLPermissionRead, v1alpha1.ACLPermissionWrite}},
+	})
+	expected := []zk.ACL{
+		{Scheme: "world", ID: "anyone", Perms: zk.PermRead},
+		{Scheme: "sasl", ID: "app", Perms: zk.PermRead | zk.PermWrite},
+	}
+	if !reflect.DeepEqual(acl, expected) {
+		t.Errorf("expected %v, got %v", expected, acl)
+	}
+}
@@ -23,11 +23,15 @@ import (
 	"github.com/monimesl/operator-helper/config"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/operator-helper/webhook"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/monimesl/zookeeper-operator/internal"
@@ -48,6 +52,11 @@ func init() {
 
 func main() {
 	cfg, options := config.GetManagerParams(scheme, internal.OperatorName, internal.Domain)
+	// Only the labelled secrets are cached; the others are read from the api server
+	options.Cache.ByObject = map[client.Object]cache.ByObject{
+		&v1.Secret{}: {Label: labels.SelectorFromSet(internal.WatchedSecretLabels())},
+	}
+	options.Client.Cache = &client.CacheOptions{DisableFor: []client.Object{&v1.Secret{}}}
 	mgr, err := manager.New(cfg, options)
 	if err != nil {
 		log.Fatalf("manager create error: %s", err)
@@ -57,7 +66,9 @@ func main() {
 		log.Fatalf("webhook config error: %s", err)
 	}
 	if err = reconciler.Configure(mgr,
-		&controller.ZookeeperClusterReconciler{}); err != nil {
+		&controller.ZookeeperClusterReconciler{Recorder: mgr.GetEventRecorderFor(internal.OperatorName)},
+		&controller.ZookeeperUserReconciler{},
+		&controller.ZookeeperZNodeReconciler{}); err != nil {
 		log.Fatalf("reconciler cfg error: %s", err)
 	}
 	if err = mgr.Start(ctrl.SetupSignalHandler()); err != nil {